@@ -0,0 +1,183 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrInvalidWidth is returned for a placeholder or prefix width other
+	// than 1, 2, 4 or 8.
+	ErrInvalidWidth = errors.New("binpacker: invalid width")
+	// ErrPlaceholderFilled is returned when a Placeholder is filled twice.
+	ErrPlaceholderFilled = errors.New("binpacker: placeholder already filled")
+)
+
+const (
+	patchBuf  = iota // patch the append or holding buffer
+	patchAt          // patch with io.WriterAt
+	patchSeek        // patch with io.Seeker
+)
+
+// Placeholder is an unsigned integer written by Reserve whose value is filled
+// in later with Fill.
+type Placeholder struct {
+	p      *Packer
+	width  int
+	endian binary.ByteOrder
+	offset int64 // Packer offset of the placeholder
+	mode   int
+	pos    int64 // index into buf or position in the writer
+}
+
+// Reserve writes width zero bytes (1, 2, 4 or 8) and returns a Placeholder to
+// fill them in later.
+//
+// An io.WriterAt or io.WriteSeeker writer is patched in place. For other
+// writers, everything written from the first unfilled placeholder on is held
+// in memory until all placeholders are filled. An io.WriterAt that is not an
+// io.Seeker is assumed to start at position 0.
+func (p *Packer) Reserve(width int) Placeholder {
+	ph := Placeholder{p: p, width: width, endian: p.endian, offset: p.offset}
+	p.errFilter(func() {
+		if width != 1 && width != 2 && width != 4 && width != 8 {
+			p.fail("Reserve", ErrInvalidWidth)
+			return
+		}
+		ph.mode, ph.pos = p.patchMode()
+		p.reserved = append(p.reserved, p.offset)
+		p.scratch = [scratchSize]byte{}
+		p.write("Reserve", p.scratch[:width])
+		if p.tracer != nil {
+			p.tracePushed("Reserve", p.scratch[:width], nil)
+		}
+	})
+	return ph
+}
+
+// patchMode returns how a placeholder at the current offset is patched.
+func (p *Packer) patchMode() (int, int64) {
+	if p.appending || p.holding {
+		return patchBuf, int64(len(p.buf))
+	}
+	_, at := p.writer.(io.WriterAt)
+	if s, ok := p.writer.(io.Seeker); ok {
+		// Pipes are io.Seekers that fail to seek; they are held instead.
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			if at {
+				return patchAt, pos
+			}
+			return patchSeek, pos
+		}
+	} else if at {
+		return patchAt, p.offset
+	}
+	p.holding = true
+	return patchBuf, int64(len(p.buf))
+}
+
+// Fill writes v into the placeholder. Held data is written to the writer once
+// the last placeholder is filled.
+func (ph Placeholder) Fill(v uint64) error {
+	p := ph.p
+	if p.err != nil {
+		return p.err
+	}
+	if ph.width < 8 && v>>(8*uint(ph.width)) != 0 {
+		p.err = p.traceError(&Error{Op: "Fill", Offset: ph.offset, Err: ErrLengthOverflow})
+		return p.err
+	}
+	i := 0
+	for i < len(p.reserved) && p.reserved[i] != ph.offset {
+		i++
+	}
+	if i == len(p.reserved) {
+		p.err = p.traceError(&Error{Op: "Fill", Offset: ph.offset, Err: ErrPlaceholderFilled})
+		return p.err
+	}
+	p.reserved = append(p.reserved[:i], p.reserved[i+1:]...)
+
+	b := p.scratch[:ph.width]
+	switch ph.width {
+	case 1:
+		b[0] = uint8(v)
+	case 2:
+		ph.endian.PutUint16(b, uint16(v))
+	case 4:
+		ph.endian.PutUint32(b, uint32(v))
+	case 8:
+		ph.endian.PutUint64(b, v)
+	}
+	var err error
+	switch ph.mode {
+	case patchBuf:
+		copy(p.buf[ph.pos:], b)
+	case patchAt:
+		_, err = p.writer.(io.WriterAt).WriteAt(b, ph.pos)
+	case patchSeek:
+		err = ph.seekWrite(b)
+	}
+	if err != nil {
+		p.err = p.traceError(&Error{Op: "Fill", Offset: ph.offset, Want: uint64(len(b)), Err: err})
+		return p.err
+	}
+	if p.tracer != nil {
+		p.tracer.OnPush("Fill", ph.offset, b, v)
+	}
+	if p.holding && len(p.reserved) == 0 && p.sections == 0 {
+		p.flush()
+	}
+	return p.err
+}
+
+// seekWrite writes b at the placeholder's position and seeks back.
+func (ph Placeholder) seekWrite(b []byte) error {
+	s := ph.p.writer.(io.Seeker)
+	end, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Seek(ph.pos, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := ph.p.writer.Write(b)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.Seek(end, io.SeekStart)
+	return err
+}
+
+// flush writes the held data to the writer.
+func (p *Packer) flush() {
+	p.holding = false
+	offset := p.offset - int64(len(p.buf))
+	n, err := p.writer.Write(p.buf)
+	if err == nil && n < len(p.buf) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		p.err = p.traceError(&Error{Op: "Fill", Offset: offset + int64(n), Want: uint64(len(p.buf)), Got: uint64(n), Err: err})
+	}
+	p.buf = p.buf[:0]
+}
+
+// LengthPrefixed writes a width byte length prefix followed by whatever f
+// pushes, filling in the length once f returns.
+func (p *Packer) LengthPrefixed(width int, f func(*Packer)) *Packer {
+	return p.errFilter(func() {
+		p.traceEnter("LengthPrefixed")
+		defer p.traceLeave("LengthPrefixed")
+		ph := p.Reserve(width)
+		if p.err != nil {
+			return
+		}
+		start := p.offset
+		f(p)
+		ph.Fill(uint64(p.offset - start))
+	})
+}
@@ -0,0 +1,137 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seekBuffer is an in-memory io.WriteSeeker.
+type seekBuffer struct {
+	data []byte
+	pos  int64
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	if end := b.pos + int64(len(p)); end > int64(len(b.data)) {
+		b.data = append(b.data, make([]byte, end-int64(len(b.data)))...)
+	}
+	n := copy(b.data[b.pos:], p)
+	b.pos += int64(n)
+	return n, nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += b.pos
+	case io.SeekEnd:
+		offset += int64(len(b.data))
+	}
+	b.pos = offset
+	return offset, nil
+}
+
+// atBuffer is an in-memory io.WriterAt that is not an io.Seeker.
+type atBuffer struct {
+	bytes.Buffer
+}
+
+func (b *atBuffer) WriteAt(p []byte, off int64) (int, error) {
+	return copy(b.Bytes()[off:], p), nil
+}
+
+func packWithPlaceholders(p *Packer) {
+	count := p.Reserve(2)
+	p.PushByte(0xFF)
+	p.LengthPrefixed(4, func(p *Packer) {
+		p.PushString("abc")
+		p.LengthPrefixed(1, func(p *Packer) {
+			p.PushUint16(7)
+		})
+	})
+	count.Fill(2)
+}
+
+func TestReserve(t *testing.T) {
+	want := []byte{0, 2, 0xFF, 0, 0, 0, 6, 'a', 'b', 'c', 2, 0, 7}
+
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, buf)
+	p.PushByte(0xEE)
+	packWithPlaceholders(p)
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, buf.Bytes(), append([]byte{0xEE}, want...), "held error.")
+
+	p = NewAppendPacker(binary.BigEndian, []byte{0xEE})
+	packWithPlaceholders(p)
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, p.Bytes(), append([]byte{0xEE}, want...), "append error.")
+
+	sb := &seekBuffer{}
+	sb.Write([]byte{0xEE})
+	p = NewPacker(binary.BigEndian, sb)
+	packWithPlaceholders(p)
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, sb.data, append([]byte{0xEE}, want...), "seek error.")
+	assert.Equal(t, sb.pos, int64(len(want)+1), "position error.")
+
+	ab := &atBuffer{}
+	p = NewPacker(binary.BigEndian, ab)
+	packWithPlaceholders(p)
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, ab.Bytes(), want, "WriterAt error.")
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "packed"))
+	assert.NoError(t, err)
+	defer f.Close()
+	f.Write([]byte{0xEE})
+	p = NewPacker(binary.BigEndian, f)
+	packWithPlaceholders(p)
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	f.Seek(0, io.SeekStart)
+	got, _ := io.ReadAll(f)
+	assert.Equal(t, got, append([]byte{0xEE}, want...), "file error.")
+}
+
+func TestReserveHoldsUntilFilled(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.LittleEndian, buf)
+	a := p.Reserve(1)
+	b := p.Reserve(2)
+	p.PushByte(3)
+	assert.Equal(t, a.Fill(1), nil, "Has error.")
+	assert.Equal(t, buf.Len(), 0, "data should be held.")
+	assert.Equal(t, b.Fill(2), nil, "Has error.")
+	assert.Equal(t, buf.Bytes(), []byte{1, 2, 0, 3}, "held error.")
+	assert.Equal(t, p.Offset(), int64(4), "offset error.")
+
+	p.PushByte(4)
+	assert.Equal(t, buf.Bytes(), []byte{1, 2, 0, 3, 4}, "write error.")
+}
+
+func TestReserveErrors(t *testing.T) {
+	p := NewPacker(binary.BigEndian, new(bytes.Buffer))
+	p.Reserve(3)
+	assert.ErrorIs(t, p.Error(), ErrInvalidWidth, "width error.")
+
+	p = NewPacker(binary.BigEndian, new(bytes.Buffer))
+	ph := p.Reserve(1)
+	assert.ErrorIs(t, ph.Fill(256), ErrLengthOverflow, "overflow error.")
+
+	p = NewPacker(binary.BigEndian, new(bytes.Buffer))
+	ph = p.Reserve(1)
+	ph.Fill(1)
+	assert.ErrorIs(t, ph.Fill(1), ErrPlaceholderFilled, "filled error.")
+
+	p = NewPacker(binary.BigEndian, new(bytes.Buffer))
+	p.LengthPrefixed(1, func(p *Packer) {
+		p.PushBytes(make([]byte, 256))
+	})
+	assert.ErrorIs(t, p.Error(), ErrLengthOverflow, "overflow error.")
+}
@@ -0,0 +1,199 @@
+package binpacker
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	// ErrInvalidNibble is matched by errors.Is for every *NibbleError.
+	ErrInvalidNibble = errors.New("binpacker: invalid BCD nibble")
+	// ErrInvalidDigit is returned by PushTBCD for a character that has no
+	// TBCD nibble.
+	ErrInvalidDigit = errors.New("binpacker: invalid TBCD digit")
+)
+
+// NibbleError is returned for a BCD or TBCD field with a nibble that is not a
+// digit.
+type NibbleError struct {
+	Nibble byte // the nibble
+	Index  int  // its index in the field, in digits
+}
+
+func (e *NibbleError) Error() string {
+	return fmt.Sprintf("binpacker: invalid BCD nibble %#x at digit %d", e.Nibble, e.Index)
+}
+
+// Is reports whether target is ErrInvalidNibble.
+func (e *NibbleError) Is(target error) bool {
+	return target == ErrInvalidNibble
+}
+
+// maxBCDBytes is the number of bytes of the largest BCD number, 20 digits.
+const maxBCDBytes = 10
+
+// tbcdDigits are the characters of the TBCD nibbles 0 to 0xE; 0xF is filler.
+const tbcdDigits = "0123456789*#abc"
+
+// decodeBCD decodes the packed BCD b, most significant digit first.
+func decodeBCD(b []byte) (uint64, error) {
+	var v uint64
+	for i := 0; i < 2*len(b); i++ {
+		d := b[i/2] >> 4
+		if i%2 == 1 {
+			d = b[i/2] & 0x0F
+		}
+		if d > 9 {
+			return 0, &NibbleError{Nibble: d, Index: i}
+		}
+		if v > (math.MaxUint64-uint64(d))/10 {
+			return 0, ErrValueOverflow
+		}
+		v = 10*v + uint64(d)
+	}
+	return v, nil
+}
+
+// decodeTBCD decodes the TBCD b, low nibble first, up to the filler.
+func decodeTBCD(b []byte) (string, error) {
+	var sb strings.Builder
+	sb.Grow(2 * len(b))
+	filled := false
+	for i := 0; i < 2*len(b); i++ {
+		d := b[i/2] & 0x0F
+		if i%2 == 1 {
+			d = b[i/2] >> 4
+		}
+		switch {
+		case d == 0x0F:
+			filled = true
+		case filled:
+			// Only filler may follow the filler.
+			return "", &NibbleError{Nibble: d, Index: i}
+		default:
+			sb.WriteByte(tbcdDigits[d])
+		}
+	}
+	return sb.String(), nil
+}
+
+// PushBCD write a uint64 as nBytes bytes (1 to 10) of packed BCD into writer,
+// two digits per byte and most significant digit first, whatever the byte
+// order. A value with more than 2*nBytes digits is rejected with
+// ErrValueOverflow.
+func (p *Packer) PushBCD(v uint64, nBytes int) *Packer {
+	return p.errFilter(func() {
+		const op = "PushBCD"
+		if nBytes < 1 || nBytes > maxBCDBytes {
+			p.fail(op, ErrInvalidWidth)
+			return
+		}
+		b := p.scratch[:nBytes]
+		x := v
+		for i := nBytes - 1; i >= 0; i-- {
+			b[i] = byte(x/10%10)<<4 | byte(x%10)
+			x /= 100
+		}
+		if x != 0 {
+			p.fail(op, ErrValueOverflow)
+			return
+		}
+		p.write(op, b)
+		if p.tracer != nil {
+			p.tracePushed(op, b, v)
+		}
+	})
+}
+
+// ShiftBCD fetch nBytes bytes (1 to 10) of packed BCD in io.Reader and convert
+// them to uint64. A nibble above 9 is a *NibbleError, and a value too large
+// for a uint64 is ErrValueOverflow.
+func (u *Unpacker) ShiftBCD(nBytes int) (uint64, error) {
+	const op = "ShiftBCD"
+	if nBytes < 1 || nBytes > maxBCDBytes {
+		return 0, u.traceError(&Error{Op: op, Offset: u.offset, Err: ErrInvalidWidth})
+	}
+	offset := u.offset
+	b, err := u.read(op, uint64(nBytes))
+	if err != nil {
+		return 0, err
+	}
+	v, err := decodeBCD(b)
+	if err != nil {
+		if ne, ok := err.(*NibbleError); ok {
+			offset += int64(ne.Index / 2)
+		}
+		return 0, u.traceError(&Error{Op: op, Offset: offset, Err: err})
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, b, v)
+	}
+	return v, nil
+}
+
+// FetchBCD read nBytes bytes of packed BCD, convert them to uint64 and set it
+// to v.
+func (u *Unpacker) FetchBCD(nBytes int, v *uint64) *Unpacker {
+	return u.errFilter(func() {
+		*v, u.err = u.ShiftBCD(nBytes)
+	})
+}
+
+// PushTBCD write a string of digits as TBCD into writer, as used for IMSIs
+// and phone numbers: two digits per byte, the first in the low nibble, with a
+// filler nibble 0xF after an odd number of digits. Besides 0 to 9, the digits
+// may be '*', '#', 'a', 'b' and 'c'; anything else is ErrInvalidDigit.
+func (p *Packer) PushTBCD(digits string) *Packer {
+	return p.errFilter(func() {
+		const op = "PushTBCD"
+		for i := 0; i < len(digits); i++ {
+			if strings.IndexByte(tbcdDigits, digits[i]) < 0 {
+				p.err = p.traceError(&Error{Op: op, Offset: p.offset + int64(i/2), Err: ErrInvalidDigit})
+				return
+			}
+		}
+		raw := p.writeBulk(op, (len(digits)+1)/2, func(b []byte) {
+			for i := range b {
+				lo, hi := byte(strings.IndexByte(tbcdDigits, digits[2*i])), byte(0x0F)
+				if 2*i+1 < len(digits) {
+					hi = byte(strings.IndexByte(tbcdDigits, digits[2*i+1]))
+				}
+				b[i] = hi<<4 | lo
+			}
+		})
+		if p.tracer != nil {
+			p.tracePushed(op, raw, digits)
+		}
+	})
+}
+
+// ShiftTBCD fetch n bytes of TBCD in io.Reader and convert them to a string of
+// digits, ending at the first filler nibble. Only filler may follow it; any
+// other nibble after it is a *NibbleError.
+func (u *Unpacker) ShiftTBCD(n uint64) (string, error) {
+	const op = "ShiftTBCD"
+	offset := u.offset
+	b, err := u.shiftBytes(op, n)
+	if err != nil {
+		return "", err
+	}
+	s, err := decodeTBCD(b)
+	if err != nil {
+		ne := err.(*NibbleError)
+		return "", u.traceError(&Error{Op: op, Offset: offset + int64(ne.Index/2), Err: err})
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, b, s)
+	}
+	return s, nil
+}
+
+// FetchTBCD read n bytes of TBCD, convert them to a string of digits and set
+// it to s.
+func (u *Unpacker) FetchTBCD(n uint64, s *string) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.ShiftTBCD(n)
+	})
+}
@@ -0,0 +1,128 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushBCD(t *testing.T) {
+	// The byte order must not matter.
+	for _, order := range bulkOrders {
+		p := NewAppendPacker(order, nil)
+		p.PushBCD(1234, 2).PushBCD(7, 3).PushBCD(math.MaxUint64, 10)
+		assert.Nil(t, p.Error(), "Has error.")
+		assert.Equal(t, p.Bytes(), []byte{
+			0x12, 0x34,
+			0x00, 0x00, 0x07,
+			0x18, 0x44, 0x67, 0x44, 0x07, 0x37, 0x09, 0x55, 0x16, 0x15,
+		}, "bcd error.")
+
+		for _, u := range []*Unpacker{
+			NewSliceUnpacker(order, p.Bytes()).Unpacker,
+			NewUnpacker(order, bytes.NewReader(p.Bytes())),
+		} {
+			var a, b uint64
+			u.FetchBCD(2, &a).FetchBCD(3, &b)
+			c, err := u.ShiftBCD(10)
+			assert.Nil(t, err, "Has error.")
+			assert.Nil(t, u.Error(), "Has error.")
+			assert.Equal(t, []uint64{a, b, c}, []uint64{1234, 7, math.MaxUint64}, "bcd error.")
+			_, err = u.ShiftBCD(1)
+			assert.True(t, errors.Is(err, io.EOF), "eof error.")
+		}
+	}
+}
+
+func TestBCDError(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushBCD(100, 1)
+	assert.True(t, errors.Is(p.Error(), ErrValueOverflow), "overflow error.")
+	assert.Equal(t, len(p.Bytes()), 0, "write error.")
+	for _, n := range []int{0, 11} {
+		p = NewAppendPacker(binary.BigEndian, nil)
+		p.PushBCD(0, n)
+		assert.True(t, errors.Is(p.Error(), ErrInvalidWidth), "width error.")
+		_, err := NewSliceUnpacker(binary.BigEndian, make([]byte, 16)).ShiftBCD(n)
+		assert.True(t, errors.Is(err, ErrInvalidWidth), "width error.")
+	}
+
+	u := NewSliceUnpacker(binary.BigEndian, []byte{0x12, 0x3A})
+	_, err := u.ShiftBCD(2)
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Op, "ShiftBCD", "op error.")
+	assert.Equal(t, e.Offset, int64(1), "offset error.")
+	var ne *NibbleError
+	assert.True(t, errors.As(err, &ne), "nibble error.")
+	assert.Equal(t, *ne, NibbleError{Nibble: 0xA, Index: 3}, "nibble error.")
+	assert.True(t, errors.Is(err, ErrInvalidNibble), "nibble error.")
+
+	// 20 digits can exceed a uint64.
+	u = NewSliceUnpacker(binary.BigEndian, []byte{0x18, 0x44, 0x67, 0x44, 0x07, 0x37, 0x09, 0x55, 0x16, 0x16})
+	_, err = u.ShiftBCD(10)
+	assert.True(t, errors.Is(err, ErrValueOverflow), "overflow error.")
+}
+
+func TestTBCD(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, buf)
+	p.PushTBCD("310150123456789").PushTBCD("*#12").PushTBCD("")
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, buf.Bytes(), []byte{
+		0x13, 0x10, 0x05, 0x21, 0x43, 0x65, 0x87, 0xF9,
+		0xBA, 0x21,
+	}, "tbcd error.")
+
+	u := NewUnpacker(binary.BigEndian, buf)
+	var s string
+	u.FetchTBCD(8, &s)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, s, "310150123456789", "tbcd error.")
+	s, err := u.ShiftTBCD(2)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, s, "*#12", "tbcd error.")
+
+	// A fixed-width field is padded with filler.
+	su := NewSliceUnpacker(binary.BigEndian, []byte{0x21, 0xF3, 0xFF, 0x21, 0xF3, 0x4F})
+	s, err = su.ShiftTBCD(3)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, s, "123", "filler error.")
+	_, err = su.ShiftTBCD(3)
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Offset, int64(5), "offset error.")
+	var ne *NibbleError
+	assert.True(t, errors.As(err, &ne), "nibble error.")
+	assert.Equal(t, *ne, NibbleError{Nibble: 4, Index: 5}, "nibble error.")
+
+	p = NewPacker(binary.BigEndian, buf)
+	p.PushTBCD("12-3")
+	assert.True(t, errors.As(p.Error(), &e), "error type error.")
+	assert.Equal(t, e.Err, ErrInvalidDigit, "digit error.")
+	assert.Equal(t, e.Offset, int64(1), "offset error.")
+}
+
+func TestTraceBCD(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushBCD(42, 2).PushTBCD("123")
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes()).WithTracer(tr)
+	u.FetchBCD(2, new(uint64)).FetchTBCD(2, new(string))
+	assert.Nil(t, u.Error(), "shift error.")
+	// A bad nibble is traced as an error only.
+	u = NewSliceUnpacker(binary.BigEndian, []byte{0x4A}).WithTracer(tr)
+	u.FetchBCD(1, new(uint64))
+	assert.Equal(t, tr.lines, []string{
+		"push PushBCD 0 00 42 42",
+		"push PushTBCD 2 21 f3 123",
+		"shift ShiftBCD 0 00 42 42",
+		"shift ShiftTBCD 2 21 f3 123",
+		"error ShiftBCD 0",
+	}, "trace error.")
+}
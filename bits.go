@@ -0,0 +1,202 @@
+package binpacker
+
+import "errors"
+
+// ErrBitCount is returned when a bit count is outside 0 to 64 or a value does
+// not fit in the given number of bits.
+var ErrBitCount = errors.New("binpacker: invalid bit count")
+
+// BitOrder is the order in which bits are packed into a byte.
+type BitOrder int
+
+const (
+	// MSBFirst packs bits from the most significant bit of each byte down, and
+	// writes the most significant bit of a value first.
+	MSBFirst BitOrder = iota
+	// LSBFirst packs bits from the least significant bit of each byte up, and
+	// writes the least significant bit of a value first.
+	LSBFirst
+)
+
+// BitPacker packs fields of any bit width into a Packer. Bits are collected
+// into bytes which are pushed to the Packer once complete; call AlignToByte to
+// push a partial byte before using the Packer directly again.
+type BitPacker struct {
+	packer *Packer
+	order  BitOrder
+	cur    byte
+	n      uint // number of bits in cur
+	err    error
+}
+
+// NewBitPacker returns a *BitPacker writing to p with the given bit order.
+func NewBitPacker(p *Packer, order BitOrder) *BitPacker {
+	return &BitPacker{
+		packer: p,
+		order:  order,
+	}
+}
+
+// Error returns an error if any errors exists
+func (b *BitPacker) Error() error {
+	return b.err
+}
+
+// PushBits write the lowest n bits of v.
+func (b *BitPacker) PushBits(v uint64, n int) *BitPacker {
+	return b.errFilter(func() {
+		if n < 0 || n > 64 || (n < 64 && v>>uint(n) != 0) {
+			b.err = ErrBitCount
+			return
+		}
+		for i := 0; i < n && b.err == nil; i++ {
+			var bit uint64
+			if b.order == MSBFirst {
+				bit = v >> uint(n-1-i) & 1
+			} else {
+				bit = v >> uint(i) & 1
+			}
+			b.pushBit(byte(bit))
+		}
+	})
+}
+
+// PushBool write a bool as a single bit.
+func (b *BitPacker) PushBool(v bool) *BitPacker {
+	return b.errFilter(func() {
+		if v {
+			b.pushBit(1)
+		} else {
+			b.pushBit(0)
+		}
+	})
+}
+
+// AlignToByte pads the current byte with zero bits and pushes it. It does
+// nothing if the bits written so far end on a byte boundary.
+func (b *BitPacker) AlignToByte() *BitPacker {
+	return b.errFilter(func() {
+		if b.n > 0 {
+			b.flush()
+		}
+	})
+}
+
+func (b *BitPacker) pushBit(bit byte) {
+	if b.order == MSBFirst {
+		b.cur |= bit << (7 - b.n)
+	} else {
+		b.cur |= bit << b.n
+	}
+	b.n++
+	if b.n == 8 {
+		b.flush()
+	}
+}
+
+func (b *BitPacker) flush() {
+	b.err = b.packer.PushByte(b.cur).Error()
+	b.cur, b.n = 0, 0
+}
+
+func (b *BitPacker) errFilter(f func()) *BitPacker {
+	if b.err == nil {
+		f()
+	}
+	return b
+}
+
+// BitUnpacker unpacks fields of any bit width from an Unpacker. Bytes are
+// shifted from the Unpacker as bits are needed; call AlignToByte to drop the
+// rest of a partially read byte before using the Unpacker directly again.
+type BitUnpacker struct {
+	unpacker *Unpacker
+	order    BitOrder
+	cur      byte
+	n        uint // number of unread bits in cur
+	err      error
+}
+
+// NewBitUnpacker returns a *BitUnpacker reading from u with the given bit
+// order.
+func NewBitUnpacker(u *Unpacker, order BitOrder) *BitUnpacker {
+	return &BitUnpacker{
+		unpacker: u,
+		order:    order,
+	}
+}
+
+// Error returns an error if any errors exists
+func (b *BitUnpacker) Error() error {
+	return b.err
+}
+
+// ShiftBits fetch n bits. Returns them as the lowest bits of a uint64 and an
+// error if exists.
+func (b *BitUnpacker) ShiftBits(n int) (uint64, error) {
+	if n < 0 || n > 64 {
+		return 0, ErrBitCount
+	}
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit, err := b.shiftBit()
+		if err != nil {
+			return 0, err
+		}
+		if b.order == MSBFirst {
+			v = v<<1 | uint64(bit)
+		} else {
+			v |= uint64(bit) << uint(i)
+		}
+	}
+	return v, nil
+}
+
+// FetchBits read n bits and set them to v.
+func (b *BitUnpacker) FetchBits(n int, v *uint64) *BitUnpacker {
+	return b.errFilter(func() {
+		*v, b.err = b.ShiftBits(n)
+	})
+}
+
+// ShiftBool fetch a single bit and convert it to bool.
+func (b *BitUnpacker) ShiftBool() (bool, error) {
+	bit, err := b.shiftBit()
+	return bit == 1, err
+}
+
+// FetchBool read a single bit, convert it to bool and set it to v.
+func (b *BitUnpacker) FetchBool(v *bool) *BitUnpacker {
+	return b.errFilter(func() {
+		*v, b.err = b.ShiftBool()
+	})
+}
+
+// AlignToByte drops the unread bits of the current byte.
+func (b *BitUnpacker) AlignToByte() *BitUnpacker {
+	return b.errFilter(func() {
+		b.cur, b.n = 0, 0
+	})
+}
+
+func (b *BitUnpacker) shiftBit() (byte, error) {
+	if b.n == 0 {
+		c, err := b.unpacker.ShiftByte()
+		if err != nil {
+			return 0, err
+		}
+		b.cur, b.n = c, 8
+	}
+	b.n--
+	if b.order == MSBFirst {
+		return b.cur >> b.n & 1, nil
+	}
+	return b.cur >> (7 - b.n) & 1, nil
+}
+
+func (b *BitUnpacker) errFilter(f func()) *BitUnpacker {
+	if b.err == nil {
+		f()
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushBitsMSBFirst(t *testing.T) {
+	buf := new(bytes.Buffer)
+	b := NewBitPacker(NewPacker(binary.BigEndian, buf), MSBFirst)
+	b.PushBits(0x5, 3).PushBits(0x1abc, 13).PushBool(true).AlignToByte()
+	assert.Equal(t, b.Error(), nil, "Has error.")
+	assert.Equal(t, buf.Bytes(), []byte{0xba, 0xbc, 0x80}, "bits error.")
+
+	var v uint64
+	var ok bool
+	u := NewBitUnpacker(NewUnpacker(binary.BigEndian, buf), MSBFirst)
+	u.FetchBits(3, &v)
+	assert.Equal(t, v, uint64(0x5), "bits error.")
+	u.FetchBits(13, &v)
+	assert.Equal(t, v, uint64(0x1abc), "bits error.")
+	u.FetchBool(&ok).AlignToByte()
+	assert.Equal(t, ok, true, "bool error.")
+	assert.Equal(t, u.Error(), nil, "Has error.")
+}
+
+func TestPushBitsLSBFirst(t *testing.T) {
+	buf := new(bytes.Buffer)
+	b := NewBitPacker(NewPacker(binary.BigEndian, buf), LSBFirst)
+	b.PushBits(0x5, 3).PushBits(0x1abc, 13).PushBool(true).AlignToByte()
+	assert.Equal(t, b.Error(), nil, "Has error.")
+	assert.Equal(t, buf.Bytes(), []byte{0xe5, 0xd5, 0x01}, "bits error.")
+
+	var v uint64
+	var ok bool
+	u := NewBitUnpacker(NewUnpacker(binary.BigEndian, buf), LSBFirst)
+	u.FetchBits(3, &v)
+	assert.Equal(t, v, uint64(0x5), "bits error.")
+	u.FetchBits(13, &v)
+	assert.Equal(t, v, uint64(0x1abc), "bits error.")
+	u.FetchBool(&ok)
+	assert.Equal(t, ok, true, "bool error.")
+	assert.Equal(t, u.Error(), nil, "Has error.")
+}
+
+func TestPushBits64(t *testing.T) {
+	buf := new(bytes.Buffer)
+	b := NewBitPacker(NewPacker(binary.BigEndian, buf), MSBFirst)
+	b.PushBits(1, 1).PushBits(0xfedcba9876543210, 64).AlignToByte()
+	assert.Equal(t, b.Error(), nil, "Has error.")
+
+	u := NewBitUnpacker(NewUnpacker(binary.BigEndian, buf), MSBFirst)
+	v, err := u.ShiftBits(1)
+	assert.Equal(t, err, nil, "Has error.")
+	assert.Equal(t, v, uint64(1), "bits error.")
+	v, err = u.ShiftBits(64)
+	assert.Equal(t, err, nil, "Has error.")
+	assert.Equal(t, v, uint64(0xfedcba9876543210), "bits error.")
+}
+
+func TestBitsErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+	b := NewBitPacker(NewPacker(binary.BigEndian, buf), MSBFirst)
+	b.PushBits(8, 3).PushBits(1, 1)
+	assert.Equal(t, b.Error(), ErrBitCount, "bit count error.")
+	assert.Equal(t, buf.Len(), 0, "bits error.")
+
+	var v uint64
+	u := NewBitUnpacker(NewUnpacker(binary.BigEndian, bytes.NewReader([]byte{0xff})), MSBFirst)
+	u.FetchBits(65, &v)
+	assert.Equal(t, u.Error(), ErrBitCount, "bit count error.")
+
+	u = NewBitUnpacker(NewUnpacker(binary.BigEndian, bytes.NewReader([]byte{0xff})), MSBFirst)
+	u.FetchBits(4, &v).AlignToByte().FetchBits(1, &v)
+	assert.ErrorIs(t, u.Error(), io.EOF, "EOF error.")
+}
@@ -0,0 +1,765 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/bits"
+	"unsafe"
+)
+
+// hostBigEndian reports whether the host stores integers big-endian.
+var hostBigEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 0
+}()
+
+var orderProbe = []byte{1, 2}
+
+// native reports whether order is the host's byte order, in which case slices
+// are copied as they are in memory.
+func native(order binary.ByteOrder) bool {
+	return bigEndian(order) == hostBigEndian
+}
+
+// writeBulk appends size bytes encoded by put, or writes them to the writer,
+// and returns them.
+func (p *Packer) writeBulk(op string, size int, put func(b []byte)) []byte {
+	if p.appending || p.holding {
+		start := len(p.buf)
+		p.buf = append(p.buf, make([]byte, size)...)
+		put(p.buf[start:])
+		p.offset += int64(size)
+		return p.buf[start:]
+	}
+	b := make([]byte, size)
+	put(b)
+	p.write(op, b)
+	return b
+}
+
+// pushCount writes the number of elements n as a width-byte prefix.
+func (p *Packer) pushCount(op string, width int, n int) {
+	switch {
+	case width != 1 && width != 2 && width != 4 && width != 8:
+		p.fail(op, ErrInvalidWidth)
+	case width < 8 && uint64(n) >= 1<<(8*uint(width)):
+		p.fail(op, ErrLengthOverflow)
+	default:
+		pushUint(p, width, uint64(n))
+	}
+}
+
+// bulkSize checks n elements of size bytes each against the limits and
+// returns their size in bytes.
+func (u *Unpacker) bulkSize(op string, n, size uint64) (uint64, error) {
+	if err := u.checkElements(n); err != nil {
+		return 0, u.traceError(&Error{Op: op, Offset: u.offset, Err: err})
+	}
+	want := n * size
+	if n > math.MaxUint64/size {
+		// Too much to ever be read; let the read report it.
+		want = math.MaxUint64
+	}
+	err := u.checkField(want)
+	if err == nil {
+		err = u.checkTotal(want)
+	}
+	if err != nil {
+		return 0, u.traceError(&Error{Op: op, Offset: u.offset, Want: want, Err: err})
+	}
+	return want, nil
+}
+
+// readBulk reads n elements of size bytes each for the operation op.
+func (u *Unpacker) readBulk(op string, n, size uint64) ([]byte, error) {
+	want, err := u.bulkSize(op, n, size)
+	if err != nil {
+		return nil, err
+	}
+	return u.read(op, want)
+}
+
+// readElements reads n elements of T for op from the reader straight into the
+// memory of s, reusing its capacity, and returns s and its memory, still in
+// the byte order of the input. Like read, it grows s at most readChunkSize
+// bytes at a time so that memory only grows as data actually arrives.
+func readElements[T Number](u *Unpacker, op string, n uint64, s []T) ([]T, []byte, error) {
+	size := uint64(unsafe.Sizeof(*new(T)))
+	offset := u.offset
+	want, err := u.bulkSize(op, n, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	s = s[:0]
+	var got uint64
+	for got < want && err == nil {
+		k := minUint64(n-uint64(len(s)), readChunkSize/size)
+		if uint64(cap(s)-len(s)) < k {
+			s = append(s, make([]T, k)...)
+		} else {
+			s = s[:uint64(len(s))+k]
+		}
+		chunk := elementBytes(s[uint64(len(s))-k:])
+		m := copy(chunk, u.pending)
+		u.pending = u.pending[m:]
+		var r int
+		r, err = io.ReadFull(u.reader, chunk[m:])
+		if err == io.EOF && got+uint64(m) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		got += uint64(m + r)
+	}
+	b := elementBytes(s)[:got]
+	for _, h := range u.hashes {
+		h.Write(b)
+	}
+	u.record(b)
+	u.offset += int64(got)
+	u.total += got
+	if err != nil {
+		return nil, nil, u.traceError(&Error{Op: op, Offset: offset, Want: want, Got: got, Err: err})
+	}
+	return s, b, nil
+}
+
+// elementBytes returns the memory of s as bytes.
+func elementBytes[T Number](s []T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(s))), uintptr(len(s))*unsafe.Sizeof(*new(T)))
+}
+
+// shiftCount reads the number of elements as a width-byte prefix.
+func (u *Unpacker) shiftCount(op string, width int) uint64 {
+	if width != 1 && width != 2 && width != 4 && width != 8 {
+		u.fail(op, ErrInvalidWidth)
+		return 0
+	}
+	return shiftUint(u, width)
+}
+
+// uint16Bytes returns the memory of s as bytes.
+func uint16Bytes(s []uint16) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), 2*len(s))
+}
+
+// putUint16s encodes s into b in order.
+func putUint16s(order binary.ByteOrder, b []byte, s []uint16) {
+	b = b[:2*len(s)]
+	switch {
+	case native(order):
+		copy(b, uint16Bytes(s))
+	case hostBigEndian:
+		for i, x := range s {
+			binary.LittleEndian.PutUint16(b[2*i:], x)
+		}
+	default:
+		for i, x := range s {
+			binary.BigEndian.PutUint16(b[2*i:], x)
+		}
+	}
+}
+
+// getUint16s decodes b in order into s.
+func getUint16s(order binary.ByteOrder, s []uint16, b []byte) {
+	b = b[:2*len(s)]
+	switch {
+	case native(order):
+		copy(uint16Bytes(s), b)
+	case hostBigEndian:
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	default:
+		for i := range s {
+			s[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+	}
+}
+
+// writeUint16s writes s for op and returns the bytes written.
+func (p *Packer) writeUint16s(op string, s []uint16) []byte {
+	if native(p.endian) {
+		p.write(op, uint16Bytes(s))
+		return uint16Bytes(s)
+	}
+	return p.writeBulk(op, 2*len(s), func(b []byte) {
+		putUint16s(p.endian, b, s)
+	})
+}
+
+// pushUint16s writes s, a slice of 2-byte Numbers, for op and traces it as
+// it is.
+func pushUint16s[T Number](p *Packer, op string, s []T) {
+	raw := p.writeUint16s(op, *(*[]uint16)(unsafe.Pointer(&s)))
+	if p.tracer != nil {
+		p.tracePushed(op, raw, s)
+	}
+}
+
+// shiftUint16s reads n elements of T, a 2-byte Number, into s, reusing its
+// capacity, and traces them. From a reader, the elements are read into s
+// directly and byte-swapped in place unless the byte order is the host's.
+func shiftUint16s[T Number](u *Unpacker, op string, n uint64, s []T) ([]T, error) {
+	if !u.slice {
+		s, b, err := readElements(u, op, n, s)
+		if err != nil {
+			return nil, err
+		}
+		raw := b
+		if !native(u.endian) {
+			if u.tracer != nil {
+				raw = append([]byte(nil), b...)
+			}
+			swapUint16s(*(*[]uint16)(unsafe.Pointer(&s)))
+		}
+		if u.tracer != nil {
+			u.traceShifted(op, raw, s)
+		}
+		return s, nil
+	}
+	b, err := u.readBulk(op, n, 2)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(cap(s)) < n {
+		s = make([]T, n)
+	}
+	s = s[:n]
+	getUint16s(u.endian, *(*[]uint16)(unsafe.Pointer(&s)), b)
+	if u.tracer != nil {
+		u.traceShifted(op, b, s)
+	}
+	return s, nil
+}
+
+// swapUint16s reverses the bytes of each element of s.
+func swapUint16s(s []uint16) {
+	for i, x := range s {
+		s[i] = bits.ReverseBytes16(x)
+	}
+}
+
+// uint32Bytes returns the memory of s as bytes.
+func uint32Bytes(s []uint32) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), 4*len(s))
+}
+
+// putUint32s encodes s into b in order.
+func putUint32s(order binary.ByteOrder, b []byte, s []uint32) {
+	b = b[:4*len(s)]
+	switch {
+	case native(order):
+		copy(b, uint32Bytes(s))
+	case hostBigEndian:
+		for i, x := range s {
+			binary.LittleEndian.PutUint32(b[4*i:], x)
+		}
+	default:
+		for i, x := range s {
+			binary.BigEndian.PutUint32(b[4*i:], x)
+		}
+	}
+}
+
+// getUint32s decodes b in order into s.
+func getUint32s(order binary.ByteOrder, s []uint32, b []byte) {
+	b = b[:4*len(s)]
+	switch {
+	case native(order):
+		copy(uint32Bytes(s), b)
+	case hostBigEndian:
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint32(b[4*i:])
+		}
+	default:
+		for i := range s {
+			s[i] = binary.BigEndian.Uint32(b[4*i:])
+		}
+	}
+}
+
+// writeUint32s writes s for op and returns the bytes written.
+func (p *Packer) writeUint32s(op string, s []uint32) []byte {
+	if native(p.endian) {
+		p.write(op, uint32Bytes(s))
+		return uint32Bytes(s)
+	}
+	return p.writeBulk(op, 4*len(s), func(b []byte) {
+		putUint32s(p.endian, b, s)
+	})
+}
+
+// pushUint32s writes s, a slice of 4-byte Numbers, for op and traces it as
+// it is.
+func pushUint32s[T Number](p *Packer, op string, s []T) {
+	raw := p.writeUint32s(op, *(*[]uint32)(unsafe.Pointer(&s)))
+	if p.tracer != nil {
+		p.tracePushed(op, raw, s)
+	}
+}
+
+// shiftUint32s reads n elements of T, a 4-byte Number, into s, reusing its
+// capacity, and traces them. From a reader, the elements are read into s
+// directly and byte-swapped in place unless the byte order is the host's.
+func shiftUint32s[T Number](u *Unpacker, op string, n uint64, s []T) ([]T, error) {
+	if !u.slice {
+		s, b, err := readElements(u, op, n, s)
+		if err != nil {
+			return nil, err
+		}
+		raw := b
+		if !native(u.endian) {
+			if u.tracer != nil {
+				raw = append([]byte(nil), b...)
+			}
+			swapUint32s(*(*[]uint32)(unsafe.Pointer(&s)))
+		}
+		if u.tracer != nil {
+			u.traceShifted(op, raw, s)
+		}
+		return s, nil
+	}
+	b, err := u.readBulk(op, n, 4)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(cap(s)) < n {
+		s = make([]T, n)
+	}
+	s = s[:n]
+	getUint32s(u.endian, *(*[]uint32)(unsafe.Pointer(&s)), b)
+	if u.tracer != nil {
+		u.traceShifted(op, b, s)
+	}
+	return s, nil
+}
+
+// swapUint32s reverses the bytes of each element of s.
+func swapUint32s(s []uint32) {
+	for i, x := range s {
+		s[i] = bits.ReverseBytes32(x)
+	}
+}
+
+// uint64Bytes returns the memory of s as bytes.
+func uint64Bytes(s []uint64) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), 8*len(s))
+}
+
+// putUint64s encodes s into b in order.
+func putUint64s(order binary.ByteOrder, b []byte, s []uint64) {
+	b = b[:8*len(s)]
+	switch {
+	case native(order):
+		copy(b, uint64Bytes(s))
+	case hostBigEndian:
+		for i, x := range s {
+			binary.LittleEndian.PutUint64(b[8*i:], x)
+		}
+	default:
+		for i, x := range s {
+			binary.BigEndian.PutUint64(b[8*i:], x)
+		}
+	}
+}
+
+// getUint64s decodes b in order into s.
+func getUint64s(order binary.ByteOrder, s []uint64, b []byte) {
+	b = b[:8*len(s)]
+	switch {
+	case native(order):
+		copy(uint64Bytes(s), b)
+	case hostBigEndian:
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint64(b[8*i:])
+		}
+	default:
+		for i := range s {
+			s[i] = binary.BigEndian.Uint64(b[8*i:])
+		}
+	}
+}
+
+// writeUint64s writes s for op and returns the bytes written.
+func (p *Packer) writeUint64s(op string, s []uint64) []byte {
+	if native(p.endian) {
+		p.write(op, uint64Bytes(s))
+		return uint64Bytes(s)
+	}
+	return p.writeBulk(op, 8*len(s), func(b []byte) {
+		putUint64s(p.endian, b, s)
+	})
+}
+
+// pushUint64s writes s, a slice of 8-byte Numbers, for op and traces it as
+// it is.
+func pushUint64s[T Number](p *Packer, op string, s []T) {
+	raw := p.writeUint64s(op, *(*[]uint64)(unsafe.Pointer(&s)))
+	if p.tracer != nil {
+		p.tracePushed(op, raw, s)
+	}
+}
+
+// shiftUint64s reads n elements of T, an 8-byte Number, into s, reusing its
+// capacity, and traces them. From a reader, the elements are read into s
+// directly and byte-swapped in place unless the byte order is the host's.
+func shiftUint64s[T Number](u *Unpacker, op string, n uint64, s []T) ([]T, error) {
+	if !u.slice {
+		s, b, err := readElements(u, op, n, s)
+		if err != nil {
+			return nil, err
+		}
+		raw := b
+		if !native(u.endian) {
+			if u.tracer != nil {
+				raw = append([]byte(nil), b...)
+			}
+			swapUint64s(*(*[]uint64)(unsafe.Pointer(&s)))
+		}
+		if u.tracer != nil {
+			u.traceShifted(op, raw, s)
+		}
+		return s, nil
+	}
+	b, err := u.readBulk(op, n, 8)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(cap(s)) < n {
+		s = make([]T, n)
+	}
+	s = s[:n]
+	getUint64s(u.endian, *(*[]uint64)(unsafe.Pointer(&s)), b)
+	if u.tracer != nil {
+		u.traceShifted(op, b, s)
+	}
+	return s, nil
+}
+
+// swapUint64s reverses the bytes of each element of s.
+func swapUint64s(s []uint64) {
+	for i, x := range s {
+		s[i] = bits.ReverseBytes64(x)
+	}
+}
+
+// PushUint16s write a slice of uint16 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushUint16s(s []uint16) *Packer {
+	return p.errFilter(func() {
+		pushUint16s(p, "PushUint16s", s)
+	})
+}
+
+// PushUint16sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushUint16sWithPrefix(width int, s []uint16) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushUint16sWithPrefix", width, len(s))
+		p.PushUint16s(s)
+	})
+}
+
+// ShiftUint16s fetch n uint16 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftUint16s(n uint64) ([]uint16, error) {
+	return shiftUint16s[uint16](u, "ShiftUint16s", n, nil)
+}
+
+// FetchUint16s read n uint16 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchUint16s(n uint64, s *[]uint16) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint16s(u, "ShiftUint16s", n, *s)
+	})
+}
+
+// FetchUint16sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchUint16sWithPrefix(width int, s *[]uint16) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchUint16sWithPrefix", width)
+		u.FetchUint16s(n, s)
+	})
+}
+
+// PushInt16s write a slice of int16 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushInt16s(s []int16) *Packer {
+	return p.errFilter(func() {
+		pushUint16s(p, "PushInt16s", s)
+	})
+}
+
+// PushInt16sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushInt16sWithPrefix(width int, s []int16) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushInt16sWithPrefix", width, len(s))
+		p.PushInt16s(s)
+	})
+}
+
+// ShiftInt16s fetch n int16 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftInt16s(n uint64) ([]int16, error) {
+	return shiftUint16s[int16](u, "ShiftInt16s", n, nil)
+}
+
+// FetchInt16s read n int16 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchInt16s(n uint64, s *[]int16) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint16s(u, "ShiftInt16s", n, *s)
+	})
+}
+
+// FetchInt16sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchInt16sWithPrefix(width int, s *[]int16) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchInt16sWithPrefix", width)
+		u.FetchInt16s(n, s)
+	})
+}
+
+// PushUint32s write a slice of uint32 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushUint32s(s []uint32) *Packer {
+	return p.errFilter(func() {
+		pushUint32s(p, "PushUint32s", s)
+	})
+}
+
+// PushUint32sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushUint32sWithPrefix(width int, s []uint32) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushUint32sWithPrefix", width, len(s))
+		p.PushUint32s(s)
+	})
+}
+
+// ShiftUint32s fetch n uint32 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftUint32s(n uint64) ([]uint32, error) {
+	return shiftUint32s[uint32](u, "ShiftUint32s", n, nil)
+}
+
+// FetchUint32s read n uint32 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchUint32s(n uint64, s *[]uint32) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint32s(u, "ShiftUint32s", n, *s)
+	})
+}
+
+// FetchUint32sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchUint32sWithPrefix(width int, s *[]uint32) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchUint32sWithPrefix", width)
+		u.FetchUint32s(n, s)
+	})
+}
+
+// PushInt32s write a slice of int32 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushInt32s(s []int32) *Packer {
+	return p.errFilter(func() {
+		pushUint32s(p, "PushInt32s", s)
+	})
+}
+
+// PushInt32sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushInt32sWithPrefix(width int, s []int32) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushInt32sWithPrefix", width, len(s))
+		p.PushInt32s(s)
+	})
+}
+
+// ShiftInt32s fetch n int32 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftInt32s(n uint64) ([]int32, error) {
+	return shiftUint32s[int32](u, "ShiftInt32s", n, nil)
+}
+
+// FetchInt32s read n int32 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchInt32s(n uint64, s *[]int32) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint32s(u, "ShiftInt32s", n, *s)
+	})
+}
+
+// FetchInt32sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchInt32sWithPrefix(width int, s *[]int32) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchInt32sWithPrefix", width)
+		u.FetchInt32s(n, s)
+	})
+}
+
+// PushUint64s write a slice of uint64 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushUint64s(s []uint64) *Packer {
+	return p.errFilter(func() {
+		pushUint64s(p, "PushUint64s", s)
+	})
+}
+
+// PushUint64sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushUint64sWithPrefix(width int, s []uint64) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushUint64sWithPrefix", width, len(s))
+		p.PushUint64s(s)
+	})
+}
+
+// ShiftUint64s fetch n uint64 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftUint64s(n uint64) ([]uint64, error) {
+	return shiftUint64s[uint64](u, "ShiftUint64s", n, nil)
+}
+
+// FetchUint64s read n uint64 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchUint64s(n uint64, s *[]uint64) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint64s(u, "ShiftUint64s", n, *s)
+	})
+}
+
+// FetchUint64sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchUint64sWithPrefix(width int, s *[]uint64) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchUint64sWithPrefix", width)
+		u.FetchUint64s(n, s)
+	})
+}
+
+// PushInt64s write a slice of int64 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushInt64s(s []int64) *Packer {
+	return p.errFilter(func() {
+		pushUint64s(p, "PushInt64s", s)
+	})
+}
+
+// PushInt64sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushInt64sWithPrefix(width int, s []int64) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushInt64sWithPrefix", width, len(s))
+		p.PushInt64s(s)
+	})
+}
+
+// ShiftInt64s fetch n int64 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftInt64s(n uint64) ([]int64, error) {
+	return shiftUint64s[int64](u, "ShiftInt64s", n, nil)
+}
+
+// FetchInt64s read n int64 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchInt64s(n uint64, s *[]int64) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint64s(u, "ShiftInt64s", n, *s)
+	})
+}
+
+// FetchInt64sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchInt64sWithPrefix(width int, s *[]int64) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchInt64sWithPrefix", width)
+		u.FetchInt64s(n, s)
+	})
+}
+
+// PushFloat32s write a slice of float32 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushFloat32s(s []float32) *Packer {
+	return p.errFilter(func() {
+		pushUint32s(p, "PushFloat32s", s)
+	})
+}
+
+// PushFloat32sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushFloat32sWithPrefix(width int, s []float32) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushFloat32sWithPrefix", width, len(s))
+		p.PushFloat32s(s)
+	})
+}
+
+// ShiftFloat32s fetch n float32 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftFloat32s(n uint64) ([]float32, error) {
+	return shiftUint32s[float32](u, "ShiftFloat32s", n, nil)
+}
+
+// FetchFloat32s read n float32 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchFloat32s(n uint64, s *[]float32) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint32s(u, "ShiftFloat32s", n, *s)
+	})
+}
+
+// FetchFloat32sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchFloat32sWithPrefix(width int, s *[]float32) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchFloat32sWithPrefix", width)
+		u.FetchFloat32s(n, s)
+	})
+}
+
+// PushFloat64s write a slice of float64 into writer, with a single copy if the
+// byte order is the host's.
+func (p *Packer) PushFloat64s(s []float64) *Packer {
+	return p.errFilter(func() {
+		pushUint64s(p, "PushFloat64s", s)
+	})
+}
+
+// PushFloat64sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s into writer.
+func (p *Packer) PushFloat64sWithPrefix(width int, s []float64) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushFloat64sWithPrefix", width, len(s))
+		p.PushFloat64s(s)
+	})
+}
+
+// ShiftFloat64s fetch n float64 values in io.Reader. Returns a slice and an
+// error if exists.
+func (u *Unpacker) ShiftFloat64s(n uint64) ([]float64, error) {
+	return shiftUint64s[float64](u, "ShiftFloat64s", n, nil)
+}
+
+// FetchFloat64s read n float64 values and set them to s, reusing its capacity.
+func (u *Unpacker) FetchFloat64s(n uint64, s *[]float64) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = shiftUint64s(u, "ShiftFloat64s", n, *s)
+	})
+}
+
+// FetchFloat64sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the elements, and set them to s.
+func (u *Unpacker) FetchFloat64sWithPrefix(width int, s *[]float64) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchFloat64sWithPrefix", width)
+		u.FetchFloat64s(n, s)
+	})
+}
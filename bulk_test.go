@@ -0,0 +1,296 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var bulkOrders = []binary.ByteOrder{binary.BigEndian, binary.LittleEndian}
+
+func TestPushSlices(t *testing.T) {
+	for _, order := range bulkOrders {
+		u16 := []uint16{0, 1, 0x0102, math.MaxUint16}
+		i16 := []int16{0, -1, math.MinInt16, math.MaxInt16}
+		u32 := []uint32{0, 1, 0x01020304, math.MaxUint32}
+		i32 := []int32{0, -1, math.MinInt32, math.MaxInt32}
+		u64 := []uint64{0, 1, 0x0102030405060708, math.MaxUint64}
+		i64 := []int64{0, -1, math.MinInt64, math.MaxInt64}
+		f32 := []float32{0, -1.5, math.MaxFloat32, float32(math.Inf(1))}
+		f64 := []float64{0, -1.5, math.MaxFloat64, math.Inf(-1)}
+
+		// The bulk methods must write what the per-element ones write.
+		want := NewAppendPacker(order, nil)
+		for _, x := range u16 {
+			want.PushUint16(x)
+		}
+		for _, x := range i16 {
+			want.PushInt16(x)
+		}
+		for _, x := range u32 {
+			want.PushUint32(x)
+		}
+		for _, x := range i32 {
+			want.PushInt32(x)
+		}
+		for _, x := range u64 {
+			want.PushUint64(x)
+		}
+		for _, x := range i64 {
+			want.PushInt64(x)
+		}
+		for _, x := range f32 {
+			want.PushFloat32(x)
+		}
+		for _, x := range f64 {
+			want.PushFloat64(x)
+		}
+
+		buf := new(bytes.Buffer)
+		for _, p := range []*Packer{NewAppendPacker(order, nil), NewPacker(order, buf)} {
+			p.PushUint16s(u16).PushInt16s(i16).
+				PushUint32s(u32).PushInt32s(i32).
+				PushUint64s(u64).PushInt64s(i64).
+				PushFloat32s(f32).PushFloat64s(f64)
+			assert.Nil(t, p.Error(), "Has error.")
+		}
+		assert.Equal(t, buf.Bytes(), want.Bytes(), "writer error.")
+
+		for _, u := range []*Unpacker{NewSliceUnpacker(order, want.Bytes()).Unpacker, NewUnpacker(order, buf)} {
+			var (
+				gu16 []uint16
+				gi16 []int16
+				gu32 []uint32
+				gi32 []int32
+				gu64 []uint64
+				gi64 []int64
+				gf32 []float32
+			)
+			u.FetchUint16s(4, &gu16).FetchInt16s(4, &gi16).
+				FetchUint32s(4, &gu32).FetchInt32s(4, &gi32).
+				FetchUint64s(4, &gu64).FetchInt64s(4, &gi64).
+				FetchFloat32s(4, &gf32)
+			gf64, err := u.ShiftFloat64s(4)
+			assert.Nil(t, err, "Has error.")
+			assert.Nil(t, u.Error(), "Has error.")
+			assert.Equal(t, gu16, u16, "uint16s error.")
+			assert.Equal(t, gi16, i16, "int16s error.")
+			assert.Equal(t, gu32, u32, "uint32s error.")
+			assert.Equal(t, gi32, i32, "int32s error.")
+			assert.Equal(t, gu64, u64, "uint64s error.")
+			assert.Equal(t, gi64, i64, "int64s error.")
+			assert.Equal(t, gf32, f32, "float32s error.")
+			assert.Equal(t, gf64, f64, "float64s error.")
+		}
+	}
+}
+
+func TestShiftSlices(t *testing.T) {
+	data := []byte{0, 1, 0, 2, 0, 3}
+	u := NewSliceUnpacker(binary.BigEndian, data)
+	s, err := u.ShiftInt16s(2)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, s, []int16{1, 2}, "int16s error.")
+	// The result must not alias the input.
+	data[1] = 9
+	assert.Equal(t, s, []int16{1, 2}, "alias error.")
+
+	// Fetch reuses the capacity of the slice.
+	buf := make([]uint16, 0, 8)
+	u = NewSliceUnpacker(binary.BigEndian, data)
+	u.FetchUint16s(3, &buf)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, buf, []uint16{9, 2, 3}, "uint16s error.")
+	assert.Equal(t, cap(buf), 8, "capacity error.")
+
+	u = NewSliceUnpacker(binary.BigEndian, data)
+	u.FetchUint16s(0, &buf)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, buf, []uint16{}, "empty error.")
+
+	u = NewSliceUnpacker(binary.BigEndian, data)
+	_, err = u.ShiftUint32s(2)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+
+	u = NewSliceUnpacker(binary.BigEndian, data)
+	_, err = u.ShiftUint64s(math.MaxUint64)
+	var e *Error
+	assert.True(t, errors.As(err, &e), "overflow error.")
+	assert.Equal(t, e.Op, "ShiftUint64s", "op error.")
+
+	lu := NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(data), UnpackerOptions{MaxElements: 2})
+	_, err = lu.ShiftUint16s(3)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), "limit error.")
+}
+
+func TestShiftSlicesReader(t *testing.T) {
+	for _, order := range bulkOrders {
+		p := NewAppendPacker(order, nil)
+		p.PushUint32s([]uint32{1, 0x01020304, math.MaxUint32})
+		data := p.Bytes()
+
+		// Peeked bytes are read before the rest from the reader.
+		tr := &recordTracer{}
+		u := NewUnpacker(order, bytes.NewReader(data)).WithTracer(tr)
+		u.PeekUint8()
+		buf := make([]uint32, 0, 8)
+		u.FetchUint32s(3, &buf)
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, buf, []uint32{1, 0x01020304, math.MaxUint32}, "uint32s error.")
+		assert.Equal(t, cap(buf), 8, "capacity error.")
+		assert.Equal(t, u.Offset(), int64(12), "offset error.")
+		assert.Equal(t, tr.lines[len(tr.lines)-1], fmt.Sprintf("shift ShiftUint32s 0 % x %v", data, buf), "trace error.")
+
+		r := bytes.NewReader(data)
+		u = NewUnpacker(order, r)
+		allocs := testing.AllocsPerRun(100, func() {
+			r.Seek(0, io.SeekStart)
+			u.FetchUint32s(3, &buf)
+		})
+		assert.Equal(t, allocs, float64(0), "allocs error.")
+
+		u = NewUnpacker(order, bytes.NewReader(data[:10]))
+		_, err := u.ShiftInt32s(3)
+		var e *Error
+		assert.True(t, errors.As(err, &e), "short error.")
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+		assert.Equal(t, e.Got, uint64(10), "got error.")
+		assert.Equal(t, u.Offset(), int64(10), "offset error.")
+	}
+
+	// A large count only grows the slice as the data arrives.
+	u := NewUnpacker(binary.BigEndian, bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 1}))
+	_, err := u.ShiftUint64s(1 << 40)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+}
+
+func TestSlicesWithPrefix(t *testing.T) {
+	p := NewAppendPacker(binary.LittleEndian, nil)
+	p.PushUint32sWithPrefix(1, []uint32{1, 2}).PushFloat64sWithPrefix(2, []float64{1.5})
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{
+		2, 1, 0, 0, 0, 2, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0, 0xF8, 0x3F,
+	}, "prefix error.")
+
+	var a []uint32
+	var b []float64
+	u := NewSliceUnpacker(binary.LittleEndian, p.Bytes())
+	u.FetchUint32sWithPrefix(1, &a).FetchFloat64sWithPrefix(2, &b)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, a, []uint32{1, 2}, "uint32s error.")
+	assert.Equal(t, b, []float64{1.5}, "float64s error.")
+
+	p = NewAppendPacker(binary.LittleEndian, nil)
+	p.PushUint16sWithPrefix(1, make([]uint16, 256))
+	assert.True(t, errors.Is(p.Error(), ErrLengthOverflow), "overflow error.")
+	assert.Equal(t, len(p.Bytes()), 0, "overflow error.")
+
+	p = NewAppendPacker(binary.LittleEndian, nil)
+	p.PushInt64sWithPrefix(3, nil)
+	assert.True(t, errors.Is(p.Error(), ErrInvalidWidth), "width error.")
+
+	u = NewSliceUnpacker(binary.LittleEndian, []byte{1, 0})
+	u.FetchInt16sWithPrefix(3, new([]int16))
+	assert.True(t, errors.Is(u.Error(), ErrInvalidWidth), "width error.")
+}
+
+func TestTraceSlices(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.LittleEndian, nil).WithTracer(tr)
+	p.PushInt16sWithPrefix(1, []int16{-1, 2}).PushFloat32s([]float32{1.5})
+	u := NewSliceUnpacker(binary.BigEndian, []byte{0, 1, 0, 2}).WithTracer(tr)
+	u.ShiftUint16s(2)
+	assert.Equal(t, tr.lines, []string{
+		"push PushUint8 0 02 2",
+		"push PushInt16s 1 ff ff 02 00 [-1 2]",
+		"push PushFloat32s 5 00 00 c0 3f [1.5]",
+		"shift ShiftUint16s 0 00 01 00 02 [1 2]",
+	}, "trace error.")
+}
+
+func TestPushSlicesAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations differ with the race detector")
+	}
+	s := make([]float32, 256)
+	for _, order := range bulkOrders {
+		p := NewAppendPacker(order, make([]byte, 0, 4096))
+		allocs := testing.AllocsPerRun(100, func() {
+			p.Reset()
+			p.PushFloat32s(s)
+		})
+		assert.Equal(t, allocs, float64(0), "allocs error.")
+	}
+}
+
+func benchmarkPushFloat32s(b *testing.B, order binary.ByteOrder, bulk bool) {
+	s := make([]float32, 4096)
+	for i := range s {
+		s[i] = float32(i)
+	}
+	p := NewAppendPacker(order, make([]byte, 0, 4*len(s)))
+	b.SetBytes(int64(4 * len(s)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Reset()
+		if bulk {
+			p.PushFloat32s(s)
+			continue
+		}
+		for _, x := range s {
+			p.PushFloat32(x)
+		}
+	}
+}
+
+// The Native benchmarks assume a little-endian host.
+
+func BenchmarkPushFloat32sNative(b *testing.B) {
+	benchmarkPushFloat32s(b, binary.LittleEndian, true)
+}
+
+func BenchmarkPushFloat32sSwapped(b *testing.B) {
+	benchmarkPushFloat32s(b, binary.BigEndian, true)
+}
+
+func BenchmarkPushFloat32Loop(b *testing.B) {
+	benchmarkPushFloat32s(b, binary.BigEndian, false)
+}
+
+func benchmarkFetchFloat32s(b *testing.B, order binary.ByteOrder, bulk bool) {
+	const n = 4096
+	data := make([]byte, 4*n)
+	s := make([]float32, n)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	u := NewSliceUnpacker(order, data)
+	for i := 0; i < b.N; i++ {
+		u.Seek(0, io.SeekStart)
+		if bulk {
+			u.FetchFloat32s(n, &s)
+			continue
+		}
+		for j := range s {
+			u.FetchFloat32(&s[j])
+		}
+	}
+}
+
+func BenchmarkFetchFloat32sNative(b *testing.B) {
+	benchmarkFetchFloat32s(b, binary.LittleEndian, true)
+}
+
+func BenchmarkFetchFloat32sSwapped(b *testing.B) {
+	benchmarkFetchFloat32s(b, binary.BigEndian, true)
+}
+
+func BenchmarkFetchFloat32Loop(b *testing.B) {
+	benchmarkFetchFloat32s(b, binary.BigEndian, false)
+}
@@ -0,0 +1,320 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"math/bits"
+)
+
+// ErrChecksumMismatch is matched by errors.Is for every *ChecksumError.
+var ErrChecksumMismatch = errors.New("binpacker: checksum mismatch")
+
+// ChecksumError is returned when a checksum read by VerifyChecksummed does not
+// match the data before it.
+type ChecksumError struct {
+	Expected uint64 // checksum read from the input
+	Actual   uint64 // checksum computed over the data
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("binpacker: checksum mismatch: expected %#x, actual %#x", e.Expected, e.Actual)
+}
+
+// Is reports whether target is ErrChecksumMismatch.
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// Checksum is a checksum algorithm for Checksummed and VerifyChecksummed. The
+// checksum is written as a Size byte unsigned integer in the byte order of the
+// Packer. Checksummed and VerifyChecksummed fail with ErrInvalidWidth for any
+// other Size.
+type Checksum struct {
+	Size int // 1, 2, 4 or 8
+	// New returns a new hash whose Sum appends Size bytes in big endian
+	// order, as hash.Hash32 and hash.Hash64 do.
+	New func() hash.Hash
+}
+
+func (c Checksum) validSize() bool {
+	return c.Size == 1 || c.Size == 2 || c.Size == 4 || c.Size == 8
+}
+
+// Hash32 returns a 4 byte Checksum computed by the hashes f returns.
+func Hash32(f func() hash.Hash32) Checksum {
+	return Checksum{Size: 4, New: func() hash.Hash { return f() }}
+}
+
+// Hash64 returns an 8 byte Checksum computed by the hashes f returns.
+func Hash64(f func() hash.Hash64) Checksum {
+	return Checksum{Size: 8, New: func() hash.Hash { return f() }}
+}
+
+var (
+	// CRC32 is the IEEE CRC-32 used by Ethernet, gzip and PNG.
+	CRC32 = Hash32(crc32.NewIEEE)
+	// CRC32C is the Castagnoli CRC-32 used by iSCSI and SCTP.
+	CRC32C = Hash32(func() hash.Hash32 { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) })
+	// CRC16 is CRC-16/ARC, also known as CRC-16/IBM.
+	CRC16 = Checksum{Size: 2, New: func() hash.Hash { return newCRC16(&crc16ARC) }}
+	// CRC16Modbus is CRC-16/MODBUS.
+	CRC16Modbus = Checksum{Size: 2, New: func() hash.Hash { return newCRC16(&crc16Modbus) }}
+	// CRC16CCITT is CRC-16/CCITT-FALSE.
+	CRC16CCITT = Checksum{Size: 2, New: func() hash.Hash { return newCRC16(&crc16CCITT) }}
+	// Adler32 is the Adler-32 checksum used by zlib.
+	Adler32 = Hash32(adler32.New)
+	// XXH64 is the 64 bit xxHash with seed 0.
+	XXH64 = Hash64(func() hash.Hash64 { return NewXXH64(0) })
+)
+
+// Checksummed runs f and then writes the checksum c over exactly the bytes f
+// wrote. The section is held in memory until f returns, so that placeholders
+// reserved inside f are filled before the checksum is computed; they must be
+// filled before f returns.
+func (p *Packer) Checksummed(c Checksum, f func(*Packer)) *Packer {
+	return p.errFilter(func() {
+		if !c.validSize() {
+			p.fail("Checksummed", ErrInvalidWidth)
+			return
+		}
+		p.traceEnter("Checksummed")
+		defer p.traceLeave("Checksummed")
+		if !p.appending {
+			p.holding = true
+		}
+		p.sections++
+		start := len(p.buf)
+		f(p)
+		p.sections--
+		if p.err != nil {
+			return
+		}
+		h := c.New()
+		h.Write(p.buf[start:])
+		pushUint(p, c.Size, sumUint(h.Sum(p.scratch[:0])))
+		if p.holding && p.sections == 0 && len(p.reserved) == 0 && p.err == nil {
+			p.flush()
+		}
+	})
+}
+
+// VerifyChecksummed runs f and then reads a checksum c and compares it with
+// the checksum over exactly the bytes f read. A mismatch is returned as a
+// *ChecksumError.
+func (u *Unpacker) VerifyChecksummed(c Checksum, f func(*Unpacker)) error {
+	u.errFilter(func() {
+		if !c.validSize() {
+			u.fail("VerifyChecksummed", ErrInvalidWidth)
+			return
+		}
+		u.traceEnter("VerifyChecksummed")
+		defer u.traceLeave("VerifyChecksummed")
+		h := c.New()
+		u.hashes = append(u.hashes, h)
+		f(u)
+		u.hashes = u.hashes[:len(u.hashes)-1]
+		if u.err != nil {
+			return
+		}
+		actual := sumUint(h.Sum(u.scratch[:0]))
+		offset := u.offset
+		expected := shiftUint(u, c.Size)
+		if u.err == nil && expected != actual {
+			u.err = u.traceError(&Error{Op: "VerifyChecksummed", Offset: offset, Err: &ChecksumError{Expected: expected, Actual: actual}})
+		}
+	})
+	return u.err
+}
+
+// sumUint decodes a big endian hash sum.
+func sumUint(b []byte) uint64 {
+	var x uint64
+	for _, c := range b {
+		x = x<<8 | uint64(c)
+	}
+	return x
+}
+
+type crc16Params struct {
+	poly      uint16
+	init      uint16
+	reflected bool
+	table     [256]uint16
+}
+
+var (
+	crc16ARC    = crc16Params{poly: 0xA001, reflected: true}
+	crc16Modbus = crc16Params{poly: 0xA001, init: 0xFFFF, reflected: true}
+	crc16CCITT  = crc16Params{poly: 0x1021, init: 0xFFFF}
+)
+
+func init() {
+	for _, params := range []*crc16Params{&crc16ARC, &crc16Modbus, &crc16CCITT} {
+		for i := range params.table {
+			crc := uint16(i)
+			if params.reflected {
+				for j := 0; j < 8; j++ {
+					if crc&1 == 1 {
+						crc = crc>>1 ^ params.poly
+					} else {
+						crc >>= 1
+					}
+				}
+			} else {
+				crc <<= 8
+				for j := 0; j < 8; j++ {
+					if crc&0x8000 != 0 {
+						crc = crc<<1 ^ params.poly
+					} else {
+						crc <<= 1
+					}
+				}
+			}
+			params.table[i] = crc
+		}
+	}
+}
+
+// crc16 is a table driven CRC-16 implementing hash.Hash.
+type crc16 struct {
+	params *crc16Params
+	crc    uint16
+}
+
+func newCRC16(params *crc16Params) *crc16 {
+	return &crc16{params: params, crc: params.init}
+}
+
+func (c *crc16) Write(p []byte) (int, error) {
+	crc := c.crc
+	if c.params.reflected {
+		for _, b := range p {
+			crc = crc>>8 ^ c.params.table[byte(crc)^b]
+		}
+	} else {
+		for _, b := range p {
+			crc = crc<<8 ^ c.params.table[byte(crc>>8)^b]
+		}
+	}
+	c.crc = crc
+	return len(p), nil
+}
+
+func (c *crc16) Sum(b []byte) []byte {
+	return append(b, byte(c.crc>>8), byte(c.crc))
+}
+
+func (c *crc16) Reset()         { c.crc = c.params.init }
+func (c *crc16) Size() int      { return 2 }
+func (c *crc16) BlockSize() int { return 1 }
+
+const (
+	xxhPrime1 uint64 = 0x9E3779B185EBCA87
+	xxhPrime2 uint64 = 0xC2B2AE3D27D4EB4F
+	xxhPrime3 uint64 = 0x165667B19E3779F9
+	xxhPrime4 uint64 = 0x85EBCA77C2B2AE63
+	xxhPrime5 uint64 = 0x27D4EB2F165667C5
+)
+
+// xxh64 is the 64 bit xxHash.
+type xxh64 struct {
+	seed  uint64
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int // bytes in mem
+}
+
+// NewXXH64 returns a 64 bit xxHash with the given seed.
+func NewXXH64(seed uint64) hash.Hash64 {
+	x := &xxh64{seed: seed}
+	x.Reset()
+	return x
+}
+
+func (x *xxh64) Reset() {
+	x.v = [4]uint64{x.seed + xxhPrime1 + xxhPrime2, x.seed + xxhPrime2, x.seed, x.seed - xxhPrime1}
+	x.total = 0
+	x.n = 0
+}
+
+func (x *xxh64) Size() int      { return 8 }
+func (x *xxh64) BlockSize() int { return 32 }
+
+func (x *xxh64) Write(p []byte) (int, error) {
+	n := len(p)
+	x.total += uint64(n)
+	if x.n+len(p) < 32 {
+		x.n += copy(x.mem[x.n:], p)
+		return n, nil
+	}
+	if x.n > 0 {
+		c := copy(x.mem[x.n:], p)
+		x.block(x.mem[:])
+		p = p[c:]
+		x.n = 0
+	}
+	for ; len(p) >= 32; p = p[32:] {
+		x.block(p)
+	}
+	x.n = copy(x.mem[:], p)
+	return n, nil
+}
+
+func (x *xxh64) block(p []byte) {
+	for i := range x.v {
+		x.v[i] = xxhRound(x.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+	}
+}
+
+func (x *xxh64) Sum(b []byte) []byte {
+	s := x.Sum64()
+	return append(b, byte(s>>56), byte(s>>48), byte(s>>40), byte(s>>32), byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
+
+func (x *xxh64) Sum64() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		v := x.v
+		h = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) + bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, vi := range v {
+			h ^= xxhRound(0, vi)
+			h = h*xxhPrime1 + xxhPrime4
+		}
+	} else {
+		h = x.seed + xxhPrime5
+	}
+	h += x.total
+
+	p := x.mem[:x.n]
+	for ; len(p) >= 8; p = p[8:] {
+		h ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * xxhPrime1
+		h = bits.RotateLeft64(h, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, c := range p {
+		h ^= uint64(c) * xxhPrime5
+		h = bits.RotateLeft64(h, 11) * xxhPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxhPrime2
+	h ^= h >> 29
+	h *= xxhPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
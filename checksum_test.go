@@ -0,0 +1,158 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/fnv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumVectors(t *testing.T) {
+	data := []byte("123456789")
+	for name, c := range map[string]struct {
+		checksum Checksum
+		want     uint64
+	}{
+		"CRC32":       {CRC32, 0xCBF43926},
+		"CRC32C":      {CRC32C, 0xE3069283},
+		"CRC16":       {CRC16, 0xBB3D},
+		"CRC16Modbus": {CRC16Modbus, 0x4B37},
+		"CRC16CCITT":  {CRC16CCITT, 0x29B1},
+		"Adler32":     {Adler32, 0x091E01DE},
+		"XXH64":       {XXH64, 0x8CB841DB40E6AE83},
+	} {
+		h := c.checksum.New()
+		h.Write(data[:4])
+		h.Write(data[4:])
+		assert.Equal(t, sumUint(h.Sum(nil)), c.want, name+" error.")
+	}
+}
+
+func TestXXH64(t *testing.T) {
+	for s, want := range map[string]uint64{
+		"":    0xEF46DB3751D8E999,
+		"a":   0xD24EC4F1A98C6E5B,
+		"abc": 0x44BC2CF5AD770999,
+	} {
+		h := NewXXH64(0)
+		h.Write([]byte(s))
+		assert.Equal(t, h.Sum64(), want, "xxh64 error.")
+	}
+
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	h := NewXXH64(0)
+	h.Write(data)
+	want := h.Sum64()
+	for split := range data {
+		h.Reset()
+		h.Write(data[:split])
+		h.Write(data[split:])
+		assert.Equal(t, h.Sum64(), want, "xxh64 split error.")
+	}
+}
+
+func TestChecksummed(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, buf)
+	p.PushByte(0xEE).Checksummed(CRC32, func(p *Packer) {
+		p.LengthPrefixed(1, func(p *Packer) {
+			p.PushString("123456789")
+		})
+	}).PushByte(0xFF)
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, buf.Bytes(), []byte{
+		0xEE,
+		9, '1', '2', '3', '4', '5', '6', '7', '8', '9',
+		0x32, 0x62, 0x6E, 0x34,
+		0xFF,
+	}, "checksummed error.")
+
+	u := NewUnpacker(binary.BigEndian, bytes.NewReader(buf.Bytes()))
+	var s string
+	u.Skip(1)
+	err := u.VerifyChecksummed(CRC32, func(u *Unpacker) {
+		u.StringWithUint8Prefix(&s)
+	})
+	assert.Equal(t, err, nil, "Has error.")
+	assert.Equal(t, s, "123456789", "string error.")
+	b, _ := u.ShiftByte()
+	assert.Equal(t, b, byte(0xFF), "byte error.")
+
+	a := NewAppendPacker(binary.LittleEndian, nil)
+	a.Checksummed(CRC16, func(p *Packer) {
+		p.PushString("123456789")
+	})
+	assert.Equal(t, a.Bytes(), []byte("123456789\x3D\xBB"), "append error.")
+}
+
+func TestChecksummedNested(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.Checksummed(XXH64, func(p *Packer) {
+		p.PushUint16(1).Checksummed(Adler32, func(p *Packer) {
+			p.PushUint32(2)
+		})
+	})
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, len(p.Bytes()), 2+4+4+8, "length error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes())
+	var i16 uint16
+	err := u.VerifyChecksummed(XXH64, func(u *Unpacker) {
+		u.FetchUint16(&i16).VerifyChecksummed(Adler32, func(u *Unpacker) {
+			u.Skip(2).FetchUint16(&i16)
+		})
+	})
+	assert.Equal(t, err, nil, "Has error.")
+	assert.Equal(t, i16, uint16(2), "uint16 error.")
+	assert.Equal(t, u.Len(), 0, "len error.")
+}
+
+func TestVerifyChecksummedMismatch(t *testing.T) {
+	data := []byte("123456789\x00\x00\x00\x01")
+	u := NewUnpacker(binary.BigEndian, bytes.NewReader(data))
+	err := u.VerifyChecksummed(CRC32, func(u *Unpacker) {
+		u.Skip(9)
+	})
+	assert.ErrorIs(t, err, ErrChecksumMismatch, "mismatch error.")
+	var ce *ChecksumError
+	assert.True(t, errors.As(err, &ce), "error type error.")
+	assert.Equal(t, ce, &ChecksumError{Expected: 1, Actual: 0xCBF43926}, "checksum error.")
+	assert.Equal(t, u.Error(), err, "sticky error.")
+}
+
+func TestChecksumCustomHash(t *testing.T) {
+	fnv64 := Hash64(func() hash.Hash64 { return fnv.New64a() })
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.Checksummed(fnv64, func(p *Packer) {
+		p.PushString("binpacker")
+	})
+	h := fnv.New64a()
+	h.Write([]byte("binpacker"))
+	assert.Equal(t, p.Bytes(), h.Sum([]byte("binpacker")), "custom hash error.")
+}
+
+func TestChecksumInvalidSize(t *testing.T) {
+	for _, size := range []int{0, 3, 16} {
+		c := Checksum{Size: size, New: CRC32.New}
+		called := false
+		p := NewAppendPacker(binary.BigEndian, nil)
+		p.Checksummed(c, func(p *Packer) {
+			called = true
+		})
+		assert.ErrorIs(t, p.Error(), ErrInvalidWidth, "width error.")
+
+		u := NewSliceUnpacker(binary.BigEndian, make([]byte, 16))
+		err := u.VerifyChecksummed(c, func(u *Unpacker) {
+			called = true
+		})
+		assert.ErrorIs(t, err, ErrInvalidWidth, "width error.")
+		assert.Equal(t, called, false, "call error.")
+	}
+}
@@ -0,0 +1,719 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/zhuangsirui/binpacker/internal/bintag"
+)
+
+// annotation marks the struct types to generate methods for.
+const annotation = "//binpacker:generate"
+
+// structType is a struct type declaration found in the source.
+type structType struct {
+	name   string
+	fields []field
+	decls  map[string]ast.Expr // the type declarations of the package, by name
+}
+
+type field struct {
+	name string
+	expr ast.Expr
+	tag  bintag.Tag
+}
+
+// findTypes returns the struct types declared in files, in source order. If
+// names is empty the types annotated with //binpacker:generate are returned,
+// otherwise the types with the given names.
+func findTypes(files []*ast.File, names []string) ([]structType, error) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var found []structType
+	decls := map[string]ast.Expr{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				decls[ts.Name.Name] = ts.Type
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				if len(names) > 0 && !wanted[ts.Name.Name] {
+					continue
+				}
+				if len(names) == 0 && !annotated(gd.Doc) && !annotated(ts.Doc) {
+					continue
+				}
+				delete(wanted, ts.Name.Name)
+				t, err := newStructType(ts.Name.Name, st)
+				if err != nil {
+					return nil, err
+				}
+				t.decls = decls
+				found = append(found, t)
+			}
+		}
+	}
+	for name := range wanted {
+		return nil, fmt.Errorf("struct type %s not found", name)
+	}
+	return found, nil
+}
+
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+func newStructType(name string, st *ast.StructType) (structType, error) {
+	t := structType{name: name}
+	for _, f := range st.Fields.List {
+		var tg bintag.Tag
+		var err error
+		if f.Tag != nil {
+			s, _ := strconv.Unquote(f.Tag.Value)
+			tg, err = bintag.Parse(reflect.StructTag(s).Get("bin"))
+		} else {
+			tg, err = bintag.Parse("")
+		}
+		if err != nil {
+			return t, fmt.Errorf("%s: %v", name, err)
+		}
+		if tg.Skip {
+			continue
+		}
+		if len(f.Names) == 0 {
+			return t, fmt.Errorf("%s: embedded fields are not supported", name)
+		}
+		for _, n := range f.Names {
+			if !n.IsExported() {
+				continue
+			}
+			t.fields = append(t.fields, field{name: n.Name, expr: f.Type, tag: tg})
+		}
+	}
+	return t, nil
+}
+
+type valueKind int
+
+const (
+	scalarKind valueKind = iota
+	stringKind
+	bytesKind
+	byteArrayKind
+	sliceKind
+	arrayKind
+	structKind
+	fixedKind
+)
+
+// value describes how a field or slice element is encoded.
+type value struct {
+	kind    valueKind
+	goType  string       // the type as written in the source
+	basic   string       // the predeclared type goType is or is declared as, if any
+	wire    string       // name of the Packer method suffix for scalars, e.g. Uint16
+	param   string       // parameter type of the Packer method for scalars
+	direct  bool         // whether the Go type is the parameter type
+	convert bool         // whether the Go type is a named type of the parameter type
+	boolean bool         // whether a bool is encoded as a wider integer
+	signed  bool         // whether the wire type of scalars is signed
+	float   bool         // whether the wire type of scalars is a float
+	width   int          // wire width of scalars and fixed-point values
+	order   string       // byte order suffix of the methods, BE or LE, if overridden
+	fixed   bintag.Fixed // format of fixed-point values
+	policy  string       // FixedOverflow of fixed-point values, if not the Packer's
+	prefix  int
+	length  int
+	elem    *value
+}
+
+var goScalars = map[string]bool{
+	"bool": true, "byte": true, "uint8": true, "int8": true,
+	"uint16": true, "int16": true, "uint32": true, "int32": true,
+	"uint64": true, "int64": true, "float32": true, "float64": true,
+	"int": true, "uint": true, "uintptr": true,
+}
+
+// wireMethods maps wire type names to the Push*/Fetch* method suffixes.
+var wireMethods = map[string]string{
+	"bool": "Bool", "byte": "Uint8", "uint8": "Uint8", "int8": "Int8",
+	"uint16": "Uint16", "int16": "Int16", "uint32": "Uint32", "int32": "Int32",
+	"uint64": "Uint64", "int64": "Int64", "float32": "Float32", "float64": "Float64",
+}
+
+// orderSuffix returns the method suffix of a byte order override.
+func orderSuffix(order binary.ByteOrder) string {
+	switch order {
+	case binary.BigEndian:
+		return "BE"
+	case binary.LittleEndian:
+		return "LE"
+	}
+	return ""
+}
+
+// underlying returns the predeclared type name is, or is declared as in decls
+// like Opcode in "type Opcode uint16", or "" if it is neither.
+func underlying(name string, decls map[string]ast.Expr) string {
+	// Bounded by the number of declarations, in case they are cyclic.
+	for i := 0; i <= len(decls); i++ {
+		if goScalars[name] || name == "string" {
+			return name
+		}
+		id, ok := decls[name].(*ast.Ident)
+		if !ok {
+			break
+		}
+		name = id.Name
+	}
+	return ""
+}
+
+// resolve describes how expr, a field or element type, is encoded with tag
+// tg. Named types declared in decls are encoded as their underlying type.
+func resolve(expr ast.Expr, tg bintag.Tag, decls map[string]ast.Expr) (*value, error) {
+	v := &value{goType: types.ExprString(expr), order: orderSuffix(tg.Order), prefix: tg.Prefix, length: tg.Length}
+	if v.prefix == 0 && v.length < 0 {
+		v.prefix = bintag.DefaultPrefix
+	}
+	elem := bintag.Tag{Type: tg.Type, Order: tg.Order, Length: -1, Fixed: tg.Fixed, Overflow: tg.Overflow}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		v.basic = underlying(t.Name, decls)
+		switch {
+		case t.Name == "string":
+			if tg.Type != "" && tg.Type != "string" {
+				return nil, fmt.Errorf("cannot encode string as %s", tg.Type)
+			}
+			v.kind = stringKind
+			return v, nil
+		case v.basic == "string":
+			return nil, fmt.Errorf("unsupported type %s", v.goType)
+		case v.basic != "" || tg.Type != "":
+		case isStruct(decls[t.Name]):
+			return structValue(v, tg)
+		default:
+			return nil, fmt.Errorf("%s is not a struct type and needs an explicit type, e.g. `bin:\"uint16\"`", v.goType)
+		}
+	case *ast.SelectorExpr:
+		if tg.Type == "" {
+			return structValue(v, tg)
+		}
+	case *ast.ArrayType:
+		isByte := isIdent(t.Elt, "byte") || isIdent(t.Elt, "uint8")
+		if t.Len == nil {
+			if isByte && (tg.Type == "" || tg.Type == "bytes") {
+				v.kind = bytesKind
+				return v, nil
+			}
+			v.kind = sliceKind
+		} else {
+			if tg.Prefix != 0 || tg.Length >= 0 {
+				return nil, errors.New("prefix and len are not allowed on arrays")
+			}
+			if isByte && (tg.Type == "" || tg.Type == "bytes") {
+				v.kind = byteArrayKind
+				return v, nil
+			}
+			v.kind = arrayKind
+		}
+		e, err := resolve(t.Elt, elem, decls)
+		if err != nil {
+			return nil, err
+		}
+		v.elem = e
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", v.goType)
+	}
+	if tg.Prefix != 0 || tg.Length >= 0 {
+		return nil, fmt.Errorf("prefix and len are not allowed on %s", v.goType)
+	}
+	if tg.Fixed != nil {
+		return fixedValue(v, tg)
+	}
+	wire := tg.Type
+	if wire == "" {
+		wire = v.basic
+	}
+	st, ok := bintag.Scalars[wire]
+	if !ok {
+		if tg.Type == "" {
+			return nil, fmt.Errorf("%s needs an explicit type", v.goType)
+		}
+		return nil, fmt.Errorf("unknown type %q", wire)
+	}
+	if v.basic != "" && st.Float != strings.HasPrefix(v.basic, "float") {
+		return nil, fmt.Errorf("cannot encode %s as %s", v.goType, wire)
+	}
+	// A bool is 0 or 1 in any integer; PushBool writes the 1-byte ones.
+	isBool := v.basic == "bool"
+	switch {
+	case wire == "bool" && !isBool:
+		wire = "uint8"
+	case isBool && st.Width == 1:
+		wire = "bool"
+	}
+	v.kind = scalarKind
+	v.wire = wireMethods[wire]
+	v.param = strings.ToLower(v.wire)
+	v.width = st.Width
+	v.signed = st.Signed
+	v.float = st.Float
+	v.direct = v.goType == v.param || v.goType == "byte" && v.param == "uint8"
+	v.convert = !v.direct && (v.basic == v.param || v.basic == "byte" && v.param == "uint8")
+	v.boolean = isBool && !v.direct && !v.convert
+	if v.width == 1 {
+		v.order = ""
+	}
+	return v, nil
+}
+
+// fixedValue resolves a float encoded as a Q-format fixed-point value.
+func fixedValue(v *value, tg bintag.Tag) (*value, error) {
+	if v.basic != "" && !strings.HasPrefix(v.basic, "float") {
+		return nil, fmt.Errorf("cannot encode %s as %s", v.goType, tg.Type)
+	}
+	v.kind = fixedKind
+	v.fixed = *tg.Fixed
+	switch tg.Overflow {
+	case "saturate":
+		v.policy = "SaturateOutOfRange"
+	case "reject":
+		v.policy = "RejectOutOfRange"
+	}
+	bits := v.fixed.IntBits + v.fixed.FracBits
+	if v.fixed.Signed {
+		bits++
+	}
+	v.width = (bits + 7) / 8
+	v.direct = v.goType == "float64"
+	if v.width == 1 {
+		v.order = ""
+	}
+	return v, nil
+}
+
+func structValue(v *value, tg bintag.Tag) (*value, error) {
+	if tg.Prefix != 0 || tg.Length >= 0 {
+		return nil, fmt.Errorf("prefix and len are not allowed on %s", v.goType)
+	}
+	v.kind = structKind
+	return v, nil
+}
+
+func isStruct(expr ast.Expr) bool {
+	_, ok := expr.(*ast.StructType)
+	return ok
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// emitter writes method bodies, chaining consecutive Push*/Fetch* calls on
+// the receiver like hand-written code does.
+type emitter struct {
+	buf     *bytes.Buffer
+	recv    string
+	op      string          // the generated method, for errors
+	imports map[string]bool // packages the generated code uses besides binpacker
+	chain   []string
+}
+
+func (e *emitter) call(format string, args ...interface{}) {
+	e.chain = append(e.chain, fmt.Sprintf(format, args...))
+}
+
+func (e *emitter) stmt(format string, args ...interface{}) {
+	e.flush()
+	fmt.Fprintf(e.buf, format+"\n", args...)
+}
+
+func (e *emitter) flush() {
+	if len(e.chain) > 0 {
+		fmt.Fprintf(e.buf, "%s.%s\n", e.recv, strings.Join(e.chain, ".\n"))
+		e.chain = nil
+	}
+}
+
+// failIf emits a statement failing with the binpacker error err if cond holds.
+func (e *emitter) failIf(cond, err string) {
+	e.stmt("if %s {\n%s.Fail(%q, binpacker.%s)\n}", cond, e.recv, e.op, err)
+}
+
+// method returns the name of the Push* or Fetch* method of a value of the
+// given width, honouring its byte order override.
+func method(verb, suffix string, v *value, width int) string {
+	if width > 1 {
+		suffix += v.order
+	}
+	return verb + suffix
+}
+
+// endian returns the expression of the byte order override of v.
+func (e *emitter) endian(v *value) string {
+	e.imports["encoding/binary"] = true
+	if v.order == "BE" {
+		return "binary.BigEndian"
+	}
+	return "binary.LittleEndian"
+}
+
+func (e *emitter) push(v *value, x string, depth int) {
+	switch v.kind {
+	case scalarKind:
+		e.pushScalar(v, x)
+	case fixedKind:
+		e.pushFixed(v, x)
+	case stringKind, bytesKind:
+		what := "String"
+		if v.kind == bytesKind {
+			what = "Bytes"
+		}
+		switch {
+		case v.length >= 0:
+			e.failIf(fmt.Sprintf("len(%s) != %d", x, v.length), "ErrLengthMismatch")
+			e.call("Push%s(%s)", what, x)
+		case v.prefix > 1 && v.order != "":
+			e.pushLength(v, x)
+			e.call("Push%s(%s)", what, x)
+		default:
+			e.call("Push%sWith%sPrefix(%s)", what, title(prefixType(v.prefix)), x)
+		}
+	case byteArrayKind:
+		e.call("PushBytes(%s[:])", x)
+	case sliceKind, arrayKind:
+		if v.kind == sliceKind && v.length >= 0 {
+			e.failIf(fmt.Sprintf("len(%s) != %d", x, v.length), "ErrLengthMismatch")
+		} else if v.kind == sliceKind {
+			e.pushLength(v, x)
+		}
+		i := fmt.Sprintf("i%d", depth)
+		e.stmt("for %s := range %s {", i, x)
+		e.push(v.elem, fmt.Sprintf("%s[%s]", x, i), depth+1)
+		e.stmt("}")
+	case structKind:
+		if v.order != "" {
+			e.stmt("%s.WithEndian(%s, func(%s *binpacker.Packer) {\n%s.PackTo(%s)\n})", e.recv, e.endian(v), e.recv, x, e.recv)
+			return
+		}
+		e.stmt("%s.PackTo(%s)", x, e.recv)
+	}
+}
+
+// pushScalar emits the push of a scalar, failing with ErrValueOverflow if it
+// does not fit its wire type as Marshal does.
+func (e *emitter) pushScalar(v *value, x string) {
+	m := method("Push", v.wire, v, v.width)
+	switch {
+	case v.direct:
+		e.call("%s(%s)", m, x)
+		return
+	case v.convert:
+		e.call("%s(%s(%s))", m, v.param, x)
+		return
+	case v.boolean:
+		e.stmt("{\nvar v %s\nif %s {\nv = 1\n}\n%s.%s(v)\n}", v.param, x, e.recv, m)
+		return
+	case v.float && v.width == 4:
+		e.imports["math"] = true
+		e.failIf(fmt.Sprintf("math.IsInf(float64(float32(%s)), 0) && !math.IsInf(float64(%s), 0)", x, x), "ErrValueOverflow")
+	case v.signed:
+		e.failIf(fmt.Sprintf("%s(%s(%s)) != %s || (%s(%s) < 0) != (%s < 0)", v.goType, v.param, x, x, v.param, x, x), "ErrValueOverflow")
+	case !v.float:
+		e.failIf(fmt.Sprintf("%s(%s(%s)) != %s || %s < 0", v.goType, v.param, x, x, x), "ErrValueOverflow")
+	}
+	e.call("%s(%s(%s))", m, v.param, x)
+}
+
+// pushFixed emits the push of a fixed-point value with its overflow policy
+// and byte order, if they are overridden.
+func (e *emitter) pushFixed(v *value, x string) {
+	if !v.direct {
+		x = fmt.Sprintf("float64(%s)", x)
+	}
+	call := fmt.Sprintf("PushFixed(%s, %d, %d, %t)", x, v.fixed.IntBits, v.fixed.FracBits, v.fixed.Signed)
+	s := fmt.Sprintf("%s.%s", e.recv, call)
+	if v.policy != "" {
+		s = fmt.Sprintf("o := %s.FixedOverflow()\n%s.WithFixedOverflow(binpacker.%s).\n%s.\nWithFixedOverflow(o)", e.recv, e.recv, v.policy, call)
+	}
+	switch {
+	case v.order != "":
+		e.stmt("%s.WithEndian(%s, func(%s *binpacker.Packer) {\n%s\n})", e.recv, e.endian(v), e.recv, s)
+	case v.policy != "":
+		e.stmt("{\n%s\n}", s)
+	default:
+		e.call("%s", call)
+	}
+}
+
+// pushLength emits the length prefix of x, failing with ErrLengthOverflow if
+// the length does not fit.
+func (e *emitter) pushLength(v *value, x string) {
+	t := prefixType(v.prefix)
+	if v.prefix < 8 {
+		e.failIf(fmt.Sprintf("uint64(len(%s)) > %#x", x, uint64(1)<<(8*uint(v.prefix))-1), "ErrLengthOverflow")
+	}
+	e.call("%s(%s(len(%s)))", method("Push", title(t), v, v.prefix), t, x)
+}
+
+func (e *emitter) fetch(v *value, x string, depth int) {
+	switch v.kind {
+	case scalarKind:
+		e.fetchScalar(v, x)
+	case fixedKind:
+		e.fetchFixed(v, x)
+	case stringKind, bytesKind:
+		what := "String"
+		if v.kind == bytesKind {
+			what = "Bytes"
+		}
+		switch {
+		case v.length >= 0:
+			e.call("Fetch%s(%d, &%s)", what, v.length, x)
+		case v.prefix > 1 && v.order != "":
+			t := prefixType(v.prefix)
+			e.stmt("{\nvar n %s\n%s.%s(&n).\nFetch%s(uint64(n), &%s)\n}", t, e.recv, method("Fetch", title(t), v, v.prefix), what, x)
+		default:
+			e.call("%sWith%sPrefix(&%s)", what, title(prefixType(v.prefix)), x)
+		}
+	case byteArrayKind:
+		e.stmt("{\nvar b []byte\n%s.FetchBytes(uint64(len(%s)), &b)\ncopy(%s[:], b)\n}", e.recv, x, x)
+	case sliceKind:
+		i := fmt.Sprintf("i%d", depth)
+		if v.length >= 0 {
+			e.call("CheckElements(%q, %d)", e.op, v.length)
+			e.stmt("%s = make(%s, %d)", x, v.goType, v.length)
+			e.stmt("for %s := range %s {", i, x)
+			e.fetch(v.elem, fmt.Sprintf("%s[%s]", x, i), depth+1)
+			e.stmt("}")
+			return
+		}
+		t := prefixType(v.prefix)
+		el := fmt.Sprintf("e%d", depth)
+		e.stmt("{\nvar n %s\n%s.%s(&n)\n%s.CheckElements(%q, uint64(n))\n%s = %s[:0]", t, e.recv, method("Fetch", title(t), v, v.prefix), e.recv, e.op, x, x)
+		e.stmt("for %s := 0; %s < int(n) && %s.Error() == nil; %s++ {", i, i, e.recv, i)
+		e.stmt("var %s %s", el, v.elem.goType)
+		e.fetch(v.elem, el, depth+1)
+		e.stmt("%s = append(%s, %s)\n}\n}", x, x, el)
+	case arrayKind:
+		i := fmt.Sprintf("i%d", depth)
+		e.stmt("for %s := range %s {", i, x)
+		e.fetch(v.elem, fmt.Sprintf("%s[%s]", x, i), depth+1)
+		e.stmt("}")
+	case structKind:
+		if v.order != "" {
+			e.stmt("%s.WithEndian(%s, func(%s *binpacker.Unpacker) {\n%s.UnpackFrom(%s)\n})", e.recv, e.endian(v), e.recv, x, e.recv)
+			return
+		}
+		e.stmt("%s.UnpackFrom(%s)", x, e.recv)
+	}
+}
+
+// fetchScalar emits the fetch of a scalar, failing with ErrValueOverflow if it
+// does not fit the Go type as Unmarshal does.
+func (e *emitter) fetchScalar(v *value, x string) {
+	m := method("Fetch", v.wire, v, v.width)
+	switch {
+	case v.direct:
+		e.call("%s(&%s)", m, x)
+		return
+	case v.convert:
+		e.call("%s((*%s)(&%s))", m, v.param, x)
+		return
+	}
+	e.stmt("{\nvar v %s\n%s.%s(&v)", v.param, e.recv, m)
+	switch {
+	case v.boolean:
+		e.failIf(fmt.Sprintf("v > 1 && %s.StrictBools()", e.recv), "ErrInvalidBool")
+		e.stmt("%s = v != 0", x)
+	case v.float:
+		e.stmt("%s = %s(v)", x, v.goType)
+		if v.width == 8 {
+			e.imports["math"] = true
+			e.failIf(fmt.Sprintf("math.IsInf(float64(%s), 0) && !math.IsInf(v, 0)", x), "ErrValueOverflow")
+		}
+	case v.signed:
+		e.stmt("%s = %s(v)", x, v.goType)
+		e.failIf(fmt.Sprintf("%s(%s) != v || (%s < 0) != (v < 0)", v.param, x, x), "ErrValueOverflow")
+	default:
+		e.stmt("%s = %s(v)", x, v.goType)
+		e.failIf(fmt.Sprintf("%s(%s) != v || %s < 0", v.param, x, x), "ErrValueOverflow")
+	}
+	e.stmt("}")
+}
+
+// fetchFixed emits the fetch of a fixed-point value, converting it to the Go
+// type of x.
+func (e *emitter) fetchFixed(v *value, x string) {
+	dst := "&" + x
+	if !v.direct {
+		dst = "&v"
+	}
+	call := fmt.Sprintf("FetchFixed(%d, %d, %t, %s)", v.fixed.IntBits, v.fixed.FracBits, v.fixed.Signed, dst)
+	switch {
+	case v.direct && v.order == "":
+		e.call("%s", call)
+		return
+	case !v.direct:
+		e.stmt("{\nvar v float64")
+	}
+	if v.order != "" {
+		e.stmt("%s.WithEndian(%s, func(%s *binpacker.Unpacker) {\n%s.%s\n})", e.recv, e.endian(v), e.recv, e.recv, call)
+	} else {
+		e.stmt("%s.%s", e.recv, call)
+	}
+	if !v.direct {
+		e.stmt("%s = %s(v)\n}", x, v.goType)
+	}
+}
+
+// size returns the constant part of the encoded size of x and the statements
+// adding the variable part to n.
+func size(v *value, x string, depth int) (int, []string) {
+	switch v.kind {
+	case scalarKind, fixedKind:
+		return v.width, nil
+	case stringKind, bytesKind:
+		if v.length >= 0 {
+			return v.length, nil
+		}
+		return v.prefix, []string{fmt.Sprintf("n += len(%s)", x)}
+	case byteArrayKind:
+		return 0, []string{fmt.Sprintf("n += len(%s)", x)}
+	case sliceKind, arrayKind:
+		c := 0
+		if v.kind == sliceKind && v.length < 0 {
+			c = v.prefix
+		}
+		el := fmt.Sprintf("e%d", depth)
+		ec, stmts := size(v.elem, el, depth+1)
+		if len(stmts) == 0 && ec == 1 {
+			return c, []string{fmt.Sprintf("n += len(%s)", x)}
+		}
+		if len(stmts) == 0 {
+			return c, []string{fmt.Sprintf("n += len(%s) * %d", x, ec)}
+		}
+		loop := []string{fmt.Sprintf("for _, %s := range %s {", el, x)}
+		if ec > 0 {
+			loop = append(loop, fmt.Sprintf("n += %d", ec))
+		}
+		loop = append(loop, stmts...)
+		return c, append(loop, "}")
+	case structKind:
+		return 0, []string{fmt.Sprintf("n += %s.Size()", x)}
+	}
+	return 0, nil
+}
+
+func prefixType(width int) string {
+	return fmt.Sprintf("uint%d", width*8)
+}
+
+// title upper-cases the first letter of a type name, e.g. uint16 to Uint16.
+func title(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// generate returns the gofmt-ed source of the PackTo, UnpackFrom and Size
+// methods of types.
+func generate(pkg string, types []structType) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	imports := map[string]bool{}
+	for _, t := range types {
+		values := make([]*value, len(t.fields))
+		for i, f := range t.fields {
+			v, err := resolve(f.expr, f.tag, t.decls)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", t.name, f.name, err)
+			}
+			values[i] = v
+		}
+
+		fmt.Fprintf(buf, "\n// PackTo packs x into p.\n")
+		fmt.Fprintf(buf, "func (x *%s) PackTo(p *binpacker.Packer) *binpacker.Packer {\n", t.name)
+		e := &emitter{buf: buf, recv: "p", op: "PackTo", imports: imports}
+		for i, f := range t.fields {
+			e.push(values[i], "x."+f.name, 0)
+		}
+		e.flush()
+		fmt.Fprintf(buf, "return p\n}\n")
+
+		fmt.Fprintf(buf, "\n// UnpackFrom unpacks x from u.\n")
+		fmt.Fprintf(buf, "func (x *%s) UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker {\n", t.name)
+		// Like Unmarshal, count the nesting of structs and the elements of
+		// slices against the limits of u.
+		fmt.Fprintf(buf, "u.Enter(%q)\ndefer u.Leave()\n", "UnpackFrom")
+		e = &emitter{buf: buf, recv: "u", op: "UnpackFrom", imports: imports}
+		for i, f := range t.fields {
+			e.fetch(values[i], "x."+f.name, 0)
+		}
+		e.flush()
+		fmt.Fprintf(buf, "return u\n}\n")
+
+		fmt.Fprintf(buf, "\n// Size returns the number of bytes PackTo writes.\n")
+		fmt.Fprintf(buf, "func (x *%s) Size() int {\n", t.name)
+		total := 0
+		var stmts []string
+		for i, f := range t.fields {
+			c, s := size(values[i], "x."+f.name, 0)
+			total += c
+			stmts = append(stmts, s...)
+		}
+		if len(stmts) == 0 {
+			fmt.Fprintf(buf, "return %d\n}\n", total)
+			continue
+		}
+		fmt.Fprintf(buf, "n := %d\n%s\nreturn n\n}\n", total, strings.Join(stmts, "\n"))
+	}
+
+	head := new(bytes.Buffer)
+	fmt.Fprintf(head, "// Code generated by binpacker-gen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(head, "package %s\n\n", pkg)
+	if len(imports) == 0 {
+		fmt.Fprintf(head, "import \"github.com/zhuangsirui/binpacker\"\n")
+	} else {
+		fmt.Fprintf(head, "import (\n")
+		for _, path := range []string{"encoding/binary", "math"} {
+			if imports[path] {
+				fmt.Fprintf(head, "%q\n", path)
+			}
+		}
+		fmt.Fprintf(head, "\n\"github.com/zhuangsirui/binpacker\"\n)\n")
+	}
+	src, err := format.Source(append(head.Bytes(), buf.Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
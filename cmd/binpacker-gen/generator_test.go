@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func parseSource(t *testing.T, src string) []*ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []*ast.File{f}
+}
+
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		golden := strings.TrimSuffix(path, ".go") + ".golden"
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			types, err := findTypes(parseSource(t, string(src)), nil)
+			assert.NoError(t, err)
+			got, err := generate("example", types)
+			assert.NoError(t, err)
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(want), string(got), "golden file mismatch, run go test -update.")
+		})
+	}
+}
+
+// TestExampleUpToDate checks that the generated code the example package
+// compiles and tests against Marshal is the generator's current output.
+func TestExampleUpToDate(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	out := filepath.Join(dir, "binpacker_gen.go")
+	pkg, files, err := parseDir(dir, out)
+	assert.NoError(t, err)
+	types, err := findTypes(files, nil)
+	assert.NoError(t, err)
+	got, err := generate(pkg, types)
+	assert.NoError(t, err)
+	want, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(want), string(got), "example is out of date, run go generate.")
+}
+
+func TestFindTypesByName(t *testing.T) {
+	files := parseSource(t, "package p\ntype A struct{ X uint8 }\ntype B struct{ Y uint8 }\n")
+	types, err := findTypes(files, []string{"B"})
+	assert.NoError(t, err)
+	assert.Len(t, types, 1)
+	assert.Equal(t, "B", types[0].name, "type error.")
+
+	_, err = findTypes(files, []string{"C"})
+	assert.Error(t, err)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, src := range []string{
+		"struct{ A int }",
+		"struct{ A uint16 `bin:\"float32\"` }",
+		"struct{ A bool `bin:\"float32\"` }",
+		"struct{ A *uint16 }",
+		"struct{ A map[string]uint8 }",
+		"struct{ A uint16 `bin:\",prefix=uint8\"` }",
+		"struct{ A [4]uint16 `bin:\",len=4\"` }",
+		"struct{ A int `bin:\"q7.8\"` }",
+		"struct{ A string `bin:\"uq16\"` }",
+		"struct{ A float64 `bin:\"q7.8,prefix=uint8\"` }",
+		"struct{ A Names }\ntype Names []string",
+		"struct{ A Name }\ntype Name string",
+		"struct{ A []Undeclared }",
+	} {
+		types, err := findTypes(parseSource(t, "package p\ntype T "+src+"\n"), []string{"T"})
+		assert.NoError(t, err, src)
+		_, err = generate("p", types)
+		assert.Error(t, err, src)
+	}
+}
@@ -0,0 +1,485 @@
+// Code generated by binpacker-gen; DO NOT EDIT.
+
+package example
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/zhuangsirui/binpacker"
+)
+
+// PackTo packs x into p.
+func (x *Header) PackTo(p *binpacker.Packer) *binpacker.Packer {
+	p.PushBytes(x.Magic[:]).
+		PushUint16(x.Version).
+		PushUint16(uint16(x.Op))
+	if int(uint32(x.Length)) != x.Length || x.Length < 0 {
+		p.Fail("PackTo", binpacker.ErrValueOverflow)
+	}
+	p.PushUint32(uint32(x.Length)).
+		PushInt64(x.Offset).
+		PushInt8(x.Signed).
+		PushBool(x.Enabled).
+		PushFloat32(x.Ratio).
+		PushStringWithUint8Prefix(x.Name).
+		PushBytesWithUint16Prefix(x.Payload)
+	if len(x.Tag) != 2 {
+		p.Fail("PackTo", binpacker.ErrLengthMismatch)
+	}
+	p.PushString(x.Tag)
+	return p
+}
+
+// UnpackFrom unpacks x from u.
+func (x *Header) UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker {
+	u.Enter("UnpackFrom")
+	defer u.Leave()
+	{
+		var b []byte
+		u.FetchBytes(uint64(len(x.Magic)), &b)
+		copy(x.Magic[:], b)
+	}
+	u.FetchUint16(&x.Version).
+		FetchUint16((*uint16)(&x.Op))
+	{
+		var v uint32
+		u.FetchUint32(&v)
+		x.Length = int(v)
+		if uint32(x.Length) != v || x.Length < 0 {
+			u.Fail("UnpackFrom", binpacker.ErrValueOverflow)
+		}
+	}
+	u.FetchInt64(&x.Offset).
+		FetchInt8(&x.Signed).
+		FetchBool(&x.Enabled).
+		FetchFloat32(&x.Ratio).
+		StringWithUint8Prefix(&x.Name).
+		BytesWithUint16Prefix(&x.Payload).
+		FetchString(2, &x.Tag)
+	return u
+}
+
+// Size returns the number of bytes PackTo writes.
+func (x *Header) Size() int {
+	n := 27
+	n += len(x.Magic)
+	n += len(x.Name)
+	n += len(x.Payload)
+	return n
+}
+
+// PackTo packs x into p.
+func (x *Packet) PackTo(p *binpacker.Packer) *binpacker.Packer {
+	x.Header.PackTo(p)
+	if uint64(len(x.Points)) > 0xff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint8(uint8(len(x.Points)))
+	for i0 := range x.Points {
+		p.PushUint16(x.Points[i0])
+	}
+	for i0 := range x.Grid {
+		for i1 := range x.Grid[i0] {
+			p.PushInt32(x.Grid[i0][i1])
+		}
+	}
+	if uint64(len(x.Labels)) > 0xffff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint16(uint16(len(x.Labels)))
+	for i0 := range x.Labels {
+		p.PushStringWithUint32Prefix(x.Labels[i0])
+	}
+	if uint64(len(x.Entries)) > 0xffffffff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint32(uint32(len(x.Entries)))
+	for i0 := range x.Entries {
+		x.Entries[i0].PackTo(p)
+	}
+	return p
+}
+
+// UnpackFrom unpacks x from u.
+func (x *Packet) UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker {
+	u.Enter("UnpackFrom")
+	defer u.Leave()
+	x.Header.UnpackFrom(u)
+	{
+		var n uint8
+		u.FetchUint8(&n)
+		u.CheckElements("UnpackFrom", uint64(n))
+		x.Points = x.Points[:0]
+		for i0 := 0; i0 < int(n) && u.Error() == nil; i0++ {
+			var e0 uint16
+			u.FetchUint16(&e0)
+			x.Points = append(x.Points, e0)
+		}
+	}
+	for i0 := range x.Grid {
+		for i1 := range x.Grid[i0] {
+			u.FetchInt32(&x.Grid[i0][i1])
+		}
+	}
+	{
+		var n uint16
+		u.FetchUint16(&n)
+		u.CheckElements("UnpackFrom", uint64(n))
+		x.Labels = x.Labels[:0]
+		for i0 := 0; i0 < int(n) && u.Error() == nil; i0++ {
+			var e0 string
+			u.StringWithUint32Prefix(&e0)
+			x.Labels = append(x.Labels, e0)
+		}
+	}
+	{
+		var n uint32
+		u.FetchUint32(&n)
+		u.CheckElements("UnpackFrom", uint64(n))
+		x.Entries = x.Entries[:0]
+		for i0 := 0; i0 < int(n) && u.Error() == nil; i0++ {
+			var e0 Entry
+			e0.UnpackFrom(u)
+			x.Entries = append(x.Entries, e0)
+		}
+	}
+	return u
+}
+
+// Size returns the number of bytes PackTo writes.
+func (x *Packet) Size() int {
+	n := 7
+	n += x.Header.Size()
+	n += len(x.Points) * 2
+	for _, e0 := range x.Grid {
+		n += len(e0) * 4
+	}
+	for _, e0 := range x.Labels {
+		n += 4
+		n += len(e0)
+	}
+	for _, e0 := range x.Entries {
+		n += e0.Size()
+	}
+	return n
+}
+
+// PackTo packs x into p.
+func (x *Entry) PackTo(p *binpacker.Packer) *binpacker.Packer {
+	p.PushUint8(x.Key)
+	if int(int16(x.Value)) != x.Value || (int16(x.Value) < 0) != (x.Value < 0) {
+		p.Fail("PackTo", binpacker.ErrValueOverflow)
+	}
+	p.PushInt16(int16(x.Value))
+	return p
+}
+
+// UnpackFrom unpacks x from u.
+func (x *Entry) UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker {
+	u.Enter("UnpackFrom")
+	defer u.Leave()
+	u.FetchUint8(&x.Key)
+	{
+		var v int16
+		u.FetchInt16(&v)
+		x.Value = int(v)
+		if int16(x.Value) != v || (x.Value < 0) != (v < 0) {
+			u.Fail("UnpackFrom", binpacker.ErrValueOverflow)
+		}
+	}
+	return u
+}
+
+// Size returns the number of bytes PackTo writes.
+func (x *Entry) Size() int {
+	return 3
+}
+
+// PackTo packs x into p.
+func (x *Options) PackTo(p *binpacker.Packer) *binpacker.Packer {
+	p.PushUint16LE(x.Port)
+	if int64(uint32(x.Seq)) != x.Seq || x.Seq < 0 {
+		p.Fail("PackTo", binpacker.ErrValueOverflow)
+	}
+	p.PushUint32BE(uint32(x.Seq))
+	if math.IsInf(float64(float32(x.Ratio)), 0) && !math.IsInf(float64(x.Ratio), 0) {
+		p.Fail("PackTo", binpacker.ErrValueOverflow)
+	}
+	p.PushFloat32LE(float32(x.Ratio))
+	if int(uint8(x.Kind)) != x.Kind || x.Kind < 0 {
+		p.Fail("PackTo", binpacker.ErrValueOverflow)
+	}
+	p.PushUint8(uint8(x.Kind))
+	{
+		var v uint16
+		if x.Ready {
+			v = 1
+		}
+		p.PushUint16(v)
+	}
+	p.PushBool(x.Flag)
+	if uint64(len(x.Name)) > 0xffff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint16LE(uint16(len(x.Name))).
+		PushString(x.Name)
+	if uint64(len(x.Points)) > 0xffff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint16LE(uint16(len(x.Points)))
+	for i0 := range x.Points {
+		p.PushInt32LE(x.Points[i0])
+	}
+	if len(x.Pair) != 2 {
+		p.Fail("PackTo", binpacker.ErrLengthMismatch)
+	}
+	for i0 := range x.Pair {
+		p.PushUint16(x.Pair[i0])
+	}
+	p.WithEndian(binary.LittleEndian, func(p *binpacker.Packer) {
+		x.Nested.PackTo(p)
+	})
+	if uint64(len(x.Entries)) > 0xff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint8(uint8(len(x.Entries)))
+	for i0 := range x.Entries {
+		p.WithEndian(binary.BigEndian, func(p *binpacker.Packer) {
+			x.Entries[i0].PackTo(p)
+		})
+	}
+	return p
+}
+
+// UnpackFrom unpacks x from u.
+func (x *Options) UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker {
+	u.Enter("UnpackFrom")
+	defer u.Leave()
+	u.FetchUint16LE(&x.Port)
+	{
+		var v uint32
+		u.FetchUint32BE(&v)
+		x.Seq = int64(v)
+		if uint32(x.Seq) != v || x.Seq < 0 {
+			u.Fail("UnpackFrom", binpacker.ErrValueOverflow)
+		}
+	}
+	{
+		var v float32
+		u.FetchFloat32LE(&v)
+		x.Ratio = float64(v)
+	}
+	{
+		var v uint8
+		u.FetchUint8(&v)
+		x.Kind = int(v)
+		if uint8(x.Kind) != v || x.Kind < 0 {
+			u.Fail("UnpackFrom", binpacker.ErrValueOverflow)
+		}
+	}
+	{
+		var v uint16
+		u.FetchUint16(&v)
+		if v > 1 && u.StrictBools() {
+			u.Fail("UnpackFrom", binpacker.ErrInvalidBool)
+		}
+		x.Ready = v != 0
+	}
+	u.FetchBool(&x.Flag)
+	{
+		var n uint16
+		u.FetchUint16LE(&n).
+			FetchString(uint64(n), &x.Name)
+	}
+	{
+		var n uint16
+		u.FetchUint16LE(&n)
+		u.CheckElements("UnpackFrom", uint64(n))
+		x.Points = x.Points[:0]
+		for i0 := 0; i0 < int(n) && u.Error() == nil; i0++ {
+			var e0 int32
+			u.FetchInt32LE(&e0)
+			x.Points = append(x.Points, e0)
+		}
+	}
+	u.CheckElements("UnpackFrom", 2)
+	x.Pair = make([]uint16, 2)
+	for i0 := range x.Pair {
+		u.FetchUint16(&x.Pair[i0])
+	}
+	u.WithEndian(binary.LittleEndian, func(u *binpacker.Unpacker) {
+		x.Nested.UnpackFrom(u)
+	})
+	{
+		var n uint8
+		u.FetchUint8(&n)
+		u.CheckElements("UnpackFrom", uint64(n))
+		x.Entries = x.Entries[:0]
+		for i0 := 0; i0 < int(n) && u.Error() == nil; i0++ {
+			var e0 Entry
+			u.WithEndian(binary.BigEndian, func(u *binpacker.Unpacker) {
+				e0.UnpackFrom(u)
+			})
+			x.Entries = append(x.Entries, e0)
+		}
+	}
+	return u
+}
+
+// Size returns the number of bytes PackTo writes.
+func (x *Options) Size() int {
+	n := 19
+	n += len(x.Name)
+	n += len(x.Points) * 4
+	n += len(x.Pair) * 2
+	n += x.Nested.Size()
+	for _, e0 := range x.Entries {
+		n += e0.Size()
+	}
+	return n
+}
+
+// PackTo packs x into p.
+func (x *Command) PackTo(p *binpacker.Packer) *binpacker.Packer {
+	p.PushUint16(uint16(x.Op))
+	if Opcode(uint32(x.Wide)) != x.Wide || x.Wide < 0 {
+		p.Fail("PackTo", binpacker.ErrValueOverflow)
+	}
+	p.PushUint32(uint32(x.Wide))
+	if uint64(len(x.Levels)) > 0xff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint8(uint8(len(x.Levels)))
+	for i0 := range x.Levels {
+		p.PushInt8(int8(x.Levels[i0]))
+	}
+	for i0 := range x.Ops {
+		p.PushUint16(uint16(x.Ops[i0]))
+	}
+	p.PushBool(bool(x.Set))
+	{
+		var v uint16
+		if x.Ready {
+			v = 1
+		}
+		p.PushUint16(v)
+	}
+	return p
+}
+
+// UnpackFrom unpacks x from u.
+func (x *Command) UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker {
+	u.Enter("UnpackFrom")
+	defer u.Leave()
+	u.FetchUint16((*uint16)(&x.Op))
+	{
+		var v uint32
+		u.FetchUint32(&v)
+		x.Wide = Opcode(v)
+		if uint32(x.Wide) != v || x.Wide < 0 {
+			u.Fail("UnpackFrom", binpacker.ErrValueOverflow)
+		}
+	}
+	{
+		var n uint8
+		u.FetchUint8(&n)
+		u.CheckElements("UnpackFrom", uint64(n))
+		x.Levels = x.Levels[:0]
+		for i0 := 0; i0 < int(n) && u.Error() == nil; i0++ {
+			var e0 Level
+			u.FetchInt8((*int8)(&e0))
+			x.Levels = append(x.Levels, e0)
+		}
+	}
+	for i0 := range x.Ops {
+		u.FetchUint16((*uint16)(&x.Ops[i0]))
+	}
+	u.FetchBool((*bool)(&x.Set))
+	{
+		var v uint16
+		u.FetchUint16(&v)
+		if v > 1 && u.StrictBools() {
+			u.Fail("UnpackFrom", binpacker.ErrInvalidBool)
+		}
+		x.Ready = v != 0
+	}
+	return u
+}
+
+// Size returns the number of bytes PackTo writes.
+func (x *Command) Size() int {
+	n := 10
+	n += len(x.Levels)
+	n += len(x.Ops) * 2
+	return n
+}
+
+// PackTo packs x into p.
+func (x *Reading) PackTo(p *binpacker.Packer) *binpacker.Packer {
+	p.PushFixed(x.Level, 0, 15, true)
+	{
+		o := p.FixedOverflow()
+		p.WithFixedOverflow(binpacker.SaturateOutOfRange).
+			PushFixed(float64(x.Gain), 8, 8, false).
+			WithFixedOverflow(o)
+	}
+	p.WithEndian(binary.LittleEndian, func(p *binpacker.Packer) {
+		o := p.FixedOverflow()
+		p.WithFixedOverflow(binpacker.RejectOutOfRange).
+			PushFixed(x.Phase, 3, 12, true).
+			WithFixedOverflow(o)
+	})
+	p.WithEndian(binary.BigEndian, func(p *binpacker.Packer) {
+		p.PushFixed(float64(x.Volts), 7, 8, true)
+	})
+	if uint64(len(x.Taps)) > 0xff {
+		p.Fail("PackTo", binpacker.ErrLengthOverflow)
+	}
+	p.PushUint8(uint8(len(x.Taps)))
+	for i0 := range x.Taps {
+		p.PushFixed(x.Taps[i0], 1, 14, true)
+	}
+	return p
+}
+
+// UnpackFrom unpacks x from u.
+func (x *Reading) UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker {
+	u.Enter("UnpackFrom")
+	defer u.Leave()
+	u.FetchFixed(0, 15, true, &x.Level)
+	{
+		var v float64
+		u.FetchFixed(8, 8, false, &v)
+		x.Gain = float32(v)
+	}
+	u.WithEndian(binary.LittleEndian, func(u *binpacker.Unpacker) {
+		u.FetchFixed(3, 12, true, &x.Phase)
+	})
+	{
+		var v float64
+		u.WithEndian(binary.BigEndian, func(u *binpacker.Unpacker) {
+			u.FetchFixed(7, 8, true, &v)
+		})
+		x.Volts = Volts(v)
+	}
+	{
+		var n uint8
+		u.FetchUint8(&n)
+		u.CheckElements("UnpackFrom", uint64(n))
+		x.Taps = x.Taps[:0]
+		for i0 := 0; i0 < int(n) && u.Error() == nil; i0++ {
+			var e0 float64
+			u.FetchFixed(1, 14, true, &e0)
+			x.Taps = append(x.Taps, e0)
+		}
+	}
+	return u
+}
+
+// Size returns the number of bytes PackTo writes.
+func (x *Reading) Size() int {
+	n := 9
+	n += len(x.Taps) * 2
+	return n
+}
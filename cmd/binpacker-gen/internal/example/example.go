@@ -0,0 +1,83 @@
+// Package example holds types with methods generated by binpacker-gen, so
+// that the tests can check the generated code against binpacker.Marshal.
+package example
+
+//go:generate go run github.com/zhuangsirui/binpacker/cmd/binpacker-gen
+
+import "time"
+
+// Epoch is not a type, and the generator skips it.
+var Epoch = time.Unix(0, 0)
+
+type Opcode uint16
+
+//binpacker:generate
+type Header struct {
+	Magic   [4]byte
+	Version uint16
+	Op      Opcode `bin:"uint16"`
+	Length  int    `bin:"uint32"`
+	Offset  int64
+	Signed  int8
+	Enabled bool
+	Ratio   float32
+	Name    string `bin:"string,prefix=uint8"`
+	Payload []byte `bin:"bytes,prefix=uint16"`
+	Tag     string `bin:",len=2"`
+	Cache   []byte `bin:"-"`
+}
+
+//binpacker:generate
+type Packet struct {
+	Header  Header
+	Points  []uint16 `bin:",prefix=uint8"`
+	Grid    [2][3]int32
+	Labels  []string `bin:"string,prefix=uint16"`
+	Entries []Entry
+}
+
+//binpacker:generate
+type Entry struct {
+	Key   uint8
+	Value int `bin:"int16"`
+}
+
+//binpacker:generate
+type Options struct {
+	Port    uint16   `bin:",le"`
+	Seq     int64    `bin:"uint32,be"`
+	Ratio   float64  `bin:"float32,le"`
+	Kind    int      `bin:"bool"`
+	Ready   bool     `bin:"uint16"`
+	Flag    bool     `bin:"uint8"`
+	Name    string   `bin:",prefix=uint16,le"`
+	Points  []int32  `bin:",prefix=uint16,le"`
+	Pair    []uint16 `bin:",len=2"`
+	Nested  Entry    `bin:",le"`
+	Entries []Entry  `bin:",prefix=uint8,be"`
+}
+
+type Level int8
+
+type Flag bool
+
+//binpacker:generate
+type Command struct {
+	Op     Opcode
+	Wide   Opcode  `bin:"uint32"`
+	Levels []Level `bin:",prefix=uint8"`
+	Ops    [2]Opcode
+	Set    Flag
+	Ready  Flag `bin:"uint16"`
+}
+
+type Volts float32
+
+//binpacker:generate
+type Reading struct {
+	Level float64   `bin:"q15"`
+	Gain  float32   `bin:"uq8.8,saturate"`
+	Phase float64   `bin:"q3.12,reject,le"`
+	Volts Volts     `bin:"q7.8,be"`
+	Taps  []float64 `bin:"q1.14,prefix=uint8"`
+}
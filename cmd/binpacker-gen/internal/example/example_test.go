@@ -0,0 +1,167 @@
+package example
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhuangsirui/binpacker"
+)
+
+type generated interface {
+	PackTo(p *binpacker.Packer) *binpacker.Packer
+	UnpackFrom(u *binpacker.Unpacker) *binpacker.Unpacker
+	Size() int
+}
+
+func packet() *Packet {
+	return &Packet{
+		Header: Header{
+			Magic:   [4]byte{'B', 'I', 'N', 'P'},
+			Version: 3,
+			Op:      0x1234,
+			Length:  70000,
+			Offset:  -5,
+			Signed:  -2,
+			Enabled: true,
+			Ratio:   0.5,
+			Name:    "name",
+			Payload: []byte{1, 2, 3},
+			Tag:     "ok",
+		},
+		Points:  []uint16{1, 0xFFFF},
+		Grid:    [2][3]int32{{1, -2, 3}, {-4, 5, -6}},
+		Labels:  []string{"a", "bc"},
+		Entries: []Entry{{Key: 1, Value: -300}, {Key: 2, Value: 300}},
+	}
+}
+
+func options() *Options {
+	return &Options{
+		Port:    8080,
+		Seq:     0xFFFFFFFF,
+		Ratio:   -1.25,
+		Kind:    200,
+		Ready:   true,
+		Flag:    true,
+		Name:    "options",
+		Points:  []int32{-1, 2},
+		Pair:    []uint16{3, 4},
+		Nested:  Entry{Key: 5, Value: -6},
+		Entries: []Entry{{Key: 7, Value: 0x102}},
+	}
+}
+
+func reading() *Reading {
+	return &Reading{
+		Level: -0.5,
+		Gain:  2.25,
+		Phase: 3.5,
+		Volts: -1.5,
+		Taps:  []float64{0.25, -1},
+	}
+}
+
+func TestMatchesMarshal(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		for _, c := range []struct {
+			in  generated
+			out generated
+		}{
+			{packet(), new(Packet)},
+			{options(), new(Options)},
+			{reading(), new(Reading)},
+			{&Command{Op: 0x1234, Wide: 0xFFFF, Levels: []Level{-1, 2}, Ops: [2]Opcode{3, 4}, Set: true, Ready: true}, new(Command)},
+			{&Entry{Key: 1, Value: math.MaxInt16}, new(Entry)},
+		} {
+			want := new(bytes.Buffer)
+			assert.Nil(t, binpacker.Marshal(order, want, c.in), "Has error.")
+
+			p := binpacker.NewAppendPacker(order, nil)
+			c.in.PackTo(p)
+			assert.Nil(t, p.Error(), "Has error.")
+			assert.Equal(t, p.Bytes(), want.Bytes(), "pack error.")
+			assert.Equal(t, c.in.Size(), len(p.Bytes()), "size error.")
+
+			u := binpacker.NewSliceUnpacker(order, p.Bytes())
+			c.out.UnpackFrom(u.Unpacker)
+			assert.Nil(t, u.Error(), "Has error.")
+			assert.Equal(t, c.out, c.in, "unpack error.")
+		}
+	}
+}
+
+func TestErrorsMatchMarshal(t *testing.T) {
+	long := string(make([]byte, 256))
+	for _, in := range []generated{
+		&Header{Name: long, Tag: "ok"},
+		&Header{Tag: "long"},
+		&Header{Length: -1, Tag: "ok"},
+		&Header{Length: math.MaxUint32 + 1, Tag: "ok"},
+		&Options{Pair: []uint16{1}},
+		&Options{Pair: []uint16{1, 2}, Seq: -1},
+		&Options{Pair: []uint16{1, 2}, Kind: 256},
+		&Options{Pair: []uint16{1, 2}, Ratio: math.MaxFloat64},
+		&Options{Pair: []uint16{1, 2}, Entries: make([]Entry, 256)},
+		&Options{Pair: []uint16{1, 2}, Points: make([]int32, 1<<16)},
+		&Entry{Value: math.MaxInt16 + 1},
+		&Reading{Level: 1},
+		&Reading{Phase: 8},
+		&Reading{Taps: []float64{2}},
+	} {
+		err := binpacker.Marshal(binary.BigEndian, new(bytes.Buffer), in)
+		assert.NotNil(t, err, "marshal error.")
+		p := binpacker.NewAppendPacker(binary.BigEndian, nil)
+		in.PackTo(p)
+		for _, target := range []error{binpacker.ErrLengthOverflow, binpacker.ErrLengthMismatch, binpacker.ErrValueOverflow} {
+			assert.Equal(t, errors.Is(p.Error(), target), errors.Is(err, target), "error mismatch.")
+		}
+		assert.NotNil(t, p.Error(), "pack error.")
+	}
+
+	// A strict Unpacker rejects a wide bool other than 0 or 1.
+	data := make([]byte, 13)
+	data[12] = 2
+	var o Options
+	u := binpacker.NewSliceUnpacker(binary.BigEndian, data)
+	o.UnpackFrom(u.WithStrictBools(true))
+	assert.True(t, errors.Is(u.Error(), binpacker.ErrInvalidBool), "bool error.")
+	u = binpacker.NewSliceUnpacker(binary.BigEndian, data)
+	o.UnpackFrom(u.Unpacker)
+	assert.Equal(t, o.Ready, true, "bool error.")
+}
+
+func TestLimitsMatchUnmarshal(t *testing.T) {
+	p := binpacker.NewAppendPacker(binary.BigEndian, nil)
+	packet().PackTo(p)
+	// Points has 2 elements, and Header is nested in Packet.
+	for _, opts := range []binpacker.UnpackerOptions{{MaxElements: 1}, {MaxDepth: 1}} {
+		want := binpacker.NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(p.Bytes()), opts)
+		want.FetchStruct(new(Packet))
+		assert.True(t, errors.Is(want.Error(), binpacker.ErrLimitExceeded), "unmarshal error.")
+		u := binpacker.NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(p.Bytes()), opts)
+		new(Packet).UnpackFrom(u)
+		assert.True(t, errors.Is(u.Error(), binpacker.ErrLimitExceeded), "limit error.")
+	}
+	u := binpacker.NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(p.Bytes()), binpacker.UnpackerOptions{MaxElements: 2, MaxDepth: 2})
+	new(Packet).UnpackFrom(u)
+	assert.Nil(t, u.Error(), "Has error.")
+}
+
+func TestFixedOverflowMatchesMarshal(t *testing.T) {
+	// Gain saturates and Phase is rejected whatever the Packer's policy.
+	for _, in := range []*Reading{{Gain: 1000}, {Level: 1, Phase: 8}} {
+		for _, o := range []binpacker.FixedOverflow{binpacker.RejectOutOfRange, binpacker.SaturateOutOfRange} {
+			want := binpacker.NewAppendPacker(binary.BigEndian, nil).WithFixedOverflow(o)
+			want.PushStruct(in)
+			p := binpacker.NewAppendPacker(binary.BigEndian, nil).WithFixedOverflow(o)
+			in.PackTo(p)
+			assert.Equal(t, p.Error(), want.Error(), "error mismatch.")
+			assert.Equal(t, p.Bytes(), want.Bytes(), "pack error.")
+			assert.Equal(t, p.FixedOverflow(), o, "policy error.")
+		}
+	}
+}
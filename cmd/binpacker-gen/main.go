@@ -0,0 +1,111 @@
+// Command binpacker-gen generates PackTo, UnpackFrom and Size methods for
+// struct types, so that they can be packed without reflection. The generated
+// methods encode fields the same way binpacker.Marshal does and honour the same
+// "bin" struct tags.
+//
+// Annotate the types with a //binpacker:generate comment and add a go:generate
+// directive to the package:
+//
+//	//go:generate binpacker-gen
+//
+//	//binpacker:generate
+//	type Header struct {
+//		Version uint16
+//		Name    string `bin:"string,prefix=uint8"`
+//	}
+//
+// Alternatively, list the types with the -type flag.
+//
+// Named types declared in the package, such as "type Opcode uint16", are
+// encoded as the predeclared type they are declared as. Other field types that
+// are not structs need an explicit type in their tag.
+//
+// Like Marshal, the generated methods fail with ErrValueOverflow,
+// ErrLengthOverflow or ErrLengthMismatch for values that do not fit their
+// fields, and UnpackFrom enforces the MaxElements and MaxDepth limits of the
+// Unpacker like FetchStruct does.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; defaults to the annotated types")
+	output    = flag.String("output", "", "output file name; default <dir>/binpacker_gen.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: binpacker-gen [flags] [directory]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("binpacker-gen: ")
+	flag.Usage = usage
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	out := *output
+	if out == "" {
+		out = filepath.Join(dir, "binpacker_gen.go")
+	}
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+
+	pkg, files, err := parseDir(dir, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	types, err := findTypes(files, names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(types) == 0 {
+		log.Fatalf("no types to generate in %s", dir)
+	}
+	src, err := generate(pkg, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseDir parses the non-test Go files in dir, skipping the output file.
+func parseDir(dir, out string) (string, []*ast.File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+	fset := token.NewFileSet()
+	var pkg string
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || filepath.Clean(path) == filepath.Clean(out) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		pkg = f.Name.Name
+		files = append(files, f)
+	}
+	return pkg, files, nil
+}
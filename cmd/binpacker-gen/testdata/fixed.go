@@ -0,0 +1,12 @@
+package example
+
+type Volts float32
+
+//binpacker:generate
+type Reading struct {
+	Level float64   `bin:"q15"`
+	Gain  float32   `bin:"uq8.8,saturate"`
+	Phase float64   `bin:"q3.12,reject,le"`
+	Volts Volts     `bin:"q7.8,be"`
+	Taps  []float64 `bin:"q1.14,prefix=uint8"`
+}
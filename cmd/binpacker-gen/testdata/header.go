@@ -0,0 +1,46 @@
+package example
+
+type Opcode uint16
+
+// Unannotated is skipped by the generator.
+type Unannotated struct {
+	A uint8
+}
+
+//binpacker:generate
+type Header struct {
+	Magic   [4]byte
+	Version uint16
+	Op      Opcode `bin:"uint16"`
+	Length  int    `bin:"uint32"`
+	Offset  int64
+	Signed  int8
+	Enabled bool
+	Ratio   float32
+	Name    string `bin:"string,prefix=uint8"`
+	Payload []byte `bin:"bytes,prefix=uint16"`
+	Tag     string `bin:",len=2"`
+	Cache   []byte `bin:"-"`
+	private uint32
+}
+
+//binpacker:generate
+type Packet struct {
+	Header  Header
+	Points  []uint16 `bin:",prefix=uint8"`
+	Grid    [2][3]int32
+	Labels  []string `bin:"string,prefix=uint16"`
+	Entries []Entry
+}
+
+//binpacker:generate
+type Entry struct {
+	Key   uint8
+	Value string
+}
+
+//binpacker:generate
+type Fixed struct {
+	A, B uint32
+	C    [8]float64
+}
@@ -0,0 +1,8 @@
+package example
+
+//binpacker:generate
+type Tree struct {
+	Value    uint8
+	Pair     []uint16 `bin:",len=2"`
+	Children []Tree   `bin:",prefix=uint8"`
+}
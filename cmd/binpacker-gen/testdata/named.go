@@ -0,0 +1,19 @@
+package example
+
+type Opcode uint16
+
+type Level Delta
+
+type Delta int8
+
+type Flag bool
+
+//binpacker:generate
+type Command struct {
+	Op     Opcode
+	Wide   Opcode  `bin:"uint32"`
+	Levels []Level `bin:",prefix=uint8"`
+	Ops    [2]Opcode
+	Set    Flag
+	Ready  Flag `bin:"uint16"`
+}
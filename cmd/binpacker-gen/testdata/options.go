@@ -0,0 +1,37 @@
+package example
+
+import (
+	"fmt"
+	"time"
+)
+
+const version = 2
+
+var epoch = time.Unix(0, 0)
+
+type Kind uint8
+
+func (k Kind) String() string {
+	return fmt.Sprint(uint8(k))
+}
+
+//binpacker:generate
+type Options struct {
+	Port    uint16   `bin:",le"`
+	Seq     int64    `bin:"uint32,be"`
+	Ratio   float64  `bin:"float32,le"`
+	Kind    Kind     `bin:"bool"`
+	Ready   bool     `bin:"uint16"`
+	Flag    bool     `bin:"uint8"`
+	Name    string   `bin:",prefix=uint16,le"`
+	Points  []int32  `bin:",prefix=uint16,le"`
+	Pair    []uint16 `bin:",len=2"`
+	Nested  Entry    `bin:",le"`
+	Entries []Entry  `bin:",prefix=uint8,be"`
+}
+
+//binpacker:generate
+type Entry struct {
+	Key   uint8
+	Value int `bin:"int16"`
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zhuangsirui/binpacker/schema"
+)
+
+// bytesPerLine is the width of the hexdump.
+const bytesPerLine = 16
+
+// annotation describes one line of a dump: a decoded field, a struct or
+// repeated field that contains other annotations, or unparsed bytes.
+type annotation struct {
+	Path   string      `json:"path"`
+	Type   string      `json:"type"` // "unparsed" for bytes no field covers
+	Depth  int         `json:"depth"`
+	Offset int64       `json:"offset"`
+	Length int64       `json:"length"`
+	Leaf   bool        `json:"leaf"`            // whether Raw holds the bytes, rather than nested annotations
+	Raw    string      `json:"raw,omitempty"`   // hex
+	Value  interface{} `json:"value,omitempty"` // nil if the field failed to decode
+}
+
+// annotate flattens the decoded tree root of data in offset order, adding
+// unparsed annotations for the bytes before, between and after the fields.
+// The field that err reports as failed is annotated as incomplete.
+func annotate(root *schema.Node, data []byte, err error) []annotation {
+	failed := ""
+	var e *schema.Error
+	if errors.As(err, &e) {
+		failed = e.Path
+	}
+	var list []annotation
+	var pos int64
+	var walk func(n *schema.Node, depth int)
+	walk = func(n *schema.Node, depth int) {
+		if n.Offset > pos {
+			list = append(list, unparsed(data, pos, n.Offset, depth))
+			pos = n.Offset
+		}
+		a := annotation{Path: n.Path, Type: n.Type, Depth: depth, Offset: n.Offset, Length: n.Length}
+		// The field that failed to decode has no value, but may have read
+		// some bytes.
+		if n.Children == nil && (n.Path == failed || n.Value != nil) {
+			a.Leaf = true
+			a.Raw = hex.EncodeToString(data[n.Offset : n.Offset+n.Length])
+			a.Value = n.Value
+			if b, ok := n.Value.([]byte); ok {
+				a.Value = hex.EncodeToString(b)
+			}
+			list = append(list, a)
+			pos = n.Offset + n.Length
+			return
+		}
+		list = append(list, a)
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+		if end := n.Offset + n.Length; end > pos {
+			list = append(list, unparsed(data, pos, end, depth+1))
+			pos = end
+		}
+	}
+	for _, c := range root.Children {
+		walk(c, 0)
+	}
+	if pos < int64(len(data)) {
+		list = append(list, unparsed(data, pos, int64(len(data)), 0))
+	}
+	return list
+}
+
+func unparsed(data []byte, from, to int64, depth int) annotation {
+	return annotation{
+		Type:   "unparsed",
+		Depth:  depth,
+		Offset: from,
+		Length: to - from,
+		Leaf:   true,
+		Raw:    hex.EncodeToString(data[from:to]),
+	}
+}
+
+// palette colours the fields in turn; containers are bold and unparsed bytes
+// dim.
+var palette = []string{"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m"}
+
+const (
+	bold  = "\x1b[1m"
+	dim   = "\x1b[2m"
+	reset = "\x1b[0m"
+)
+
+// writeText writes the annotations as a hexdump. Each field starts on a new
+// line, with its bytes wrapped at bytesPerLine and its name and value on the
+// first line.
+func writeText(w io.Writer, data []byte, list []annotation, colorize bool) error {
+	field := 0
+	for _, a := range list {
+		color := ""
+		switch {
+		case a.Type == "unparsed":
+			color = dim
+		case !a.Leaf:
+			color = bold
+		default:
+			color = palette[field%len(palette)]
+			field++
+		}
+		if !colorize {
+			color = ""
+		}
+		indent := strings.Repeat("  ", a.Depth)
+		label := fmt.Sprintf("%s%s: %s", indent, name(a), describe(a))
+		if !a.Leaf {
+			if err := line(w, a.Offset, nil, label, color); err != nil {
+				return err
+			}
+			continue
+		}
+		b := data[a.Offset : a.Offset+a.Length]
+		for first := true; first || len(b) > 0; first = false {
+			n := len(b)
+			if n > bytesPerLine {
+				n = bytesPerLine
+			}
+			if err := line(w, a.Offset, b[:n], label, color); err != nil {
+				return err
+			}
+			a.Offset += int64(n)
+			b = b[n:]
+			label = ""
+		}
+	}
+	return nil
+}
+
+// line writes one line of the dump.
+func line(w io.Writer, offset int64, b []byte, label, color string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%08x  ", offset)
+	h := make([]string, len(b))
+	for i, c := range b {
+		h[i] = fmt.Sprintf("%02x", c)
+	}
+	hexCol := fmt.Sprintf("%-*s", 3*bytesPerLine-1, strings.Join(h, " "))
+	if color != "" {
+		hexCol = color + hexCol + reset
+		if label != "" {
+			label = color + label + reset
+		}
+	}
+	sb.WriteString(hexCol)
+	if label != "" {
+		sb.WriteString("  ")
+		sb.WriteString(label)
+	}
+	_, err := io.WriteString(w, strings.TrimRight(sb.String(), " ")+"\n")
+	return err
+}
+
+func name(a annotation) string {
+	if a.Type == "unparsed" {
+		return "(unparsed)"
+	}
+	if i := strings.LastIndexByte(a.Path, '.'); i >= 0 {
+		return a.Path[i+1:]
+	}
+	return a.Path
+}
+
+// describe returns the type and value of a field, or the size of a container.
+func describe(a annotation) string {
+	switch {
+	case a.Type == "unparsed":
+		return size(a.Length)
+	case !a.Leaf:
+		return fmt.Sprintf("%s, %s", a.Type, size(a.Length))
+	}
+	switch v := a.Value.(type) {
+	case nil:
+		return fmt.Sprintf("%s, incomplete", a.Type)
+	case string:
+		if a.Type == "bytes" {
+			return fmt.Sprintf("%s, %s", a.Type, size(a.Length))
+		}
+		return fmt.Sprintf("%s = %q", a.Type, v)
+	case schema.EnumValue:
+		if v.Name == "" {
+			return fmt.Sprintf("%s = %d (unknown)", a.Type, v.Value)
+		}
+		return fmt.Sprintf("%s = %s (%d)", a.Type, v.Name, v.Value)
+	}
+	return fmt.Sprintf("%s = %v", a.Type, a.Value)
+}
+
+func size(n int64) string {
+	if n == 1 {
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// writeJSON writes the annotations, and the decode error if any, as JSON.
+func writeJSON(w io.Writer, file string, data []byte, list []annotation, decodeErr error) error {
+	out := struct {
+		File   string       `json:"file"`
+		Size   int          `json:"size"`
+		Fields []annotation `json:"fields"`
+		Error  string       `json:"error,omitempty"`
+	}{File: file, Size: len(data), Fields: list}
+	if out.Fields == nil {
+		out.Fields = []annotation{}
+	}
+	if decodeErr != nil {
+		out.Error = decodeErr.Error()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(out)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhuangsirui/binpacker"
+	"github.com/zhuangsirui/binpacker/schema"
+)
+
+const testSchema = `{
+	"enums": {"kind": {"1": "text"}},
+	"fields": [
+		{"name": "kind", "type": "uint8", "enum": "kind"},
+		{"name": "size", "type": "uint16"},
+		{"name": "body", "type": "struct", "length": "size", "fields": [
+			{"name": "name", "type": "string", "prefix": "uint8"}
+		]},
+		{"name": "data", "type": "bytes", "length": 18}
+	]
+}`
+
+var testData = []byte{
+	1,
+	0, 4,
+	2, 'h', 'i', 0xEE,
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17,
+	0xFF,
+}
+
+func decodeTest(t *testing.T, data []byte) ([]annotation, error) {
+	s, err := schema.Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := schema.DecodeNodes(s, binpacker.NewSliceUnpacker(binary.BigEndian, data).Unpacker)
+	return annotate(root, data, err), err
+}
+
+func TestAnnotate(t *testing.T) {
+	list, err := decodeTest(t, testData)
+	assert.NoError(t, err)
+	var got []string
+	for _, a := range list {
+		got = append(got, a.Path+" "+a.Type)
+	}
+	assert.Equal(t, got, []string{
+		"kind uint8", "size uint16", "body struct", "body.name string", " unparsed",
+		"data bytes", " unparsed",
+	}, "annotations error.")
+	assert.Equal(t, list[4].Offset, int64(6), "gap offset error.")
+	assert.Equal(t, list[4].Depth, 1, "gap depth error.")
+	assert.Equal(t, list[5].Raw, "000102030405060708090a0b0c0d0e0f1011", "raw error.")
+	assert.Equal(t, list[6].Raw, "ff", "trailing error.")
+}
+
+func TestWriteText(t *testing.T) {
+	list, err := decodeTest(t, testData)
+	assert.NoError(t, err)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, writeText(buf, testData, list, false))
+	assert.Equal(t, buf.String(), strings.Join([]string{
+		"00000000  01                                               kind: uint8 = text (1)",
+		"00000001  00 04                                            size: uint16 = 4",
+		"00000003                                                   body: struct, 4 bytes",
+		"00000003  02 68 69                                           name: string = \"hi\"",
+		"00000006  ee                                                 (unparsed): 1 byte",
+		"00000007  00 01 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f  data: bytes, 18 bytes",
+		"00000017  10 11",
+		"00000019  ff                                               (unparsed): 1 byte",
+		"",
+	}, "\n"), "text error.")
+
+	buf.Reset()
+	assert.NoError(t, writeText(buf, testData, list, true))
+	assert.Contains(t, buf.String(), "\x1b[31m01", "colour error.")
+	assert.Contains(t, buf.String(), "\x1b[32m00 04", "colour error.")
+}
+
+func TestWriteJSON(t *testing.T) {
+	list, decodeErr := decodeTest(t, testData[:5])
+	assert.Error(t, decodeErr)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, writeJSON(buf, "test.bin", testData[:5], list, decodeErr))
+	var out struct {
+		File   string
+		Size   int
+		Fields []annotation
+		Error  string
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, out.Size, 5, "size error.")
+	assert.Equal(t, out.Error, decodeErr.Error(), "error error.")
+	last := out.Fields[len(out.Fields)-1]
+	assert.Equal(t, last.Path, "body", "path error.")
+	assert.Equal(t, last.Leaf, true, "leaf error.")
+	assert.Equal(t, last.Raw, "0268", "raw error.")
+	assert.Nil(t, last.Value, "value error.")
+	assert.Equal(t, describe(last), "struct, incomplete", "describe error.")
+}
+
+func TestWriteTextIncomplete(t *testing.T) {
+	data := []byte{97}
+	list, err := decodeTest(t, data)
+	assert.Error(t, err)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, writeText(buf, data, list, false))
+	assert.Equal(t, buf.String(), strings.Join([]string{
+		"00000000  61                                               kind: uint8 = 97 (unknown)",
+		"00000001                                                   size: uint16, incomplete",
+		"",
+	}, "\n"), "text error.")
+}
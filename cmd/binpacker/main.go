@@ -0,0 +1,123 @@
+// Command binpacker inspects binary files.
+//
+// The dump command decodes a file with a schema and prints a hexdump annotated
+// with the field names, offsets, raw bytes and decoded values:
+//
+//	binpacker dump -schema packet.json packet.bin
+//
+// The schema is a schema package JSON file, or a Kaitai Struct file if its
+// name ends in .ksy. With -json, the annotations are written as JSON instead.
+// If decoding fails, the fields decoded so far are printed, followed by the
+// rest of the input as unparsed bytes, and the command exits with status 1.
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/zhuangsirui/binpacker"
+	"github.com/zhuangsirui/binpacker/schema"
+	"github.com/zhuangsirui/binpacker/schema/ksy"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: binpacker <command> [flags] [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "\tdump\tprint an annotated hexdump of a file decoded by a schema\n")
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("binpacker: ")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "dump":
+		os.Exit(runDump(args))
+	default:
+		log.Printf("unknown command %q", cmd)
+		usage()
+		os.Exit(2)
+	}
+}
+
+func runDump(args []string) int {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	schemaPath := fs.String("schema", "", "schema file, JSON or .ksy (required)")
+	asJSON := fs.Bool("json", false, "write the annotations as JSON")
+	color := fs.String("color", "auto", "colourise the output: auto, always or never")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: binpacker dump -schema file [flags] file.bin\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *schemaPath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	var colorize bool
+	switch *color {
+	case "auto":
+		colorize = isTerminal(os.Stdout)
+	case "always":
+		colorize = true
+	case "never":
+	default:
+		log.Printf("invalid -color %q", *color)
+		return 2
+	}
+
+	s, err := loadSchema(*schemaPath)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	u := binpacker.NewSliceUnpacker(binary.BigEndian, data)
+	root, decodeErr := schema.DecodeNodes(s, u.Unpacker)
+	list := annotate(root, data, decodeErr)
+	if *asJSON {
+		err = writeJSON(os.Stdout, fs.Arg(0), data, list, decodeErr)
+	} else {
+		err = writeText(os.Stdout, data, list, colorize)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if decodeErr != nil {
+		log.Print(decodeErr)
+		return 1
+	}
+	return 0
+}
+
+// loadSchema reads a JSON schema, or a Kaitai Struct file if path ends in
+// .ksy.
+func loadSchema(path string) (*schema.Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".ksy" {
+		return ksy.Parse(data)
+	}
+	return schema.Parse(data)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,237 @@
+package binpacker
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf8"
+)
+
+var (
+	// ErrStringTooLong is returned by PushFixedString for a string longer
+	// than the field, unless the Packer truncates long strings.
+	ErrStringTooLong = errors.New("binpacker: string too long for the field")
+	// ErrStringNUL is returned by PushCString for a string containing a NUL
+	// byte, which would end it early.
+	ErrStringNUL = errors.New("binpacker: string contains a NUL byte")
+	// ErrNoTerminator is returned by ShiftCString when no NUL byte follows
+	// within the maximum length.
+	ErrNoTerminator = errors.New("binpacker: no NUL terminator within the maximum length")
+)
+
+// StringOverflow is what PushFixedString does with a string longer than the
+// field.
+type StringOverflow int
+
+const (
+	// RejectLongStrings fails with ErrStringTooLong. This is the default.
+	RejectLongStrings StringOverflow = iota
+	// TruncateLongStrings cuts the string to the width of the field,
+	// without splitting a UTF-8 sequence.
+	TruncateLongStrings
+)
+
+// WithStringOverflow sets what PushFixedString does with over-long strings.
+func (p *Packer) WithStringOverflow(o StringOverflow) *Packer {
+	p.overflow = o
+	return p
+}
+
+// TrimMode is how ShiftFixedString trims the padding of a field.
+type TrimMode int
+
+const (
+	// TrimNone returns the whole field.
+	TrimNone TrimMode = iota
+	// TrimNUL cuts the field at the first NUL byte, as C does.
+	TrimNUL
+	// TrimSpace removes trailing spaces and NUL bytes.
+	TrimSpace
+)
+
+// PushCString write a string followed by a NUL byte into writer.
+func (p *Packer) PushCString(s string) *Packer {
+	return p.errFilter(func() {
+		if i := indexNUL(s); i >= 0 {
+			p.err = p.traceError(&Error{Op: "PushCString", Offset: p.offset + int64(i), Err: ErrStringNUL})
+			return
+		}
+		raw := p.writeBulk("PushCString", len(s)+1, func(b []byte) {
+			b[copy(b, s)] = 0
+		})
+		if p.tracer != nil {
+			p.tracePushed("PushCString", raw, s)
+		}
+	})
+}
+
+// PushFixedString write a string into a field of width bytes, padded with
+// pad. A longer string is rejected or truncated as set by WithStringOverflow.
+func (p *Packer) PushFixedString(s string, width uint64, pad byte) *Packer {
+	return p.errFilter(func() {
+		if uint64(len(s)) > width {
+			if p.overflow != TruncateLongStrings {
+				p.err = p.traceError(&Error{Op: "PushFixedString", Offset: p.offset, Want: width, Got: uint64(len(s)), Err: ErrStringTooLong})
+				return
+			}
+			s = truncate(s, int(width))
+		}
+		raw := p.writeBulk("PushFixedString", int(width), func(b []byte) {
+			for i := copy(b, s); i < len(b); i++ {
+				b[i] = pad
+			}
+		})
+		if p.tracer != nil {
+			p.tracePushed("PushFixedString", raw, s)
+		}
+	})
+}
+
+// truncate cuts s to at most n bytes, backing off to the start of a UTF-8
+// sequence that would be split.
+func truncate(s string, n int) string {
+	for i := n - 1; i >= 0 && i > n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			if _, size := utf8.DecodeRuneInString(s[i:]); i+size > n {
+				return s[:i]
+			}
+			break
+		}
+	}
+	return s[:n]
+}
+
+func indexNUL(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// ShiftCString fetch bytes up to a NUL byte and convert them to a string,
+// without the NUL. At most maxLen bytes are read before the NUL; if there is
+// none, the error is ErrNoTerminator.
+func (u *Unpacker) ShiftCString(maxLen uint64) (string, error) {
+	const op = "ShiftCString"
+	offset := u.offset
+	buffer, err := u.shiftTerminated(op, 1, maxLen)
+	if err != nil {
+		return "", err
+	}
+	if err := u.checkUTF8(op, offset, buffer[:len(buffer)-1]); err != nil {
+		return "", err
+	}
+	s := string(buffer[:len(buffer)-1])
+	if u.tracer != nil {
+		u.traceShifted(op, buffer, s)
+	}
+	return s, nil
+}
+
+// shiftTerminated reads units of size bytes up to and including a unit of
+// zeros, reading at most maxLen units before it.
+func (u *Unpacker) shiftTerminated(op string, size int, maxLen uint64) ([]byte, error) {
+	offset := u.offset
+	if u.slice {
+		rest := u.data[u.offset:]
+		limit := uint64(len(rest) / size)
+		if maxLen < limit {
+			limit = maxLen + 1
+		}
+		i := indexTerminator(rest[:limit*uint64(size)], size)
+		switch {
+		case i >= 0:
+			return u.shiftBytes(op, uint64((i+1)*size))
+		case limit > maxLen:
+			return nil, u.traceError(&Error{Op: op, Offset: offset, Got: limit * uint64(size), Err: ErrNoTerminator})
+		}
+		want := (limit + 1) * uint64(size)
+		return nil, u.traceError(&Error{Op: op, Offset: offset, Want: want, Got: uint64(len(rest)), Err: eofError(len(rest))})
+	}
+	var buffer []byte
+	for n := uint64(0); ; n++ {
+		if n > maxLen {
+			return nil, u.traceError(&Error{Op: op, Offset: offset, Got: uint64(len(buffer)), Err: ErrNoTerminator})
+		}
+		if err := u.checkField(uint64(len(buffer) + size)); err != nil {
+			return nil, u.traceError(&Error{Op: op, Offset: offset, Want: uint64(len(buffer) + size), Err: err})
+		}
+		zero := true
+		for j := 0; j < size; j++ {
+			b, err := u.shiftPartByte(op, len(buffer))
+			if err != nil {
+				// Report the whole field, as in slice mode, rather than
+				// the byte that could not be read.
+				if e, ok := err.(*Error); ok {
+					e.Offset, e.Want, e.Got = offset, (n+1)*uint64(size), uint64(len(buffer))
+				}
+				return nil, u.traceError(err)
+			}
+			buffer = append(buffer, b)
+			zero = zero && b == 0
+		}
+		if zero {
+			break
+		}
+	}
+	return buffer, nil
+}
+
+// indexTerminator returns the index of the first unit of zeros in b, for
+// units of size 1 or 2, or -1.
+func indexTerminator(b []byte, size int) int {
+	if size == 1 {
+		return bytes.IndexByte(b, 0)
+	}
+	for i := 0; i+size <= len(b); i += size {
+		if b[i] == 0 && b[i+1] == 0 {
+			return i / size
+		}
+	}
+	return -1
+}
+
+// FetchCString read a NUL-terminated string of at most maxLen bytes and set it
+// to s.
+func (u *Unpacker) FetchCString(maxLen uint64, s *string) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.ShiftCString(maxLen)
+	})
+}
+
+// ShiftFixedString fetch a field of width bytes and convert it to a string,
+// trimmed according to trim.
+func (u *Unpacker) ShiftFixedString(width uint64, trim TrimMode) (string, error) {
+	const op = "ShiftFixedString"
+	offset := u.offset
+	raw, err := u.shiftBytes(op, width)
+	if err != nil {
+		return "", err
+	}
+	buffer := raw
+	switch trim {
+	case TrimNUL:
+		if i := bytes.IndexByte(buffer, 0); i >= 0 {
+			buffer = buffer[:i]
+		}
+	case TrimSpace:
+		buffer = bytes.TrimRight(buffer, " \x00")
+	}
+	if err := u.checkUTF8(op, offset, buffer); err != nil {
+		return "", err
+	}
+	s := string(buffer)
+	if u.tracer != nil {
+		u.traceShifted(op, raw, s)
+	}
+	return s, nil
+}
+
+// FetchFixedString read a field of width bytes, trim it according to trim and
+// set it to s.
+func (u *Unpacker) FetchFixedString(width uint64, trim TrimMode, s *string) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.ShiftFixedString(width, trim)
+	})
+}
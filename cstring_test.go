@@ -0,0 +1,118 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushCString(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, buf)
+	p.PushCString("abc").PushCString("")
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, buf.Bytes(), []byte{'a', 'b', 'c', 0, 0}, "cstring error.")
+
+	p.PushCString("a\x00b")
+	var e *Error
+	assert.True(t, errors.As(p.Error(), &e), "error type error.")
+	assert.Equal(t, e.Err, ErrStringNUL, "nul error.")
+	assert.Equal(t, e.Offset, int64(6), "offset error.")
+}
+
+func TestShiftCString(t *testing.T) {
+	data := []byte{'a', 'b', 'c', 0, 0, 'x', 'y'}
+	for _, u := range []*Unpacker{
+		NewSliceUnpacker(binary.BigEndian, data).Unpacker,
+		NewUnpacker(binary.BigEndian, bytes.NewReader(data)),
+	} {
+		var s string
+		u.FetchCString(3, &s)
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, s, "abc", "cstring error.")
+		s, err := u.ShiftCString(0)
+		assert.Nil(t, err, "Has error.")
+		assert.Equal(t, s, "", "empty error.")
+		assert.Equal(t, u.Offset(), int64(5), "offset error.")
+		_, err = u.ShiftCString(10)
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+		var e *Error
+		assert.True(t, errors.As(err, &e), "error type error.")
+		assert.Equal(t, e.Offset, int64(5), "eof offset error.")
+		assert.Equal(t, e.Want, uint64(3), "eof want error.")
+		assert.Equal(t, e.Got, uint64(2), "eof got error.")
+	}
+
+	for _, u := range []*Unpacker{
+		NewSliceUnpacker(binary.BigEndian, data).Unpacker,
+		NewUnpacker(binary.BigEndian, bytes.NewReader(data)),
+	} {
+		_, err := u.ShiftCString(2)
+		var e *Error
+		assert.True(t, errors.As(err, &e), "error type error.")
+		assert.Equal(t, e.Err, ErrNoTerminator, "terminator error.")
+		assert.Equal(t, e.Op, "ShiftCString", "op error.")
+		assert.Equal(t, e.Offset, int64(0), "offset error.")
+	}
+
+	u := NewSliceUnpacker(binary.BigEndian, nil)
+	_, err := u.ShiftCString(2)
+	assert.True(t, errors.Is(err, io.EOF), "eof error.")
+
+	lu := NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(data), UnpackerOptions{MaxBytesPerField: 2})
+	_, err = lu.ShiftCString(10)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), "limit error.")
+}
+
+func TestPushFixedString(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushFixedString("ab", 4, 0).PushFixedString("cd", 3, ' ').PushFixedString("efg", 3, 0)
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte("ab\x00\x00cd efg"), "fixed string error.")
+
+	p.PushFixedString("toolong", 4, 0)
+	var e *Error
+	assert.True(t, errors.As(p.Error(), &e), "error type error.")
+	assert.Equal(t, e.Err, ErrStringTooLong, "overflow error.")
+	assert.Equal(t, e.Want, uint64(4), "overflow error.")
+	assert.Equal(t, e.Got, uint64(7), "overflow error.")
+
+	p = NewAppendPacker(binary.BigEndian, nil).WithStringOverflow(TruncateLongStrings)
+	p.PushFixedString("toolong", 4, 0).
+		PushFixedString("aé", 2, ' ').
+		PushFixedString("a€b", 3, 0).
+		PushFixedString("a\xffb", 2, 0)
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte("toola a\x00\x00a\xff"), "truncate error.")
+}
+
+func TestShiftFixedString(t *testing.T) {
+	data := []byte("ab\x00c  xy \x00\x00 ")
+	u := NewSliceUnpacker(binary.BigEndian, data)
+	var a, b, c string
+	u.FetchFixedString(4, TrimNUL, &a).
+		FetchFixedString(2, TrimNone, &b).
+		FetchFixedString(6, TrimSpace, &c)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, []string{a, b, c}, []string{"ab", "  ", "xy"}, "fixed string error.")
+
+	_, err := u.ShiftFixedString(1, TrimNone)
+	assert.True(t, errors.Is(err, io.EOF), "eof error.")
+}
+
+func TestTraceCString(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushCString("hi").PushFixedString("a", 2, ' ')
+	u := NewUnpacker(binary.BigEndian, bytes.NewReader(p.Bytes())).WithTracer(tr)
+	u.ShiftCString(8)
+	assert.Equal(t, tr.lines, []string{
+		"push PushCString 0 68 69 00 hi",
+		"push PushFixedString 3 61 20 a",
+		"shift ShiftCString 0 68 69 00 hi",
+	}, "trace error.")
+}
@@ -0,0 +1,330 @@
+package binpacker
+
+import "encoding/binary"
+
+// WithEndian runs f with the byte order of p set to order, and restores it
+// afterwards. f gets p itself, so it shares the writer, offset and error.
+// Placeholders reserved in f are filled in order.
+func (p *Packer) WithEndian(order binary.ByteOrder, f func(*Packer)) *Packer {
+	return p.errFilter(func() {
+		endian := p.endian
+		p.endian = order
+		defer func() { p.endian = endian }()
+		f(p)
+	})
+}
+
+// WithEndian runs f with the byte order of u set to order, and restores it
+// afterwards. f gets u itself, so it shares the reader, offset and error.
+func (u *Unpacker) WithEndian(order binary.ByteOrder, f func(*Unpacker)) *Unpacker {
+	return u.errFilter(func() {
+		endian := u.endian
+		u.endian = order
+		defer func() { u.endian = endian }()
+		f(u)
+	})
+}
+
+// PushUint16LE write a uint16 into writer in little-endian order.
+func (p *Packer) PushUint16LE(i uint16) *Packer {
+	return pushUint16(p, "PushUint16LE", binary.LittleEndian, i)
+}
+
+// PushUint16BE write a uint16 into writer in big-endian order.
+func (p *Packer) PushUint16BE(i uint16) *Packer {
+	return pushUint16(p, "PushUint16BE", binary.BigEndian, i)
+}
+
+// PushInt16LE write a int16 into writer in little-endian order.
+func (p *Packer) PushInt16LE(i int16) *Packer {
+	return pushUint16(p, "PushInt16LE", binary.LittleEndian, i)
+}
+
+// PushInt16BE write a int16 into writer in big-endian order.
+func (p *Packer) PushInt16BE(i int16) *Packer {
+	return pushUint16(p, "PushInt16BE", binary.BigEndian, i)
+}
+
+// PushUint32LE write a uint32 into writer in little-endian order.
+func (p *Packer) PushUint32LE(i uint32) *Packer {
+	return pushUint32(p, "PushUint32LE", binary.LittleEndian, i)
+}
+
+// PushUint32BE write a uint32 into writer in big-endian order.
+func (p *Packer) PushUint32BE(i uint32) *Packer {
+	return pushUint32(p, "PushUint32BE", binary.BigEndian, i)
+}
+
+// PushInt32LE write a int32 into writer in little-endian order.
+func (p *Packer) PushInt32LE(i int32) *Packer {
+	return pushUint32(p, "PushInt32LE", binary.LittleEndian, i)
+}
+
+// PushInt32BE write a int32 into writer in big-endian order.
+func (p *Packer) PushInt32BE(i int32) *Packer {
+	return pushUint32(p, "PushInt32BE", binary.BigEndian, i)
+}
+
+// PushUint64LE write a uint64 into writer in little-endian order.
+func (p *Packer) PushUint64LE(i uint64) *Packer {
+	return pushUint64(p, "PushUint64LE", binary.LittleEndian, i)
+}
+
+// PushUint64BE write a uint64 into writer in big-endian order.
+func (p *Packer) PushUint64BE(i uint64) *Packer {
+	return pushUint64(p, "PushUint64BE", binary.BigEndian, i)
+}
+
+// PushInt64LE write a int64 into writer in little-endian order.
+func (p *Packer) PushInt64LE(i int64) *Packer {
+	return pushUint64(p, "PushInt64LE", binary.LittleEndian, i)
+}
+
+// PushInt64BE write a int64 into writer in big-endian order.
+func (p *Packer) PushInt64BE(i int64) *Packer {
+	return pushUint64(p, "PushInt64BE", binary.BigEndian, i)
+}
+
+// PushFloat32LE write a float32 into writer in little-endian order.
+func (p *Packer) PushFloat32LE(i float32) *Packer {
+	return pushUint32(p, "PushFloat32LE", binary.LittleEndian, i)
+}
+
+// PushFloat32BE write a float32 into writer in big-endian order.
+func (p *Packer) PushFloat32BE(i float32) *Packer {
+	return pushUint32(p, "PushFloat32BE", binary.BigEndian, i)
+}
+
+// PushFloat64LE write a float64 into writer in little-endian order.
+func (p *Packer) PushFloat64LE(i float64) *Packer {
+	return pushUint64(p, "PushFloat64LE", binary.LittleEndian, i)
+}
+
+// PushFloat64BE write a float64 into writer in big-endian order.
+func (p *Packer) PushFloat64BE(i float64) *Packer {
+	return pushUint64(p, "PushFloat64BE", binary.BigEndian, i)
+}
+
+// ShiftUint16LE fetch 2 bytes in io.Reader and convert it to uint16 in
+// little-endian order.
+func (u *Unpacker) ShiftUint16LE() (uint16, error) {
+	return shiftUint16[uint16](u, "ShiftUint16LE", binary.LittleEndian)
+}
+
+// FetchUint16LE read 2 bytes, convert it to uint16 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchUint16LE(i *uint16) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftUint16LE()
+	})
+}
+
+// ShiftUint16BE fetch 2 bytes in io.Reader and convert it to uint16 in
+// big-endian order.
+func (u *Unpacker) ShiftUint16BE() (uint16, error) {
+	return shiftUint16[uint16](u, "ShiftUint16BE", binary.BigEndian)
+}
+
+// FetchUint16BE read 2 bytes, convert it to uint16 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchUint16BE(i *uint16) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftUint16BE()
+	})
+}
+
+// ShiftInt16LE fetch 2 bytes in io.Reader and convert it to int16 in
+// little-endian order.
+func (u *Unpacker) ShiftInt16LE() (int16, error) {
+	return shiftUint16[int16](u, "ShiftInt16LE", binary.LittleEndian)
+}
+
+// FetchInt16LE read 2 bytes, convert it to int16 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchInt16LE(i *int16) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt16LE()
+	})
+}
+
+// ShiftInt16BE fetch 2 bytes in io.Reader and convert it to int16 in
+// big-endian order.
+func (u *Unpacker) ShiftInt16BE() (int16, error) {
+	return shiftUint16[int16](u, "ShiftInt16BE", binary.BigEndian)
+}
+
+// FetchInt16BE read 2 bytes, convert it to int16 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchInt16BE(i *int16) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt16BE()
+	})
+}
+
+// ShiftUint32LE fetch 4 bytes in io.Reader and convert it to uint32 in
+// little-endian order.
+func (u *Unpacker) ShiftUint32LE() (uint32, error) {
+	return shiftUint32[uint32](u, "ShiftUint32LE", binary.LittleEndian)
+}
+
+// FetchUint32LE read 4 bytes, convert it to uint32 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchUint32LE(i *uint32) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftUint32LE()
+	})
+}
+
+// ShiftUint32BE fetch 4 bytes in io.Reader and convert it to uint32 in
+// big-endian order.
+func (u *Unpacker) ShiftUint32BE() (uint32, error) {
+	return shiftUint32[uint32](u, "ShiftUint32BE", binary.BigEndian)
+}
+
+// FetchUint32BE read 4 bytes, convert it to uint32 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchUint32BE(i *uint32) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftUint32BE()
+	})
+}
+
+// ShiftInt32LE fetch 4 bytes in io.Reader and convert it to int32 in
+// little-endian order.
+func (u *Unpacker) ShiftInt32LE() (int32, error) {
+	return shiftUint32[int32](u, "ShiftInt32LE", binary.LittleEndian)
+}
+
+// FetchInt32LE read 4 bytes, convert it to int32 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchInt32LE(i *int32) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt32LE()
+	})
+}
+
+// ShiftInt32BE fetch 4 bytes in io.Reader and convert it to int32 in
+// big-endian order.
+func (u *Unpacker) ShiftInt32BE() (int32, error) {
+	return shiftUint32[int32](u, "ShiftInt32BE", binary.BigEndian)
+}
+
+// FetchInt32BE read 4 bytes, convert it to int32 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchInt32BE(i *int32) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt32BE()
+	})
+}
+
+// ShiftUint64LE fetch 8 bytes in io.Reader and convert it to uint64 in
+// little-endian order.
+func (u *Unpacker) ShiftUint64LE() (uint64, error) {
+	return shiftUint64[uint64](u, "ShiftUint64LE", binary.LittleEndian)
+}
+
+// FetchUint64LE read 8 bytes, convert it to uint64 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchUint64LE(i *uint64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftUint64LE()
+	})
+}
+
+// ShiftUint64BE fetch 8 bytes in io.Reader and convert it to uint64 in
+// big-endian order.
+func (u *Unpacker) ShiftUint64BE() (uint64, error) {
+	return shiftUint64[uint64](u, "ShiftUint64BE", binary.BigEndian)
+}
+
+// FetchUint64BE read 8 bytes, convert it to uint64 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchUint64BE(i *uint64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftUint64BE()
+	})
+}
+
+// ShiftInt64LE fetch 8 bytes in io.Reader and convert it to int64 in
+// little-endian order.
+func (u *Unpacker) ShiftInt64LE() (int64, error) {
+	return shiftUint64[int64](u, "ShiftInt64LE", binary.LittleEndian)
+}
+
+// FetchInt64LE read 8 bytes, convert it to int64 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchInt64LE(i *int64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt64LE()
+	})
+}
+
+// ShiftInt64BE fetch 8 bytes in io.Reader and convert it to int64 in
+// big-endian order.
+func (u *Unpacker) ShiftInt64BE() (int64, error) {
+	return shiftUint64[int64](u, "ShiftInt64BE", binary.BigEndian)
+}
+
+// FetchInt64BE read 8 bytes, convert it to int64 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchInt64BE(i *int64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt64BE()
+	})
+}
+
+// ShiftFloat32LE fetch 4 bytes in io.Reader and convert it to float32 in
+// little-endian order.
+func (u *Unpacker) ShiftFloat32LE() (float32, error) {
+	return shiftUint32[float32](u, "ShiftFloat32LE", binary.LittleEndian)
+}
+
+// FetchFloat32LE read 4 bytes, convert it to float32 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchFloat32LE(i *float32) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftFloat32LE()
+	})
+}
+
+// ShiftFloat32BE fetch 4 bytes in io.Reader and convert it to float32 in
+// big-endian order.
+func (u *Unpacker) ShiftFloat32BE() (float32, error) {
+	return shiftUint32[float32](u, "ShiftFloat32BE", binary.BigEndian)
+}
+
+// FetchFloat32BE read 4 bytes, convert it to float32 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchFloat32BE(i *float32) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftFloat32BE()
+	})
+}
+
+// ShiftFloat64LE fetch 8 bytes in io.Reader and convert it to float64 in
+// little-endian order.
+func (u *Unpacker) ShiftFloat64LE() (float64, error) {
+	return shiftUint64[float64](u, "ShiftFloat64LE", binary.LittleEndian)
+}
+
+// FetchFloat64LE read 8 bytes, convert it to float64 in little-endian order
+// and set it to i.
+func (u *Unpacker) FetchFloat64LE(i *float64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftFloat64LE()
+	})
+}
+
+// ShiftFloat64BE fetch 8 bytes in io.Reader and convert it to float64 in
+// big-endian order.
+func (u *Unpacker) ShiftFloat64BE() (float64, error) {
+	return shiftUint64[float64](u, "ShiftFloat64BE", binary.BigEndian)
+}
+
+// FetchFloat64BE read 8 bytes, convert it to float64 in big-endian order
+// and set it to i.
+func (u *Unpacker) FetchFloat64BE(i *float64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftFloat64BE()
+	})
+}
@@ -0,0 +1,126 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushEndian(t *testing.T) {
+	// The Packer's own byte order must not matter.
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		p := NewAppendPacker(order, nil)
+		p.PushUint16LE(0x0102).PushUint16BE(0x0102).
+			PushInt16LE(-2).PushInt16BE(-2).
+			PushUint32LE(0x01020304).PushUint32BE(0x01020304).
+			PushInt32LE(-2).PushInt32BE(-2).
+			PushUint64LE(0x0102030405060708).PushUint64BE(0x0102030405060708).
+			PushInt64LE(-2).PushInt64BE(-2).
+			PushFloat32LE(1.5).PushFloat32BE(1.5).
+			PushFloat64LE(1.5).PushFloat64BE(1.5)
+		assert.Nil(t, p.Error(), "Has error.")
+		assert.Equal(t, p.Bytes(), []byte{
+			0x02, 0x01, 0x01, 0x02,
+			0xFE, 0xFF, 0xFF, 0xFE,
+			0x04, 0x03, 0x02, 0x01, 0x01, 0x02, 0x03, 0x04,
+			0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE,
+			0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE,
+			0x00, 0x00, 0xC0, 0x3F, 0x3F, 0xC0, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x3F, 0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}, "endian error.")
+
+		u := NewSliceUnpacker(order, p.Bytes())
+		var (
+			u16le, u16be uint16
+			i16le, i16be int16
+			u32le, u32be uint32
+			i32le, i32be int32
+			u64le, u64be uint64
+			i64le, i64be int64
+			f32le, f32be float32
+		)
+		u.FetchUint16LE(&u16le).FetchUint16BE(&u16be).
+			FetchInt16LE(&i16le).FetchInt16BE(&i16be).
+			FetchUint32LE(&u32le).FetchUint32BE(&u32be).
+			FetchInt32LE(&i32le).FetchInt32BE(&i32be).
+			FetchUint64LE(&u64le).FetchUint64BE(&u64be).
+			FetchInt64LE(&i64le).FetchInt64BE(&i64be).
+			FetchFloat32LE(&f32le).FetchFloat32BE(&f32be)
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, []uint16{u16le, u16be}, []uint16{0x0102, 0x0102}, "uint16 error.")
+		assert.Equal(t, []int16{i16le, i16be}, []int16{-2, -2}, "int16 error.")
+		assert.Equal(t, []uint32{u32le, u32be}, []uint32{0x01020304, 0x01020304}, "uint32 error.")
+		assert.Equal(t, []int32{i32le, i32be}, []int32{-2, -2}, "int32 error.")
+		assert.Equal(t, []uint64{u64le, u64be}, []uint64{0x0102030405060708, 0x0102030405060708}, "uint64 error.")
+		assert.Equal(t, []int64{i64le, i64be}, []int64{-2, -2}, "int64 error.")
+		assert.Equal(t, []float32{f32le, f32be}, []float32{1.5, 1.5}, "float32 error.")
+		f64le, err := u.ShiftFloat64LE()
+		assert.Nil(t, err, "Has error.")
+		f64be, err := u.ShiftFloat64BE()
+		assert.Nil(t, err, "Has error.")
+		assert.Equal(t, []float64{f64le, f64be}, []float64{1.5, 1.5}, "float64 error.")
+	}
+}
+
+func TestShiftEndianError(t *testing.T) {
+	u := NewUnpacker(binary.BigEndian, bytes.NewReader([]byte{1}))
+	_, err := u.ShiftUint32LE()
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Op, "ShiftUint32LE", "op error.")
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+}
+
+func TestWithEndian(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushUint16(1)
+	p.WithEndian(binary.LittleEndian, func(p *Packer) {
+		n := p.Reserve(2)
+		p.PushUint16(2).PushUint32BE(3)
+		n.Fill(4)
+	})
+	p.PushUint16(5)
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Offset(), int64(12), "offset error.")
+	assert.Equal(t, p.Bytes(), []byte{0, 1, 4, 0, 2, 0, 0, 0, 0, 3, 0, 5}, "endian error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes())
+	var a, b, c, d uint16
+	var e uint32
+	u.FetchUint16(&a)
+	u.WithEndian(binary.LittleEndian, func(u *Unpacker) {
+		u.FetchUint16(&b).FetchUint16(&c).FetchUint32BE(&e)
+	})
+	u.FetchUint16(&d)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, []uint16{a, b, c, d}, []uint16{1, 4, 2, 5}, "endian error.")
+	assert.Equal(t, e, uint32(3), "endian error.")
+
+	// The error is sticky across the scope.
+	u = NewSliceUnpacker(binary.BigEndian, []byte{1})
+	called := false
+	u.WithEndian(binary.LittleEndian, func(u *Unpacker) {
+		u.FetchUint16(&a)
+	})
+	u.WithEndian(binary.LittleEndian, func(u *Unpacker) {
+		called = true
+	})
+	assert.NotNil(t, u.Error(), "sticky error.")
+	assert.False(t, called, "sticky error.")
+	assert.Equal(t, u.endian, binary.ByteOrder(binary.BigEndian), "restore error.")
+}
+
+func TestTraceEndian(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushUint16LE(0x0102).PushFloat32LE(1.5)
+	assert.Equal(t, tr.lines, []string{
+		"push PushUint16LE 0 02 01 258",
+		"push PushFloat32LE 2 00 00 c0 3f 1.5",
+	}, "trace error.")
+}
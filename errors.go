@@ -0,0 +1,55 @@
+package binpacker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error records a failed Packer or Unpacker operation and where it happened.
+// It wraps the underlying error, so errors.Is(err, io.ErrUnexpectedEOF) and
+// similar checks keep working.
+type Error struct {
+	Op     string // operation, e.g. "ShiftUint32"
+	Offset int64  // offset at which the operation started
+	Want   uint64 // number of bytes the operation wanted to read or write
+	Got    uint64 // number of bytes actually read or written
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "binpacker: ")
+	if e.Want > 0 && e.Got < e.Want {
+		return fmt.Sprintf("binpacker: %s at offset %d: want %d bytes, got %d: %s", e.Op, e.Offset, e.Want, e.Got, msg)
+	}
+	return fmt.Sprintf("binpacker: %s at offset %d: %s", e.Op, e.Offset, msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (p *Packer) fail(op string, err error) {
+	p.err = p.traceError(&Error{Op: op, Offset: p.offset, Err: err})
+}
+
+func (u *Unpacker) fail(op string, err error) {
+	u.err = u.traceError(&Error{Op: op, Offset: u.offset, Err: err})
+}
+
+// Fail sets err, raised by op at the current offset, as the error of p unless
+// it already has one. It lets methods written outside the package, such as
+// those binpacker-gen generates, fail the way the Push methods do.
+func (p *Packer) Fail(op string, err error) *Packer {
+	return p.errFilter(func() {
+		p.fail(op, err)
+	})
+}
+
+// Fail sets err, raised by op at the current offset, as the error of u unless
+// it already has one.
+func (u *Unpacker) Fail(op string, err error) *Unpacker {
+	return u.errFilter(func() {
+		u.fail(op, err)
+	})
+}
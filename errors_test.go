@@ -0,0 +1,87 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type limitedWriter struct {
+	n int
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if len(b) > w.n {
+		n := w.n
+		w.n = 0
+		return n, io.ErrClosedPipe
+	}
+	w.n -= len(b)
+	return len(b), nil
+}
+
+func TestUnpackerOffset(t *testing.T) {
+	buf := bytes.NewReader([]byte{0, 1, 0, 0, 0, 2, 3})
+	u := NewUnpacker(binary.BigEndian, buf)
+	var i16 uint16
+	var i32 uint32
+	u.FetchUint16(&i16)
+	assert.Equal(t, u.Offset(), int64(2), "offset error.")
+	u.FetchUint32(&i32)
+	assert.Equal(t, u.Offset(), int64(6), "offset error.")
+	u.FetchUint32(&i32)
+	assert.Equal(t, u.Offset(), int64(7), "offset error.")
+
+	err := u.Error()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "EOF error.")
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, &Error{Op: "ShiftUint32", Offset: 6, Want: 4, Got: 1, Err: io.ErrUnexpectedEOF}, e, "error error.")
+	assert.Equal(t, "binpacker: ShiftUint32 at offset 6: want 4 bytes, got 1: unexpected EOF", err.Error(), "message error.")
+}
+
+func TestUnpackerErrorOp(t *testing.T) {
+	_, err := NewUnpacker(binary.BigEndian, bytes.NewReader(nil)).ShiftInt64()
+	assert.Equal(t, "ShiftInt64", err.(*Error).Op, "op error.")
+	_, err = NewUnpacker(binary.BigEndian, bytes.NewReader(nil)).ShiftFloat32()
+	assert.Equal(t, "ShiftFloat32", err.(*Error).Op, "op error.")
+	_, err = NewUnpacker(binary.BigEndian, bytes.NewReader([]byte{1})).ShiftString(2)
+	assert.Equal(t, "ShiftString", err.(*Error).Op, "op error.")
+}
+
+func TestPackerOffset(t *testing.T) {
+	p := NewPacker(binary.BigEndian, &limitedWriter{n: 5})
+	p.PushUint16(1)
+	assert.Equal(t, p.Offset(), int64(2), "offset error.")
+	p.PushInt32(1)
+	assert.Equal(t, p.Offset(), int64(5), "offset error.")
+
+	err := p.Error()
+	assert.ErrorIs(t, err, io.ErrClosedPipe, "write error.")
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, &Error{Op: "PushInt32", Offset: 2, Want: 4, Got: 3, Err: io.ErrClosedPipe}, e, "error error.")
+}
+
+func TestErrorWithoutIO(t *testing.T) {
+	p := NewPacker(binary.BigEndian, new(bytes.Buffer))
+	p.PushUint8(1).PushStringWithUint8Prefix(string(make([]byte, 256)))
+	assert.Equal(t, "binpacker: PushStringWithUint8Prefix at offset 1: length overflows the prefix", p.Error().Error(), "message error.")
+}
+
+func TestFail(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushUint8(1).Fail("PackTo", ErrLengthMismatch).Fail("PackTo", ErrValueOverflow).PushUint8(2)
+	assert.Equal(t, &Error{Op: "PackTo", Offset: 1, Err: ErrLengthMismatch}, p.Error(), "fail error.")
+	assert.Equal(t, []byte{1}, p.Bytes(), "write error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, []byte{1, 2})
+	var a, b uint8
+	u.FetchUint8(&a).Fail("UnpackFrom", ErrValueOverflow).FetchUint8(&b)
+	assert.Equal(t, &Error{Op: "UnpackFrom", Offset: 1, Err: ErrValueOverflow}, u.Error(), "fail error.")
+	assert.Equal(t, uint8(0), b, "read error.")
+}
@@ -0,0 +1,218 @@
+package binpacker
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// ErrInvalidFixed is returned for a fixed-point format with a negative number
+// of bits, no bits at all, or more than 63 bits besides the sign.
+var ErrInvalidFixed = errors.New("binpacker: invalid fixed-point format")
+
+// FixedOverflow is what PushFixed and PushFixedValue do with a value outside
+// the range of its fixed-point format.
+type FixedOverflow int
+
+const (
+	// RejectOutOfRange fails with ErrValueOverflow. This is the default.
+	RejectOutOfRange FixedOverflow = iota
+	// SaturateOutOfRange writes the nearest representable value instead.
+	SaturateOutOfRange
+)
+
+// WithFixedOverflow sets what the fixed-point methods do with out-of-range
+// values. NaN is always rejected.
+func (p *Packer) WithFixedOverflow(o FixedOverflow) *Packer {
+	p.fixed = o
+	return p
+}
+
+// FixedOverflow returns what p does with out-of-range fixed-point values, as
+// set by WithFixedOverflow.
+func (p *Packer) FixedOverflow() FixedOverflow {
+	return p.fixed
+}
+
+// Fixed is a fixed-point number in Q format, kept as its exact raw integer.
+// Its value is Raw / 2^FracBits. A signed format has a sign bit besides
+// IntBits and FracBits, so Q15 is {IntBits: 0, FracBits: 15, Signed: true}
+// and takes 16 bits. The bits are written in as few whole bytes as hold them.
+type Fixed struct {
+	Raw      int64
+	IntBits  int
+	FracBits int
+	Signed   bool
+}
+
+// Float64 returns the value of f.
+func (f Fixed) Float64() float64 {
+	return math.Ldexp(float64(f.Raw), -f.FracBits)
+}
+
+// String returns the value of f in decimal.
+func (f Fixed) String() string {
+	return strconv.FormatFloat(f.Float64(), 'g', -1, 64)
+}
+
+// bits returns the number of bits of the format of f, or 0 if it is invalid.
+func (f Fixed) bits() int {
+	n := f.IntBits + f.FracBits
+	if f.IntBits < 0 || f.FracBits < 0 || n < 1 || n > 63 {
+		return 0
+	}
+	if f.Signed {
+		n++
+	}
+	return n
+}
+
+// bounds returns the smallest and largest raw values of the format of f.
+func (f Fixed) bounds() (lo, hi int64) {
+	hi = 1<<uint(f.IntBits+f.FracBits) - 1
+	if f.Signed {
+		lo = -hi - 1
+	}
+	return lo, hi
+}
+
+// fixedFromFloat rounds value to nearest even in the format of f. ok is false
+// if value is out of range, in which case the raw value is saturated.
+func fixedFromFloat(value float64, f Fixed) (Fixed, bool) {
+	lo, hi := f.bounds()
+	r := math.RoundToEven(math.Ldexp(value, f.FracBits))
+	// Compare with a power of two, as float64(hi) may round up.
+	limit := math.Ldexp(1, f.IntBits+f.FracBits)
+	switch {
+	case r >= limit:
+		f.Raw = hi
+		return f, false
+	case f.Signed && r < -limit, !f.Signed && r < 0:
+		f.Raw = lo
+		return f, false
+	}
+	f.Raw = int64(r)
+	return f, true
+}
+
+// pushFixed writes f, applying the overflow policy o to a raw value out of
+// range or one that ok reports so.
+func (p *Packer) pushFixed(op string, f Fixed, ok bool, o FixedOverflow) {
+	bits := f.bits()
+	if bits == 0 {
+		p.fail(op, ErrInvalidFixed)
+		return
+	}
+	if lo, hi := f.bounds(); f.Raw < lo || f.Raw > hi {
+		ok = false
+		if f.Raw < lo {
+			f.Raw = lo
+		} else {
+			f.Raw = hi
+		}
+	}
+	if !ok && o != SaturateOutOfRange {
+		p.fail(op, ErrValueOverflow)
+		return
+	}
+	raw := p.pushUintN(op, uint64(f.Raw), (bits+7)/8)
+	if p.tracer != nil {
+		p.tracePushed(op, raw, f)
+	}
+}
+
+// PushFixed write value as a Q-format fixed-point number into writer, rounded
+// to nearest even. intBits and fracBits exclude the sign bit of a signed
+// format. A value out of range is rejected or saturated as set by
+// WithFixedOverflow.
+func (p *Packer) PushFixed(value float64, intBits, fracBits int, signed bool) *Packer {
+	return p.errFilter(func() {
+		p.pushFixedFloat(value, Fixed{IntBits: intBits, FracBits: fracBits, Signed: signed}, p.fixed)
+	})
+}
+
+// pushFixedFloat writes value in the format of f, applying the overflow policy
+// o.
+func (p *Packer) pushFixedFloat(value float64, f Fixed, o FixedOverflow) {
+	const op = "PushFixed"
+	if f.bits() == 0 {
+		p.fail(op, ErrInvalidFixed)
+		return
+	}
+	if math.IsNaN(value) {
+		p.fail(op, ErrValueOverflow)
+		return
+	}
+	f, ok := fixedFromFloat(value, f)
+	p.pushFixed(op, f, ok, o)
+}
+
+// PushFixedValue write the raw value of f into writer. A raw value out of the
+// range of the format is rejected or saturated as set by WithFixedOverflow.
+func (p *Packer) PushFixedValue(f Fixed) *Packer {
+	return p.errFilter(func() {
+		p.pushFixed("PushFixedValue", f, true, p.fixed)
+	})
+}
+
+// shiftFixed reads a raw value in the format of f and traces it. Padding bits
+// above a format narrower than its bytes must be a sign extension or zero.
+func (u *Unpacker) shiftFixed(op string, f Fixed) (Fixed, error) {
+	bits := f.bits()
+	if bits == 0 {
+		return f, u.traceError(&Error{Op: op, Offset: u.offset, Err: ErrInvalidFixed})
+	}
+	offset := u.offset
+	width := (bits + 7) / 8
+	v, raw, err := u.shiftUintN(op, width)
+	if err != nil {
+		return f, err
+	}
+	if f.Signed {
+		f.Raw = signExtend(v, width)
+	} else {
+		f.Raw = int64(v)
+	}
+	if lo, hi := f.bounds(); f.Raw < lo || f.Raw > hi {
+		return f, u.traceError(&Error{Op: op, Offset: offset, Err: ErrValueOverflow})
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, raw, f)
+	}
+	return f, nil
+}
+
+// ShiftFixed fetch a Q-format fixed-point number in io.Reader and convert it
+// to float64. intBits and fracBits exclude the sign bit of a signed format.
+func (u *Unpacker) ShiftFixed(intBits, fracBits int, signed bool) (float64, error) {
+	f, err := u.shiftFixed("ShiftFixed", Fixed{IntBits: intBits, FracBits: fracBits, Signed: signed})
+	if err != nil {
+		return 0, err
+	}
+	return f.Float64(), nil
+}
+
+// FetchFixed read a Q-format fixed-point number, convert it to float64 and set
+// it to v.
+func (u *Unpacker) FetchFixed(intBits, fracBits int, signed bool, v *float64) *Unpacker {
+	return u.errFilter(func() {
+		*v, u.err = u.ShiftFixed(intBits, fracBits, signed)
+	})
+}
+
+// ShiftFixedValue fetch a Q-format fixed-point number in io.Reader, keeping
+// its exact raw value.
+func (u *Unpacker) ShiftFixedValue(intBits, fracBits int, signed bool) (Fixed, error) {
+	f, err := u.shiftFixed("ShiftFixedValue", Fixed{IntBits: intBits, FracBits: fracBits, Signed: signed})
+	if err != nil {
+		return Fixed{}, err
+	}
+	return f, nil
+}
+
+// FetchFixedValue read a Q-format fixed-point number and set it to f.
+func (u *Unpacker) FetchFixedValue(intBits, fracBits int, signed bool, f *Fixed) *Unpacker {
+	return u.errFilter(func() {
+		*f, u.err = u.ShiftFixedValue(intBits, fracBits, signed)
+	})
+}
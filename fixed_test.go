@@ -0,0 +1,222 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushFixed(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	// Q15, Q16.16 counting the sign bit, 8 bits, and 10 bits in 2 bytes.
+	p.PushFixed(0.5, 0, 15, true).PushFixed(-1, 0, 15, true).
+		PushFixed(1.5, 15, 16, true).PushFixed(-0.25, 3, 4, true).
+		PushFixed(255.75, 8, 2, false).PushFixed(1.0/3, 0, 8, false)
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{
+		0x40, 0x00,
+		0x80, 0x00,
+		0x00, 0x01, 0x80, 0x00,
+		0xFC,
+		0x03, 0xFF,
+		0x55,
+	}, "fixed error.")
+
+	for _, u := range []*Unpacker{
+		NewSliceUnpacker(binary.BigEndian, p.Bytes()).Unpacker,
+		NewUnpacker(binary.BigEndian, bytes.NewReader(p.Bytes())),
+	} {
+		var a, b, c, d, e float64
+		u.FetchFixed(0, 15, true, &a).FetchFixed(0, 15, true, &b).
+			FetchFixed(15, 16, true, &c).FetchFixed(3, 4, true, &d).
+			FetchFixed(8, 2, false, &e)
+		f, err := u.ShiftFixed(0, 8, false)
+		assert.Nil(t, err, "Has error.")
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, []float64{a, b, c, d, e, f}, []float64{0.5, -1, 1.5, -0.25, 255.75, 85.0 / 256}, "fixed error.")
+		_, err = u.ShiftFixed(0, 15, true)
+		assert.True(t, errors.Is(err, io.EOF), "eof error.")
+	}
+}
+
+func TestFixedRounding(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	// Halfway values round to even: 0.5, 1.5, 2.5 and -1.5 steps.
+	for _, v := range []float64{0.5 / 256, 1.5 / 256, 2.5 / 256, -1.5 / 256} {
+		p.PushFixed(v, 0, 8, true)
+	}
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x02, 0xFF, 0xFE}, "rounding error.")
+}
+
+func TestFixedOverflow(t *testing.T) {
+	for _, c := range []struct {
+		v      float64
+		signed bool
+		want   []byte
+	}{
+		{1, true, []byte{0x7F, 0xFF}},
+		{-1.5, true, []byte{0x80, 0x00}},
+		{math.Inf(1), true, []byte{0x7F, 0xFF}},
+		{-0.1, false, []byte{0x00, 0x00}},
+		{2, false, []byte{0xFF, 0xFF}},
+	} {
+		frac := 15
+		if !c.signed {
+			frac = 16
+		}
+		p := NewAppendPacker(binary.BigEndian, nil)
+		p.PushFixed(c.v, 0, frac, c.signed)
+		var e *Error
+		assert.True(t, errors.As(p.Error(), &e), "error type error.")
+		assert.Equal(t, e.Err, ErrValueOverflow, "overflow error.")
+		assert.Equal(t, e.Op, "PushFixed", "op error.")
+
+		p = NewAppendPacker(binary.BigEndian, nil).WithFixedOverflow(SaturateOutOfRange)
+		p.PushFixed(c.v, 0, frac, c.signed)
+		assert.Nil(t, p.Error(), "Has error.")
+		assert.Equal(t, p.Bytes(), c.want, "saturate error.")
+	}
+
+	// The largest value just fits, even in 63 bits where float64 is inexact.
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushFixed(1-1.0/(1<<15), 0, 15, true).PushFixed(math.Ldexp(1, 63), 63, 0, false)
+	assert.True(t, errors.Is(p.Error(), ErrValueOverflow), "overflow error.")
+	assert.Equal(t, p.Bytes(), []byte{0x7F, 0xFF}, "max error.")
+
+	p = NewAppendPacker(binary.BigEndian, nil).WithFixedOverflow(SaturateOutOfRange)
+	p.PushFixed(math.NaN(), 0, 15, true)
+	assert.True(t, errors.Is(p.Error(), ErrValueOverflow), "nan error.")
+
+	for _, f := range [][2]int{{0, 0}, {-1, 8}, {32, 32}} {
+		p = NewAppendPacker(binary.BigEndian, nil)
+		p.PushFixed(0, f[0], f[1], false)
+		assert.True(t, errors.Is(p.Error(), ErrInvalidFixed), "format error.")
+		u := NewSliceUnpacker(binary.BigEndian, make([]byte, 8))
+		_, err := u.ShiftFixed(f[0], f[1], true)
+		assert.True(t, errors.Is(err, ErrInvalidFixed), "format error.")
+	}
+
+	// Bits above a narrow format must be a sign extension.
+	u := NewSliceUnpacker(binary.BigEndian, []byte{0xFC, 0x00, 0x10, 0x00})
+	v, err := u.ShiftFixed(5, 6, true)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, v, -16.0, "sign error.")
+	_, err = u.ShiftFixed(5, 6, true)
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Err, ErrValueOverflow, "padding error.")
+	assert.Equal(t, e.Offset, int64(2), "offset error.")
+}
+
+func TestFixedValue(t *testing.T) {
+	q := Fixed{Raw: -3, IntBits: 7, FracBits: 8, Signed: true}
+	assert.Equal(t, q.Float64(), -3.0/256, "float error.")
+	assert.Equal(t, q.String(), "-0.01171875", "string error.")
+
+	p := NewAppendPacker(binary.LittleEndian, nil)
+	p.PushFixedValue(q).PushFixedValue(Fixed{Raw: 1<<40 + 1, FracBits: 48})
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{0xFD, 0xFF, 0x01, 0, 0, 0, 0, 0x01}, "fixed value error.")
+
+	u := NewSliceUnpacker(binary.LittleEndian, p.Bytes())
+	var a Fixed
+	u.FetchFixedValue(7, 8, true, &a)
+	b, err := u.ShiftFixedValue(0, 48, false)
+	assert.Nil(t, err, "Has error.")
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, a, q, "fixed value error.")
+	assert.Equal(t, b.Raw, int64(1<<40+1), "exact error.")
+
+	p = NewAppendPacker(binary.LittleEndian, nil)
+	p.PushFixedValue(Fixed{Raw: 256, FracBits: 8})
+	assert.True(t, errors.Is(p.Error(), ErrValueOverflow), "overflow error.")
+	p = NewAppendPacker(binary.LittleEndian, nil).WithFixedOverflow(SaturateOutOfRange)
+	p.PushFixedValue(Fixed{Raw: 256, FracBits: 8}).PushFixedValue(Fixed{Raw: -129, FracBits: 7, Signed: true})
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{0xFF, 0x80}, "saturate error.")
+}
+
+type fixedRecord struct {
+	Gain    float64 `bin:"q15"`
+	Lat     float64 `bin:"q8.23,le"`
+	Clamped float32 `bin:"uq4.4,saturate"`
+	Strict  float64 `bin:"q7,reject"`
+}
+
+func TestFixedStruct(t *testing.T) {
+	in := fixedRecord{Gain: -0.5, Lat: 51.5, Clamped: 20, Strict: 0.5}
+	buf := new(bytes.Buffer)
+	assert.Nil(t, Marshal(binary.BigEndian, buf, in), "Has error.")
+	assert.Equal(t, buf.Bytes(), []byte{
+		0xC0, 0x00,
+		0x00, 0x00, 0xC0, 0x19,
+		0xFF,
+		0x40,
+	}, "marshal error.")
+
+	var out fixedRecord
+	assert.Nil(t, Unmarshal(binary.BigEndian, buf, &out), "Has error.")
+	assert.Equal(t, out, fixedRecord{Gain: -0.5, Lat: 51.5, Clamped: 15.9375, Strict: 0.5}, "unmarshal error.")
+
+	// Without an option the Packer decides.
+	var s struct {
+		A float64 `bin:"q7"`
+		B float64 `bin:"q7,reject"`
+	}
+	s.A, s.B = 2, 0
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushStruct(&s)
+	assert.True(t, errors.Is(p.Error(), ErrValueOverflow), "overflow error.")
+	p = NewAppendPacker(binary.BigEndian, nil).WithFixedOverflow(SaturateOutOfRange)
+	p.PushStruct(&s)
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{0x7F, 0x00}, "saturate error.")
+	s.B = 2
+	p.PushStruct(&s)
+	assert.True(t, errors.Is(p.Error(), ErrValueOverflow), "reject error.")
+
+	for _, v := range []interface{}{
+		&struct {
+			A int32 `bin:"q15"`
+		}{},
+		&struct {
+			A float64 `bin:"q64"`
+		}{},
+		&struct {
+			A float64 `bin:"float64,saturate"`
+		}{},
+	} {
+		assert.NotNil(t, Marshal(binary.BigEndian, new(bytes.Buffer), v), "tag error.")
+	}
+}
+
+func TestTraceFixed(t *testing.T) {
+	type reading struct {
+		Gain float64 `bin:"uq8.8"`
+	}
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushFixed(-0.5, 0, 15, true).
+		PushFixedValue(Fixed{Raw: 3, IntBits: 4, FracBits: 4}).
+		PushStruct(&reading{Gain: 2.5})
+	assert.Nil(t, p.Error(), "push error.")
+	u := NewUnpacker(binary.BigEndian, bytes.NewReader(p.Bytes())).WithTracer(tr)
+	u.FetchFixed(0, 15, true, new(float64)).
+		FetchFixedValue(4, 4, false, new(Fixed)).
+		FetchStruct(new(reading))
+	assert.Nil(t, u.Error(), "shift error.")
+	assert.Equal(t, tr.lines, []string{
+		"push PushFixed 0 c0 00 -0.5",
+		"push PushFixedValue 2 03 0.1875",
+		"push PushFixed 3 02 80 2.5",
+		"shift ShiftFixed 0 c0 00 -0.5",
+		"shift ShiftFixedValue 2 03 0.1875",
+		"shift ShiftFixed 3 02 80 2.5",
+	}, "trace error.")
+}
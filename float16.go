@@ -0,0 +1,254 @@
+package binpacker
+
+import "math"
+
+// float32ToFloat16 converts f to IEEE 754 half precision, rounding to nearest
+// even. Values too large become infinities and NaNs stay NaNs.
+func float32ToFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xFF
+	mant := b & 0x7FFFFF
+	if exp == 0xFF {
+		if mant == 0 {
+			return sign | 0x7C00
+		}
+		// Keep the top of the payload, but never let it become an infinity.
+		m := uint16(mant >> 13)
+		if m == 0 {
+			m = 0x200
+		}
+		return sign | 0x7C00 | m
+	}
+	e := exp - 127 + 15
+	if e >= 0x1F {
+		return sign | 0x7C00
+	}
+	var h, rem, half uint32
+	if e > 0 {
+		h, rem, half = uint32(e)<<10|mant>>13, mant&0x1FFF, 0x1000
+	} else {
+		// A subnormal half, a multiple of 2^-24.
+		shift := uint(14 - e)
+		if shift > 24 {
+			return sign
+		}
+		full := mant | 0x800000
+		h, rem, half = full>>shift, full&(1<<shift-1), 1<<(shift-1)
+	}
+	// A carry out of the mantissa correctly bumps the exponent, up to infinity.
+	if rem > half || rem == half && h&1 == 1 {
+		h++
+	}
+	return sign | uint16(h)
+}
+
+// float16ToFloat32 converts the IEEE 754 half precision h to float32, exactly.
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1F
+	mant := uint32(h & 0x3FF)
+	switch exp {
+	case 0x1F:
+		return math.Float32frombits(sign | 0x7F800000 | mant<<13)
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			return -f
+		}
+		return f
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// float32ToBFloat16 converts f to bfloat16, the top half of a float32, rounding
+// to nearest even. NaNs stay NaNs.
+func float32ToBFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	if b&0x7FFFFFFF > 0x7F800000 {
+		m := uint16(b >> 16)
+		if m&0x7F == 0 {
+			m |= 0x40
+		}
+		return m
+	}
+	return uint16((b + 0x7FFF + (b>>16)&1) >> 16)
+}
+
+// bfloat16ToFloat32 converts the bfloat16 h to float32, exactly.
+func bfloat16ToFloat32(h uint16) float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
+
+// PushFloat16 write a float32 as an IEEE 754 half precision float into writer,
+// rounded to nearest even.
+func (p *Packer) PushFloat16(f float32) *Packer {
+	return p.pushHalf("PushFloat16", f, float32ToFloat16)
+}
+
+// PushBFloat16 write a float32 as a bfloat16 into writer, rounded to nearest
+// even.
+func (p *Packer) PushBFloat16(f float32) *Packer {
+	return p.pushHalf("PushBFloat16", f, float32ToBFloat16)
+}
+
+// pushHalf writes f converted to a 16-bit float by conv.
+func (p *Packer) pushHalf(op string, f float32, conv func(float32) uint16) *Packer {
+	return p.errFilter(func() {
+		p.endian.PutUint16(p.scratch[:2], conv(f))
+		p.write(op, p.scratch[:2])
+		if p.tracer != nil {
+			p.tracePushed(op, p.scratch[:2], f)
+		}
+	})
+}
+
+// ShiftFloat16 fetch 2 bytes in io.Reader as an IEEE 754 half precision float
+// and convert it to float32.
+func (u *Unpacker) ShiftFloat16() (float32, error) {
+	return u.shiftHalf("ShiftFloat16", float16ToFloat32)
+}
+
+// shiftHalf reads a 16-bit float and converts it to float32 by conv.
+func (u *Unpacker) shiftHalf(op string, conv func(uint16) float32) (float32, error) {
+	buffer, err := u.read(op, 2)
+	if err != nil {
+		return 0, err
+	}
+	f := conv(u.endian.Uint16(buffer))
+	if u.tracer != nil {
+		u.traceShifted(op, buffer, f)
+	}
+	return f, nil
+}
+
+// FetchFloat16 read 2 bytes as a half precision float, convert it to float32
+// and set it to f.
+func (u *Unpacker) FetchFloat16(f *float32) *Unpacker {
+	return u.errFilter(func() {
+		*f, u.err = u.ShiftFloat16()
+	})
+}
+
+// ShiftBFloat16 fetch 2 bytes in io.Reader as a bfloat16 and convert it to
+// float32.
+func (u *Unpacker) ShiftBFloat16() (float32, error) {
+	return u.shiftHalf("ShiftBFloat16", bfloat16ToFloat32)
+}
+
+// FetchBFloat16 read 2 bytes as a bfloat16, convert it to float32 and set it
+// to f.
+func (u *Unpacker) FetchBFloat16(f *float32) *Unpacker {
+	return u.errFilter(func() {
+		*f, u.err = u.ShiftBFloat16()
+	})
+}
+
+// pushHalfs writes s converted to 16-bit floats by conv.
+func (p *Packer) pushHalfs(op string, s []float32, conv func(float32) uint16) {
+	raw := p.writeBulk(op, 2*len(s), func(b []byte) {
+		for i, f := range s {
+			p.endian.PutUint16(b[2*i:], conv(f))
+		}
+	})
+	if p.tracer != nil {
+		p.tracePushed(op, raw, s)
+	}
+}
+
+// shiftHalfs reads n 16-bit floats converted by conv into s, reusing its
+// capacity.
+func (u *Unpacker) shiftHalfs(op string, n uint64, s []float32, conv func(uint16) float32) ([]float32, error) {
+	b, err := u.readBulk(op, n, 2)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(cap(s)) < n {
+		s = make([]float32, n)
+	}
+	s = s[:n]
+	for i := range s {
+		s[i] = conv(u.endian.Uint16(b[2*i:]))
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, b, s)
+	}
+	return s, nil
+}
+
+// PushFloat16s write a slice of float32 as half precision floats into writer.
+func (p *Packer) PushFloat16s(s []float32) *Packer {
+	return p.errFilter(func() {
+		p.pushHalfs("PushFloat16s", s, float32ToFloat16)
+	})
+}
+
+// PushFloat16sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s as half precision floats into writer.
+func (p *Packer) PushFloat16sWithPrefix(width int, s []float32) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushFloat16sWithPrefix", width, len(s))
+		p.PushFloat16s(s)
+	})
+}
+
+// ShiftFloat16s fetch n half precision floats in io.Reader. Returns a slice of
+// float32 and an error if exists.
+func (u *Unpacker) ShiftFloat16s(n uint64) ([]float32, error) {
+	return u.shiftHalfs("ShiftFloat16s", n, nil, float16ToFloat32)
+}
+
+// FetchFloat16s read n half precision floats and set them to s, reusing its
+// capacity.
+func (u *Unpacker) FetchFloat16s(n uint64, s *[]float32) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.shiftHalfs("ShiftFloat16s", n, *s, float16ToFloat32)
+	})
+}
+
+// FetchFloat16sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the number
+// of elements, then the half precision floats, and set them to s.
+func (u *Unpacker) FetchFloat16sWithPrefix(width int, s *[]float32) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchFloat16sWithPrefix", width)
+		u.FetchFloat16s(n, s)
+	})
+}
+
+// PushBFloat16s write a slice of float32 as bfloat16 into writer.
+func (p *Packer) PushBFloat16s(s []float32) *Packer {
+	return p.errFilter(func() {
+		p.pushHalfs("PushBFloat16s", s, float32ToBFloat16)
+	})
+}
+
+// PushBFloat16sWithPrefix write the number of elements of s as a width-byte
+// prefix (1, 2, 4 or 8), then s as bfloat16 into writer.
+func (p *Packer) PushBFloat16sWithPrefix(width int, s []float32) *Packer {
+	return p.errFilter(func() {
+		p.pushCount("PushBFloat16sWithPrefix", width, len(s))
+		p.PushBFloat16s(s)
+	})
+}
+
+// ShiftBFloat16s fetch n bfloat16 in io.Reader. Returns a slice of float32 and
+// an error if exists.
+func (u *Unpacker) ShiftBFloat16s(n uint64) ([]float32, error) {
+	return u.shiftHalfs("ShiftBFloat16s", n, nil, bfloat16ToFloat32)
+}
+
+// FetchBFloat16s read n bfloat16 and set them to s, reusing its capacity.
+func (u *Unpacker) FetchBFloat16s(n uint64, s *[]float32) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.shiftHalfs("ShiftBFloat16s", n, *s, bfloat16ToFloat32)
+	})
+}
+
+// FetchBFloat16sWithPrefix read a width-byte prefix (1, 2, 4 or 8) as the
+// number of elements, then the bfloat16, and set them to s.
+func (u *Unpacker) FetchBFloat16sWithPrefix(width int, s *[]float32) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchBFloat16sWithPrefix", width)
+		u.FetchBFloat16s(n, s)
+	})
+}
@@ -0,0 +1,227 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func isNaN32(f float32) bool {
+	return f != f
+}
+
+func TestFloat16Values(t *testing.T) {
+	for _, c := range []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3C00},
+		{-2, 0xC000},
+		{0.5, 0x3800},
+		{65504, 0x7BFF},
+		{float32(math.Inf(1)), 0x7C00},
+		{float32(math.Inf(-1)), 0xFC00},
+		{1.0 / (1 << 14), 0x0400},    // smallest normal
+		{1.0 / (1 << 24), 0x0001},    // smallest subnormal
+		{1023.0 / (1 << 24), 0x03FF}, // largest subnormal
+		{0.1, 0x2E66},
+		{65520, 0x7C00}, // halfway to 65536 rounds up to infinity
+		{65519, 0x7BFF},
+		{1e10, 0x7C00},
+		{1.0 / (1 << 25), 0x0000}, // halfway to the smallest subnormal, even
+		{1.5 / (1 << 25), 0x0001}, // above halfway
+		{3.0 / (1 << 25), 0x0002}, // halfway between 1 and 2, even
+		{1e-30, 0x0000},
+		{math.SmallestNonzeroFloat32, 0x0000},
+	} {
+		assert.Equal(t, float32ToFloat16(c.f), c.h, "float16 %v error.", c.f)
+	}
+	assert.True(t, isNaN32(float16ToFloat32(float32ToFloat16(float32(math.NaN())))), "nan error.")
+	// A NaN whose payload is all in the low bits must not become an infinity.
+	assert.Equal(t, float32ToFloat16(math.Float32frombits(0x7F800001)), uint16(0x7E00), "nan error.")
+}
+
+// TestFloat16Exhaustive checks every half precision bit pattern: conversion to
+// float32 and back is exact, and float32 values around each midpoint between
+// neighbours round to the nearest, ties to even.
+func TestFloat16Exhaustive(t *testing.T) {
+	for i := 0; i < 1<<16; i++ {
+		h := uint16(i)
+		f := float16ToFloat32(h)
+		if got := float32ToFloat16(f); got != h {
+			t.Fatalf("float16 %#04x round trip to %#04x", h, got)
+		}
+		nan := h&0x7C00 == 0x7C00 && h&0x3FF != 0
+		if nan != isNaN32(f) {
+			t.Fatalf("float16 %#04x nan error", h)
+		}
+	}
+
+	for h := uint16(0); h < 0x7C00; h++ {
+		lo := float64(float16ToFloat32(h))
+		hi := 65536.0 // where the next step up would be, beyond 65504
+		if h+1 < 0x7C00 {
+			hi = float64(float16ToFloat32(h + 1))
+		}
+		mid := float32((lo + hi) / 2) // exact: 12 significant bits at most
+		even := h
+		if h&1 == 1 {
+			even = h + 1
+		}
+		for _, c := range []struct {
+			f    float32
+			want uint16
+		}{
+			{mid, even},
+			{math.Nextafter32(mid, 0), h},
+			{math.Nextafter32(mid, float32(math.Inf(1))), h + 1},
+		} {
+			if got := float32ToFloat16(c.f); got != c.want {
+				t.Fatalf("float16 of %g is %#04x, want %#04x", c.f, got, c.want)
+			}
+			if got := float32ToFloat16(-c.f); got != c.want|0x8000 {
+				t.Fatalf("float16 of %g is %#04x, want %#04x", -c.f, got, c.want|0x8000)
+			}
+		}
+	}
+}
+
+func TestBFloat16Values(t *testing.T) {
+	for _, c := range []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3F80},
+		{-2, 0xC000},
+		{math.MaxFloat32, 0x7F80}, // rounds up to infinity
+		{float32(math.Inf(-1)), 0xFF80},
+		{math.SmallestNonzeroFloat32, 0x0000},
+		{math.Float32frombits(0x00018000), 0x0002}, // subnormal tie, even
+		{math.Float32frombits(0x3F808000), 0x3F80}, // tie, even
+		{math.Float32frombits(0x3F818000), 0x3F82}, // tie, even
+		{math.Float32frombits(0x3F808001), 0x3F81},
+	} {
+		assert.Equal(t, float32ToBFloat16(c.f), c.h, "bfloat16 %v error.", c.f)
+	}
+	assert.Equal(t, float32ToBFloat16(math.Float32frombits(0x7F800001)), uint16(0x7FC0), "nan error.")
+	assert.Equal(t, float32ToBFloat16(math.Float32frombits(0xFFC00000)), uint16(0xFFC0), "nan error.")
+}
+
+// TestBFloat16Exhaustive checks every bfloat16 bit pattern like
+// TestFloat16Exhaustive.
+func TestBFloat16Exhaustive(t *testing.T) {
+	for i := 0; i < 1<<16; i++ {
+		h := uint16(i)
+		f := bfloat16ToFloat32(h)
+		if got := float32ToBFloat16(f); got != h {
+			t.Fatalf("bfloat16 %#04x round trip to %#04x", h, got)
+		}
+	}
+
+	for h := uint16(0); h < 0x7F80; h++ {
+		// Within a binade the float32 bits are linear in the value, so the
+		// midpoint is half a bfloat16 step above h.
+		mid := uint32(h)<<16 | 0x8000
+		even := h
+		if h&1 == 1 {
+			even = h + 1
+		}
+		for _, c := range []struct {
+			bits uint32
+			want uint16
+		}{
+			{mid, even},
+			{mid - 1, h},
+			{mid + 1, h + 1},
+		} {
+			for _, sign := range []uint32{0, 0x80000000} {
+				f := math.Float32frombits(c.bits | sign)
+				want := c.want | uint16(sign>>16)
+				if got := float32ToBFloat16(f); got != want {
+					t.Fatalf("bfloat16 of %#08x is %#04x, want %#04x", c.bits|sign, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPushFloat16(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushFloat16(1).PushBFloat16(1)
+	p.WithEndian(binary.LittleEndian, func(p *Packer) {
+		p.PushFloat16(-2).PushBFloat16(-2)
+	})
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{0x3C, 0x00, 0x3F, 0x80, 0x00, 0xC0, 0x00, 0xC0}, "push error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes())
+	var a, b, c, d float32
+	u.FetchFloat16(&a).FetchBFloat16(&b)
+	u.WithEndian(binary.LittleEndian, func(u *Unpacker) {
+		u.FetchFloat16(&c).FetchBFloat16(&d)
+	})
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, []float32{a, b, c, d}, []float32{1, 1, -2, -2}, "fetch error.")
+	_, err := u.ShiftFloat16()
+	assert.True(t, errors.Is(err, io.EOF), "eof error.")
+}
+
+func TestFloat16s(t *testing.T) {
+	s := []float32{0, 1, -0.5, 65504, float32(math.Inf(1))}
+	for _, order := range bulkOrders {
+		want := NewAppendPacker(order, nil)
+		for _, f := range s {
+			want.PushFloat16(f)
+		}
+		for _, f := range s {
+			want.PushBFloat16(f)
+		}
+
+		p := NewAppendPacker(order, nil)
+		p.PushFloat16s(s).PushBFloat16s(s)
+		assert.Nil(t, p.Error(), "Has error.")
+		assert.Equal(t, p.Bytes(), want.Bytes(), "push error.")
+
+		buf := make([]float32, 0, 8)
+		u := NewSliceUnpacker(order, p.Bytes())
+		u.FetchFloat16s(5, &buf)
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, buf, s, "float16s error.")
+		assert.Equal(t, cap(buf), 8, "capacity error.")
+		b, err := u.ShiftBFloat16s(5)
+		assert.Nil(t, err, "Has error.")
+		assert.Equal(t, b, []float32{0, 1, -0.5, 65536, float32(math.Inf(1))}, "bfloat16s error.")
+	}
+
+	p := NewAppendPacker(binary.LittleEndian, nil)
+	p.PushFloat16sWithPrefix(1, []float32{1}).PushBFloat16sWithPrefix(2, []float32{1, 2})
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{1, 0x00, 0x3C, 2, 0, 0x80, 0x3F, 0x00, 0x40}, "prefix error.")
+
+	var a, b []float32
+	u := NewSliceUnpacker(binary.LittleEndian, p.Bytes())
+	u.FetchFloat16sWithPrefix(1, &a).FetchBFloat16sWithPrefix(2, &b)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, a, []float32{1}, "float16s error.")
+	assert.Equal(t, b, []float32{1, 2}, "bfloat16s error.")
+
+	_, err := NewSliceUnpacker(binary.LittleEndian, []byte{1, 2, 3}).ShiftFloat16s(2)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+}
+
+func TestTraceFloat16(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushFloat16(1.5).PushBFloat16s([]float32{1, 2})
+	assert.Equal(t, tr.lines, []string{
+		"push PushFloat16 0 3e 00 1.5",
+		"push PushBFloat16s 2 3f 80 40 00 [1 2]",
+	}, "trace error.")
+}
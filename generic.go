@@ -0,0 +1,115 @@
+package binpacker
+
+import "unsafe"
+
+// Number is the set of fixed-width integer and floating-point types, including
+// named types such as
+//
+//	type Opcode uint16
+//
+// int, uint and uintptr are not included, as their width depends on the
+// platform.
+type Number interface {
+	~int8 | ~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64 | ~float32 | ~float64
+}
+
+// The kinds of Number, by underlying type.
+const (
+	kindUint8 = iota
+	kindInt8
+	kindUint16
+	kindInt16
+	kindUint32
+	kindInt32
+	kindFloat32
+	kindUint64
+	kindInt64
+	kindFloat64
+)
+
+// The operations of Push and Shift by kind, as the equivalent methods name
+// them.
+var (
+	pushOps = [...]string{
+		"PushUint8", "PushInt8", "PushUint16", "PushInt16", "PushUint32",
+		"PushInt32", "PushFloat32", "PushUint64", "PushInt64", "PushFloat64",
+	}
+	shiftOps = [...]string{
+		"ShiftUint8", "ShiftInt8", "ShiftUint16", "ShiftInt16", "ShiftUint32",
+		"ShiftInt32", "ShiftFloat32", "ShiftUint64", "ShiftInt64", "ShiftFloat64",
+	}
+)
+
+// numberKind returns the kind of T.
+func numberKind[T Number]() int {
+	var zero T
+	one := zero + 1
+	float := one/(one+one) != zero
+	signed := zero-one < zero
+	switch unsafe.Sizeof(zero) {
+	case 1:
+		if signed {
+			return kindInt8
+		}
+		return kindUint8
+	case 2:
+		if signed {
+			return kindInt16
+		}
+		return kindUint16
+	case 4:
+		switch {
+		case float:
+			return kindFloat32
+		case signed:
+			return kindInt32
+		}
+		return kindUint32
+	}
+	switch {
+	case float:
+		return kindFloat64
+	case signed:
+		return kindInt64
+	}
+	return kindUint64
+}
+
+// Push writes v into the writer of p like the method for its underlying type,
+// e.g. PushUint16 for a type Opcode uint16.
+func Push[T Number](p *Packer, v T) *Packer {
+	op := pushOps[numberKind[T]()]
+	switch unsafe.Sizeof(v) {
+	case 1:
+		return pushUint8(p, op, v)
+	case 2:
+		return pushUint16(p, op, p.endian, v)
+	case 4:
+		return pushUint32(p, op, p.endian, v)
+	}
+	return pushUint64(p, op, p.endian, v)
+}
+
+// Shift reads a T from u like the method for its underlying type, e.g.
+// ShiftUint16 for a type Opcode uint16. Returns the value and an error if
+// exists.
+func Shift[T Number](u *Unpacker) (T, error) {
+	var zero T
+	op := shiftOps[numberKind[T]()]
+	switch unsafe.Sizeof(zero) {
+	case 1:
+		return shiftUint8[T](u, op)
+	case 2:
+		return shiftUint16[T](u, op, u.endian)
+	case 4:
+		return shiftUint32[T](u, op, u.endian)
+	}
+	return shiftUint64[T](u, op, u.endian)
+}
+
+// Fetch reads a T from u and sets it to v.
+func Fetch[T Number](u *Unpacker, v *T) *Unpacker {
+	return u.errFilter(func() {
+		*v, u.err = Shift[T](u)
+	})
+}
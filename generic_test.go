@@ -0,0 +1,119 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type opcode uint16
+
+type celsius float32
+
+type delta int8
+
+func TestNumberKind(t *testing.T) {
+	assert.Equal(t, []int{
+		numberKind[uint8](), numberKind[int8](), numberKind[uint16](), numberKind[int16](),
+		numberKind[uint32](), numberKind[int32](), numberKind[float32](),
+		numberKind[uint64](), numberKind[int64](), numberKind[float64](),
+		numberKind[opcode](), numberKind[celsius](), numberKind[delta](),
+	}, []int{
+		kindUint8, kindInt8, kindUint16, kindInt16,
+		kindUint32, kindInt32, kindFloat32,
+		kindUint64, kindInt64, kindFloat64,
+		kindUint16, kindFloat32, kindInt8,
+	}, "kind error.")
+}
+
+func TestPushGeneric(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	Push(p, uint8(1))
+	Push(p, int8(-1))
+	Push(p, opcode(0x0102))
+	Push(p, int16(-2))
+	Push(p, uint32(3))
+	Push(p, int32(-4))
+	Push(p, celsius(1.5))
+	Push(p, uint64(5))
+	Push(p, int64(-6))
+	Push(p, math.Pi)
+	assert.Nil(t, p.Error(), "Has error.")
+
+	want := NewAppendPacker(binary.BigEndian, nil)
+	want.PushUint8(1).PushByte(0xFF).PushUint16(0x0102).PushInt16(-2).
+		PushUint32(3).PushInt32(-4).PushFloat32(1.5).
+		PushUint64(5).PushInt64(-6).PushFloat64(math.Pi)
+	assert.Equal(t, p.Bytes(), want.Bytes(), "push error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes())
+	var (
+		a uint8
+		b delta
+		c opcode
+		d int16
+		e uint32
+		f int32
+		g celsius
+		h uint64
+		i int64
+	)
+	Fetch(u.Unpacker, &a)
+	Fetch(u.Unpacker, &b)
+	Fetch(u.Unpacker, &c)
+	Fetch(u.Unpacker, &d)
+	Fetch(u.Unpacker, &e)
+	Fetch(u.Unpacker, &f)
+	Fetch(u.Unpacker, &g)
+	Fetch(u.Unpacker, &h)
+	Fetch(u.Unpacker, &i)
+	j, err := Shift[float64](u.Unpacker)
+	assert.Nil(t, err, "Has error.")
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, []interface{}{a, b, c, d, e, f, g, h, i, j}, []interface{}{
+		uint8(1), delta(-1), opcode(0x0102), int16(-2), uint32(3), int32(-4),
+		celsius(1.5), uint64(5), int64(-6), math.Pi,
+	}, "fetch error.")
+}
+
+func TestShiftGenericError(t *testing.T) {
+	u := NewSliceUnpacker(binary.LittleEndian, []byte{1})
+	_, err := Shift[opcode](u.Unpacker)
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Op, "ShiftUint16", "op error.")
+
+	var c opcode = 7
+	Fetch(u.Unpacker, &c)
+	Fetch(u.Unpacker, &c)
+	assert.NotNil(t, u.Error(), "sticky error.")
+	assert.Equal(t, c, opcode(0), "fetch error.")
+}
+
+func TestTraceGeneric(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.LittleEndian, nil).WithTracer(tr)
+	Push(p, delta(-2))
+	Push(p, celsius(1.5))
+	assert.Equal(t, tr.lines, []string{
+		"push PushInt8 0 fe -2",
+		"push PushFloat32 1 00 00 c0 3f 1.5",
+	}, "trace error.")
+}
+
+func TestGenericAllocs(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, make([]byte, 0, 64))
+	u := NewSliceUnpacker(binary.BigEndian, make([]byte, 16))
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Reset()
+		Push(p, opcode(1))
+		Push(p, celsius(2))
+		u.Seek(0, 0)
+		Shift[opcode](u.Unpacker)
+		Shift[float64](u.Unpacker)
+	})
+	assert.Equal(t, allocs, float64(0), "allocs error.")
+}
@@ -0,0 +1,138 @@
+// Package bintag parses the "bin" struct tags shared by binpacker.Marshal and
+// the binpacker-gen code generator.
+package bintag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tag is a parsed "bin" struct tag.
+type Tag struct {
+	Skip   bool
+	Type   string           // wire type name, empty to derive it from the Go type
+	Order  binary.ByteOrder // byte order override, nil if unset
+	Prefix int              // width of the length prefix in bytes, 0 if unset
+	Length int              // fixed length, -1 if unset
+	Fixed  *Fixed           // fixed-point format, if Type is one
+	// Overflow is "saturate" or "reject" for what to do with out-of-range
+	// fixed-point values, empty to leave it to the Packer.
+	Overflow string
+}
+
+// Fixed is a Q-format fixed-point wire type. IntBits and FracBits exclude the
+// sign bit of a signed format.
+type Fixed struct {
+	IntBits  int
+	FracBits int
+	Signed   bool
+}
+
+// Scalar describes a fixed-width wire type.
+type Scalar struct {
+	Width  int
+	Signed bool
+	Float  bool
+}
+
+// Scalars maps type names to fixed-width wire types.
+var Scalars = map[string]Scalar{
+	"bool":    {Width: 1},
+	"byte":    {Width: 1},
+	"uint8":   {Width: 1},
+	"int8":    {Width: 1, Signed: true},
+	"uint16":  {Width: 2},
+	"int16":   {Width: 2, Signed: true},
+	"uint32":  {Width: 4},
+	"int32":   {Width: 4, Signed: true},
+	"uint64":  {Width: 8},
+	"int64":   {Width: 8, Signed: true},
+	"float32": {Width: 4, Float: true},
+	"float64": {Width: 8, Float: true},
+}
+
+// PrefixWidths maps the names accepted by the prefix option to widths in bytes.
+var PrefixWidths = map[string]int{"uint8": 1, "uint16": 2, "uint32": 4, "uint64": 8}
+
+// DefaultPrefix is the prefix width of strings and slices without a prefix or
+// len option.
+const DefaultPrefix = 4
+
+// ParseFixed parses a fixed-point type name: "q" for signed or "uq" for
+// unsigned, then the integer and fractional bits, as in q7.8 or uq16.16. A
+// single number is the fractional bits, so q15 is q0.15. ok is false if s is
+// not a fixed-point type name.
+func ParseFixed(s string) (f Fixed, ok bool, err error) {
+	rest := strings.TrimPrefix(s, "u")
+	if !strings.HasPrefix(rest, "q") {
+		return f, false, nil
+	}
+	f.Signed = rest == s
+	rest = rest[1:]
+	if rest == "" || rest[0] < '0' || rest[0] > '9' {
+		return f, false, nil
+	}
+	intBits, fracBits := "0", rest
+	if i := strings.IndexByte(rest, '.'); i >= 0 {
+		intBits, fracBits = rest[:i], rest[i+1:]
+	}
+	m, err1 := strconv.ParseUint(intBits, 10, 8)
+	n, err2 := strconv.ParseUint(fracBits, 10, 8)
+	if err1 != nil || err2 != nil || m+n < 1 || m+n > 63 {
+		return f, true, fmt.Errorf("invalid fixed-point type %q", s)
+	}
+	f.IntBits = int(m)
+	f.FracBits = int(n)
+	return f, true, nil
+}
+
+// Parse parses the value of a "bin" struct tag.
+func Parse(s string) (Tag, error) {
+	t := Tag{Length: -1}
+	if s == "-" {
+		t.Skip = true
+		return t, nil
+	}
+	parts := strings.Split(s, ",")
+	t.Type = parts[0]
+	for _, opt := range parts[1:] {
+		switch {
+		case opt == "be":
+			t.Order = binary.BigEndian
+		case opt == "le":
+			t.Order = binary.LittleEndian
+		case strings.HasPrefix(opt, "prefix="):
+			w, ok := PrefixWidths[strings.TrimPrefix(opt, "prefix=")]
+			if !ok {
+				return t, fmt.Errorf("invalid prefix %q", opt)
+			}
+			t.Prefix = w
+		case opt == "saturate" || opt == "reject":
+			t.Overflow = opt
+		case strings.HasPrefix(opt, "len="):
+			n, err := strconv.Atoi(strings.TrimPrefix(opt, "len="))
+			if err != nil || n < 0 {
+				return t, fmt.Errorf("invalid length %q", opt)
+			}
+			t.Length = n
+		default:
+			return t, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	if t.Prefix != 0 && t.Length >= 0 {
+		return t, errors.New("prefix and len are mutually exclusive")
+	}
+	f, ok, err := ParseFixed(t.Type)
+	if err != nil {
+		return t, err
+	}
+	if ok {
+		t.Fixed = &f
+	} else if t.Overflow != "" {
+		return t, fmt.Errorf("%s is only allowed on fixed-point types", t.Overflow)
+	}
+	return t, nil
+}
@@ -0,0 +1,50 @@
+package bintag
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tg, err := Parse("uint16,be")
+	assert.NoError(t, err)
+	assert.Equal(t, Tag{Type: "uint16", Order: binary.BigEndian, Length: -1}, tg, "tag error.")
+
+	tg, err = Parse("string,prefix=uint8")
+	assert.NoError(t, err)
+	assert.Equal(t, Tag{Type: "string", Prefix: 1, Length: -1}, tg, "tag error.")
+
+	tg, err = Parse(",len=16,le")
+	assert.NoError(t, err)
+	assert.Equal(t, Tag{Order: binary.LittleEndian, Length: 16}, tg, "tag error.")
+
+	tg, err = Parse("-")
+	assert.NoError(t, err)
+	assert.True(t, tg.Skip, "skip error.")
+}
+
+func TestParseFixed(t *testing.T) {
+	tg, err := Parse("q15")
+	assert.NoError(t, err)
+	assert.Equal(t, &Fixed{FracBits: 15, Signed: true}, tg.Fixed, "fixed error.")
+
+	tg, err = Parse("uq16.16,saturate")
+	assert.NoError(t, err)
+	assert.Equal(t, Tag{Type: "uq16.16", Length: -1, Fixed: &Fixed{IntBits: 16, FracBits: 16}, Overflow: "saturate"}, tg, "tag error.")
+
+	_, ok, err := ParseFixed("quux")
+	assert.NoError(t, err)
+	assert.False(t, ok, "fixed error.")
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		",prefix=int8", ",len=-1", ",len=x", ",foo", ",prefix=uint8,len=2",
+		"q0", "q32.32", "q7.", "uq8.-1", "uint16,saturate", ",reject",
+	} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,205 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrValueOverflow is returned by PushUintN and PushIntN for a value that
+// does not fit in the given number of bytes.
+var ErrValueOverflow = errors.New("binpacker: value overflows the field")
+
+// bigEndian reports whether order stores the most significant byte first.
+func bigEndian(order binary.ByteOrder) bool {
+	return order.Uint16(orderProbe) == 0x0102
+}
+
+// putUintN encodes the low n bytes of v in order into buf, which must hold 8
+// bytes, and returns them.
+func putUintN(order binary.ByteOrder, buf []byte, v uint64, n int) []byte {
+	order.PutUint64(buf[:8], v)
+	if bigEndian(order) {
+		return buf[8-n : 8]
+	}
+	return buf[:n]
+}
+
+// getUintN decodes the n-byte integer b in order, using buf, which must hold 8
+// bytes, as scratch space.
+func getUintN(order binary.ByteOrder, buf, b []byte) uint64 {
+	buf = buf[:8]
+	for i := range buf {
+		buf[i] = 0
+	}
+	if bigEndian(order) {
+		copy(buf[8-len(b):], b)
+	} else {
+		copy(buf, b)
+	}
+	return order.Uint64(buf)
+}
+
+// signExtend sign-extends the n-byte integer v.
+func signExtend(v uint64, n int) int64 {
+	shift := 64 - 8*uint(n)
+	return int64(v<<shift) >> shift
+}
+
+// pushUintN writes the low nBytes bytes of v for op and returns them.
+func (p *Packer) pushUintN(op string, v uint64, nBytes int) []byte {
+	b := putUintN(p.endian, p.scratch[:], v, nBytes)
+	p.write(op, b)
+	return b
+}
+
+// PushUintN write the low nBytes bytes (1 to 8) of a uint64 into writer, such
+// as a 24-bit or 48-bit integer. A value that does not fit is rejected with
+// ErrValueOverflow.
+func (p *Packer) PushUintN(v uint64, nBytes int) *Packer {
+	return p.errFilter(func() {
+		const op = "PushUintN"
+		switch {
+		case nBytes < 1 || nBytes > 8:
+			p.fail(op, ErrInvalidWidth)
+		case nBytes < 8 && v >= 1<<(8*uint(nBytes)):
+			p.fail(op, ErrValueOverflow)
+		default:
+			raw := p.pushUintN(op, v, nBytes)
+			if p.tracer != nil {
+				p.tracePushed(op, raw, v)
+			}
+		}
+	})
+}
+
+// PushIntN write a int64 as a nBytes-byte (1 to 8) two's complement integer
+// into writer. A value that does not fit is rejected with ErrValueOverflow.
+func (p *Packer) PushIntN(v int64, nBytes int) *Packer {
+	return p.errFilter(func() {
+		const op = "PushIntN"
+		switch {
+		case nBytes < 1 || nBytes > 8:
+			p.fail(op, ErrInvalidWidth)
+		case signExtend(uint64(v), nBytes) != v:
+			p.fail(op, ErrValueOverflow)
+		default:
+			raw := p.pushUintN(op, uint64(v), nBytes)
+			if p.tracer != nil {
+				p.tracePushed(op, raw, v)
+			}
+		}
+	})
+}
+
+// shiftUintN reads an nBytes-byte integer for op and returns it with the
+// bytes read.
+func (u *Unpacker) shiftUintN(op string, nBytes int) (uint64, []byte, error) {
+	if nBytes < 1 || nBytes > 8 {
+		return 0, nil, u.traceError(&Error{Op: op, Offset: u.offset, Err: ErrInvalidWidth})
+	}
+	b, err := u.read(op, uint64(nBytes))
+	if err != nil {
+		return 0, nil, err
+	}
+	// A read from a reader returns the start of scratch, so decode in the rest.
+	return getUintN(u.endian, u.scratch[8:], b), b, nil
+}
+
+// ShiftUintN fetch nBytes bytes (1 to 8) in io.Reader and convert them to
+// uint64.
+func (u *Unpacker) ShiftUintN(nBytes int) (uint64, error) {
+	const op = "ShiftUintN"
+	v, raw, err := u.shiftUintN(op, nBytes)
+	if err == nil && u.tracer != nil {
+		u.traceShifted(op, raw, v)
+	}
+	return v, err
+}
+
+// FetchUintN read nBytes bytes (1 to 8), convert them to uint64 and set it to
+// v.
+func (u *Unpacker) FetchUintN(nBytes int, v *uint64) *Unpacker {
+	return u.errFilter(func() {
+		*v, u.err = u.ShiftUintN(nBytes)
+	})
+}
+
+// ShiftIntN fetch nBytes bytes (1 to 8) in io.Reader and convert them to a
+// sign-extended int64.
+func (u *Unpacker) ShiftIntN(nBytes int) (int64, error) {
+	const op = "ShiftIntN"
+	x, raw, err := u.shiftUintN(op, nBytes)
+	if err != nil {
+		return 0, err
+	}
+	v := signExtend(x, nBytes)
+	if u.tracer != nil {
+		u.traceShifted(op, raw, v)
+	}
+	return v, nil
+}
+
+// FetchIntN read nBytes bytes (1 to 8), convert them to a sign-extended int64
+// and set it to v.
+func (u *Unpacker) FetchIntN(nBytes int, v *int64) *Unpacker {
+	return u.errFilter(func() {
+		*v, u.err = u.ShiftIntN(nBytes)
+	})
+}
+
+// Uint128 is an unsigned 128-bit integer.
+type Uint128 struct {
+	Hi uint64 // the most significant 64 bits
+	Lo uint64 // the least significant 64 bits
+}
+
+// putUint128 encodes v in order into b, which must hold 16 bytes.
+func putUint128(order binary.ByteOrder, b []byte, v Uint128) {
+	if bigEndian(order) {
+		order.PutUint64(b[:8], v.Hi)
+		order.PutUint64(b[8:16], v.Lo)
+	} else {
+		order.PutUint64(b[:8], v.Lo)
+		order.PutUint64(b[8:16], v.Hi)
+	}
+}
+
+// getUint128 decodes the 16 bytes b in order.
+func getUint128(order binary.ByteOrder, b []byte) Uint128 {
+	if bigEndian(order) {
+		return Uint128{Hi: order.Uint64(b[:8]), Lo: order.Uint64(b[8:16])}
+	}
+	return Uint128{Hi: order.Uint64(b[8:16]), Lo: order.Uint64(b[:8])}
+}
+
+// PushUint128 write a Uint128 as 16 bytes into writer.
+func (p *Packer) PushUint128(v Uint128) *Packer {
+	return p.errFilter(func() {
+		putUint128(p.endian, p.scratch[:16], v)
+		p.write("PushUint128", p.scratch[:16])
+		if p.tracer != nil {
+			p.tracePushed("PushUint128", p.scratch[:16], v)
+		}
+	})
+}
+
+// ShiftUint128 fetch 16 bytes in io.Reader and convert them to Uint128.
+func (u *Unpacker) ShiftUint128() (Uint128, error) {
+	const op = "ShiftUint128"
+	b, err := u.read(op, 16)
+	if err != nil {
+		return Uint128{}, err
+	}
+	v := getUint128(u.endian, b)
+	if u.tracer != nil {
+		u.traceShifted(op, b, v)
+	}
+	return v, nil
+}
+
+// FetchUint128 read 16 bytes, convert them to Uint128 and set it to v.
+func (u *Unpacker) FetchUint128(v *Uint128) *Unpacker {
+	return u.errFilter(func() {
+		*v, u.err = u.ShiftUint128()
+	})
+}
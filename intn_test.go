@@ -0,0 +1,167 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInt8AndBool(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushInt8(-2).PushInt8(math.MaxInt8).PushBool(true).PushBool(false)
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{0xFE, 0x7F, 1, 0}, "push error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, append(p.Bytes(), 2))
+	var a int8
+	var b, c bool
+	u.FetchInt8(&a).FetchInt8(&a).FetchBool(&b).FetchBool(&c)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, a, int8(math.MaxInt8), "int8 error.")
+	assert.Equal(t, []bool{b, c}, []bool{true, false}, "bool error.")
+	// Any other value is true unless bools are strict.
+	b, err := u.ShiftBool()
+	assert.Nil(t, err, "Has error.")
+	assert.True(t, b, "bool error.")
+
+	su := NewSliceUnpacker(binary.BigEndian, []byte{1, 2}).WithStrictBools(true)
+	b, err = su.ShiftBool()
+	assert.Nil(t, err, "Has error.")
+	assert.True(t, b, "bool error.")
+	_, err = su.ShiftBool()
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Err, ErrInvalidBool, "bool error.")
+	assert.Equal(t, e.Op, "ShiftBool", "op error.")
+	assert.Equal(t, e.Offset, int64(1), "offset error.")
+
+	var s struct{ A, B bool }
+	su = NewSliceUnpacker(binary.BigEndian, []byte{1, 3}).WithStrictBools(true)
+	su.FetchStruct(&s)
+	assert.True(t, errors.Is(su.Error(), ErrInvalidBool), "struct bool error.")
+}
+
+func TestUintN(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushUintN(0x010203, 3).PushIntN(-2, 5).PushUintN(math.MaxUint64, 8)
+	p.WithEndian(binary.LittleEndian, func(p *Packer) {
+		p.PushUintN(0x010203040506, 6).PushIntN(-0x123456, 7)
+	})
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{
+		0x01, 0x02, 0x03,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFE,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x06, 0x05, 0x04, 0x03, 0x02, 0x01,
+		0xAA, 0xCB, 0xED, 0xFF, 0xFF, 0xFF, 0xFF,
+	}, "push error.")
+
+	for _, u := range []*Unpacker{
+		NewSliceUnpacker(binary.BigEndian, p.Bytes()).Unpacker,
+		NewUnpacker(binary.BigEndian, bytes.NewReader(p.Bytes())),
+	} {
+		var a, c, d uint64
+		var b, e int64
+		u.FetchUintN(3, &a).FetchIntN(5, &b).FetchUintN(8, &c)
+		u.WithEndian(binary.LittleEndian, func(u *Unpacker) {
+			u.FetchUintN(6, &d).FetchIntN(7, &e)
+		})
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, []uint64{a, c, d}, []uint64{0x010203, math.MaxUint64, 0x010203040506}, "uintN error.")
+		assert.Equal(t, []int64{b, e}, []int64{-2, -0x123456}, "intN error.")
+		_, err := u.ShiftUintN(1)
+		assert.True(t, errors.Is(err, io.EOF), "eof error.")
+	}
+
+	// A positive IntN must not come back negative.
+	u := NewSliceUnpacker(binary.BigEndian, []byte{0x7F, 0xFF, 0xFF})
+	i, err := u.ShiftIntN(3)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, i, int64(0x7FFFFF), "intN error.")
+}
+
+func TestUintNError(t *testing.T) {
+	for _, c := range []struct {
+		push func(p *Packer)
+		err  error
+	}{
+		{func(p *Packer) { p.PushUintN(1<<24, 3) }, ErrValueOverflow},
+		{func(p *Packer) { p.PushIntN(1<<23, 3) }, ErrValueOverflow},
+		{func(p *Packer) { p.PushIntN(-1<<23-1, 3) }, ErrValueOverflow},
+		{func(p *Packer) { p.PushUintN(0, 0) }, ErrInvalidWidth},
+		{func(p *Packer) { p.PushIntN(0, 9) }, ErrInvalidWidth},
+	} {
+		p := NewAppendPacker(binary.BigEndian, nil)
+		c.push(p)
+		assert.True(t, errors.Is(p.Error(), c.err), "error error.")
+		assert.Equal(t, len(p.Bytes()), 0, "write error.")
+	}
+
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushIntN(-1<<23, 3).PushIntN(1<<23-1, 3).PushUintN(1<<24-1, 3)
+	assert.Nil(t, p.Error(), "Has error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, []byte{1})
+	_, err := u.ShiftUintN(9)
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Err, ErrInvalidWidth, "width error.")
+	assert.Equal(t, e.Op, "ShiftUintN", "op error.")
+	_, err = u.ShiftIntN(2)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+}
+
+func TestUint128(t *testing.T) {
+	v := Uint128{Hi: 0x0102030405060708, Lo: 0x090A0B0C0D0E0F10}
+	be := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	le := []byte{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	for _, c := range []struct {
+		order binary.ByteOrder
+		want  []byte
+	}{{binary.BigEndian, be}, {binary.LittleEndian, le}} {
+		buf := new(bytes.Buffer)
+		p := NewPacker(c.order, buf)
+		p.PushUint128(v)
+		assert.Nil(t, p.Error(), "Has error.")
+		assert.Equal(t, buf.Bytes(), c.want, "uint128 error.")
+
+		var got Uint128
+		u := NewUnpacker(c.order, buf)
+		u.FetchUint128(&got)
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, got, v, "uint128 error.")
+	}
+
+	u := NewSliceUnpacker(binary.BigEndian, be[:15])
+	_, err := u.ShiftUint128()
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "eof error.")
+}
+
+func TestTraceIntN(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.LittleEndian, nil).WithTracer(tr)
+	p.PushBool(true).PushIntN(-2, 3).PushUint128(Uint128{Lo: 1})
+	assert.Equal(t, tr.lines, []string{
+		"push PushBool 0 01 true",
+		"push PushIntN 1 fe ff ff -2",
+		"push PushUint128 4 01 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 {0 1}",
+	}, "trace error.")
+}
+
+func TestIntNAllocs(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, make([]byte, 0, 64))
+	u := NewSliceUnpacker(binary.LittleEndian, make([]byte, 32))
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Reset()
+		p.PushUintN(1, 3).PushIntN(-1, 6).PushUint128(Uint128{})
+		u.Seek(0, io.SeekStart)
+		u.ShiftIntN(5)
+		u.ShiftUint128()
+	})
+	assert.Equal(t, allocs, float64(0), "allocs error.")
+}
@@ -0,0 +1,116 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrLimitExceeded is matched by errors.Is for every *LimitError.
+var ErrLimitExceeded = errors.New("binpacker: limit exceeded")
+
+// LimitError is returned when input asks for more than an UnpackerOptions
+// limit allows. It is returned before anything is allocated for the request.
+type LimitError struct {
+	Limit     string // name of the UnpackerOptions field
+	Max       uint64
+	Requested uint64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("binpacker: %s exceeded: requested %d, max %d", e.Limit, e.Requested, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// UnpackerOptions limits the resources an Unpacker spends on untrusted input.
+// A zero value means no limit.
+type UnpackerOptions struct {
+	// MaxBytesPerField limits the length of a single bytes or string field.
+	MaxBytesPerField uint64
+	// MaxTotalBytes limits the number of bytes read from the reader.
+	MaxTotalBytes uint64
+	// MaxElements limits the element count of a single slice.
+	MaxElements uint64
+	// MaxDepth limits the nesting depth of structs.
+	MaxDepth int
+}
+
+// NewUnpackerWithLimits returns a *Unpacker like NewUnpacker which enforces
+// the limits in opts.
+func NewUnpackerWithLimits(endian binary.ByteOrder, reader io.Reader, opts UnpackerOptions) *Unpacker {
+	u := NewUnpacker(endian, reader)
+	u.opts = opts
+	return u
+}
+
+// Options returns the limits u enforces.
+func (u *Unpacker) Options() UnpackerOptions {
+	return u.opts
+}
+
+func (u *Unpacker) checkField(n uint64) error {
+	if u.opts.MaxBytesPerField > 0 && n > u.opts.MaxBytesPerField {
+		return &LimitError{Limit: "MaxBytesPerField", Max: u.opts.MaxBytesPerField, Requested: n}
+	}
+	return nil
+}
+
+func (u *Unpacker) checkTotal(n uint64) error {
+	if u.opts.MaxTotalBytes > 0 && (n > u.opts.MaxTotalBytes || u.total > u.opts.MaxTotalBytes-n) {
+		return &LimitError{Limit: "MaxTotalBytes", Max: u.opts.MaxTotalBytes, Requested: u.total + n}
+	}
+	return nil
+}
+
+func (u *Unpacker) checkElements(n uint64) error {
+	if u.opts.MaxElements > 0 && n > u.opts.MaxElements {
+		return &LimitError{Limit: "MaxElements", Max: u.opts.MaxElements, Requested: n}
+	}
+	return nil
+}
+
+// enter records entering a nested struct, leave must be called when done.
+func (u *Unpacker) enter() error {
+	u.depth++
+	if u.opts.MaxDepth > 0 && u.depth > u.opts.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Max: uint64(u.opts.MaxDepth), Requested: uint64(u.depth)}
+	}
+	return nil
+}
+
+func (u *Unpacker) leave() {
+	u.depth--
+}
+
+// CheckElements fails u with a *LimitError, raised by op, if n elements are
+// more than its MaxElements allows. Like Fail, it lets methods written outside
+// the package, such as those binpacker-gen generates, enforce the limits the
+// way Unmarshal does.
+func (u *Unpacker) CheckElements(op string, n uint64) *Unpacker {
+	return u.errFilter(func() {
+		if err := u.checkElements(n); err != nil {
+			u.fail(op, err)
+		}
+	})
+}
+
+// Enter records that u enters a nested struct, failing with a *LimitError,
+// raised by op, if that is deeper than its MaxDepth allows. Leave must be
+// called when the struct is done, whether or not Enter failed.
+func (u *Unpacker) Enter(op string) *Unpacker {
+	if err := u.enter(); err != nil && u.err == nil {
+		u.fail(op, err)
+	}
+	return u
+}
+
+// Leave records that u leaves the struct entered with Enter.
+func (u *Unpacker) Leave() *Unpacker {
+	u.leave()
+	return u
+}
@@ -0,0 +1,154 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBytesPerField(t *testing.T) {
+	buf := new(bytes.Buffer)
+	NewPacker(binary.BigEndian, buf).PushUint64(1 << 62).PushUint16(2).PushString("Hi")
+	u := NewUnpackerWithLimits(binary.BigEndian, buf, UnpackerOptions{MaxBytesPerField: 1024})
+	var bs []byte
+	u.BytesWithUint64Prefix(&bs)
+	assert.True(t, errors.Is(u.Error(), ErrLimitExceeded), "limit error.")
+	var le *LimitError
+	assert.True(t, errors.As(u.Error(), &le), "limit error.")
+	assert.Equal(t, &LimitError{Limit: "MaxBytesPerField", Max: 1024, Requested: 1 << 62}, le, "limit error.")
+
+	var s string
+	u = NewUnpackerWithLimits(binary.BigEndian, buf, UnpackerOptions{MaxBytesPerField: 2})
+	u.StringWithUint16Prefix(&s)
+	assert.Equal(t, u.Error(), nil, "Has error.")
+	assert.Equal(t, s, "Hi", "string error.")
+}
+
+func TestMaxTotalBytes(t *testing.T) {
+	buf := bytes.NewReader(make([]byte, 16))
+	u := NewUnpackerWithLimits(binary.BigEndian, buf, UnpackerOptions{MaxTotalBytes: 10})
+	var i uint64
+	var bs []byte
+	u.FetchUint64(&i)
+	assert.Equal(t, u.Error(), nil, "Has error.")
+	u.FetchBytes(3, &bs)
+	assert.True(t, errors.Is(u.Error(), ErrLimitExceeded), "limit error.")
+	assert.Equal(t, buf.Len(), 8, "nothing should be read past the limit.")
+}
+
+func TestHostilePrefixWithoutLimits(t *testing.T) {
+	buf := new(bytes.Buffer)
+	NewPacker(binary.BigEndian, buf).PushUint64(1 << 40).PushString("short")
+	u := NewUnpacker(binary.BigEndian, buf)
+	var bs []byte
+	u.BytesWithUint64Prefix(&bs)
+	assert.ErrorIs(t, u.Error(), io.ErrUnexpectedEOF, "EOF error.")
+}
+
+func TestChunkedRead(t *testing.T) {
+	data := make([]byte, 3*readChunkSize+7)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	u := NewUnpacker(binary.BigEndian, &testReader{data: data, stride: 1000})
+	bs, err := u.ShiftBytes(uint64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, data, bs, "chunked read error.")
+
+	u = NewUnpacker(binary.BigEndian, bytes.NewReader(data))
+	bs, err = u.ShiftBytes(uint64(len(data) + 1))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "EOF error.")
+	assert.Equal(t, data, bs, "chunked read error.")
+}
+
+type limitsList struct {
+	Items []uint16 `bin:",prefix=uint16"`
+}
+
+type limitsNode struct {
+	Children []limitsNode `bin:",prefix=uint8"`
+}
+
+func TestMaxElementsAndDepth(t *testing.T) {
+	buf := new(bytes.Buffer)
+	NewPacker(binary.BigEndian, buf).PushStruct(limitsList{Items: make([]uint16, 5)})
+	var l limitsList
+	u := NewUnpackerWithLimits(binary.BigEndian, buf, UnpackerOptions{MaxElements: 4})
+	u.FetchStruct(&l)
+	assert.True(t, errors.Is(u.Error(), ErrLimitExceeded), "limit error.")
+
+	n := limitsNode{Children: []limitsNode{{Children: []limitsNode{{Children: []limitsNode{}}}}}}
+	buf.Reset()
+	NewPacker(binary.BigEndian, buf).PushStruct(n)
+	data := buf.Bytes()
+
+	var got limitsNode
+	u = NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(data), UnpackerOptions{MaxDepth: 3})
+	u.FetchStruct(&got)
+	assert.Equal(t, u.Error(), nil, "Has error.")
+	assert.Equal(t, n, got, "struct error.")
+
+	u = NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(data), UnpackerOptions{MaxDepth: 2})
+	u.FetchStruct(&got)
+	assert.True(t, errors.Is(u.Error(), ErrLimitExceeded), "limit error.")
+}
+
+func TestCheckElementsAndEnter(t *testing.T) {
+	u := NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(nil), UnpackerOptions{MaxElements: 4, MaxDepth: 1})
+	assert.Nil(t, u.CheckElements("Op", 4).Error(), "Has error.")
+	assert.Nil(t, u.Enter("Op").Error(), "Has error.")
+	u.Enter("Op").Leave()
+	var e *Error
+	assert.True(t, errors.As(u.Error(), &e), "error type error.")
+	assert.Equal(t, e.Op, "Op", "op error.")
+	assert.True(t, errors.Is(u.Error(), ErrLimitExceeded), "limit error.")
+
+	u = NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(nil), UnpackerOptions{MaxElements: 4})
+	u.CheckElements("Op", 5)
+	assert.True(t, errors.Is(u.Error(), ErrLimitExceeded), "limit error.")
+}
+
+func TestShiftRest(t *testing.T) {
+	data := make([]byte, readChunkSize+10)
+	data[len(data)-1] = 7
+	for _, u := range []*Unpacker{
+		NewSliceUnpacker(binary.BigEndian, data).Unpacker,
+		NewUnpacker(binary.BigEndian, bytes.NewReader(data)),
+	} {
+		u.Skip(2)
+		b, err := u.ShiftRest()
+		assert.Nil(t, err, "Has error.")
+		assert.Equal(t, len(b), len(data)-2, "rest error.")
+		assert.Equal(t, b[len(b)-1], byte(7), "rest error.")
+		assert.Equal(t, u.Offset(), int64(len(data)), "offset error.")
+		b, err = u.ShiftRest()
+		assert.Nil(t, err, "Has error.")
+		assert.Equal(t, len(b), 0, "rest error.")
+	}
+
+	u := NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(data), UnpackerOptions{MaxBytesPerField: 16})
+	_, err := u.ShiftRest()
+	assert.True(t, errors.Is(err, ErrLimitExceeded), "limit error.")
+	u = NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader(data), UnpackerOptions{MaxTotalBytes: 16})
+	_, err = u.ShiftRest()
+	assert.True(t, errors.Is(err, ErrLimitExceeded), "limit error.")
+}
+
+func TestSub(t *testing.T) {
+	opts := UnpackerOptions{MaxBytesPerField: 1, MaxTotalBytes: 2, MaxElements: 3}
+	u := NewUnpackerWithLimits(binary.LittleEndian, bytes.NewReader([]byte{1, 2, 3, 4}), opts).WithStrictBools(true)
+	b, err := u.ShiftBytes(1)
+	assert.Nil(t, err, "Has error.")
+	s := u.Sub([]byte{2, 0, 0, 0})
+	assert.Equal(t, s.Options(), UnpackerOptions{MaxBytesPerField: 1, MaxElements: 3}, "options error.")
+	assert.Equal(t, s.StrictBools(), true, "bools error.")
+	i, err := s.ShiftUint32()
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, i, uint32(2), "order error.")
+	_, err = u.Sub(b).ShiftBytes(2)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), "limit error.")
+}
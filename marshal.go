@@ -0,0 +1,647 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/zhuangsirui/binpacker/internal/bintag"
+)
+
+// Marshal packs the exported fields of the struct v into w in declaration
+// order, using the Push* methods of a Packer with the given byte order. v may be
+// a struct or a pointer to a struct.
+//
+// The encoding of each field is derived from its Go type and can be changed
+// with a "bin" struct tag:
+//
+//	bin:"uint16"               encode an integer field as uint16
+//	bin:"uint32,be"            override the byte order of the field
+//	bin:"string,prefix=uint8"  width of the length prefix of a string or slice
+//	bin:"bytes,len=16"         fixed length string or slice, no prefix
+//	bin:"q15.16,saturate"      signed fixed-point float, see PushFixed; uq for
+//	                           unsigned, and saturate or reject out-of-range
+//	                           values regardless of WithFixedOverflow
+//	bin:"-"                    skip the field
+//
+// Strings and slices without a prefix or len option are prefixed with a uint32
+// length. Fields of type int, uint and uintptr need an explicit type.
+func Marshal(order binary.ByteOrder, w io.Writer, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("binpacker: Marshal of non-struct type %T", v)
+	}
+	sc, err := cachedStructCodec(rv.Type())
+	if err != nil {
+		return err
+	}
+	p := NewPacker(order, w)
+	sc.encode(p, rv)
+	return p.Error()
+}
+
+// Unmarshal unpacks data from r into the exported fields of the struct pointed
+// to by v. It is the inverse of Marshal and honours the same struct tags.
+func Unmarshal(order binary.ByteOrder, r io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binpacker: Unmarshal needs a non-nil struct pointer, got %T", v)
+	}
+	sc, err := cachedStructCodec(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	u := NewUnpacker(order, r)
+	sc.decode(u, rv.Elem())
+	return u.Error()
+}
+
+// PushStruct packs the struct v like Marshal does.
+func (p *Packer) PushStruct(v interface{}) *Packer {
+	return p.errFilter(func() {
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		if rv.Kind() != reflect.Struct {
+			p.err = p.traceError(fmt.Errorf("binpacker: PushStruct of non-struct type %T", v))
+			return
+		}
+		sc, err := cachedStructCodec(rv.Type())
+		if err != nil {
+			p.err = p.traceError(err)
+			return
+		}
+		sc.encode(p, rv)
+	})
+}
+
+// FetchStruct unpacks into the struct pointed to by v like Unmarshal does,
+// within the limits of the Unpacker.
+func (u *Unpacker) FetchStruct(v interface{}) *Unpacker {
+	return u.errFilter(func() {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+			u.err = u.traceError(fmt.Errorf("binpacker: FetchStruct needs a non-nil struct pointer, got %T", v))
+			return
+		}
+		sc, err := cachedStructCodec(rv.Elem().Type())
+		if err != nil {
+			u.err = u.traceError(err)
+			return
+		}
+		sc.decode(u, rv.Elem())
+	})
+}
+
+// codec encodes and decodes one value. Errors are reported through the sticky
+// error of the Packer or Unpacker.
+type codec interface {
+	encode(p *Packer, v reflect.Value)
+	decode(u *Unpacker, v reflect.Value)
+}
+
+// kindTypes gives the default tag type name of each fixed-width Go kind.
+var kindTypes = map[reflect.Kind]string{
+	reflect.Bool:    "bool",
+	reflect.Uint8:   "uint8",
+	reflect.Int8:    "int8",
+	reflect.Uint16:  "uint16",
+	reflect.Int16:   "int16",
+	reflect.Uint32:  "uint32",
+	reflect.Int32:   "int32",
+	reflect.Uint64:  "uint64",
+	reflect.Int64:   "int64",
+	reflect.Float32: "float32",
+	reflect.Float64: "float64",
+}
+
+var (
+	codecCache sync.Map // map[reflect.Type]*structCodec
+	codecMu    sync.Mutex
+)
+
+func cachedStructCodec(t reflect.Type) (*structCodec, error) {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*structCodec), nil
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	building := map[reflect.Type]*structCodec{}
+	sc, err := buildStructCodec(t, building)
+	if err != nil {
+		return nil, err
+	}
+	// Only cache once t is complete, since the codecs built along the way may
+	// refer to it.
+	for bt, c := range building {
+		codecCache.Store(bt, c)
+	}
+	return sc, nil
+}
+
+// buildStructCodec builds the codec of struct type t. building holds the codecs
+// under construction so that recursive types refer to themselves; they are
+// cached by cachedStructCodec once the whole build succeeds.
+func buildStructCodec(t reflect.Type, building map[reflect.Type]*structCodec) (*structCodec, error) {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*structCodec), nil
+	}
+	if sc, ok := building[t]; ok {
+		return sc, nil
+	}
+	sc := &structCodec{name: t.String()}
+	building[t] = sc
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tg, err := bintag.Parse(f.Tag.Get("bin"))
+		if err != nil {
+			return nil, fmt.Errorf("binpacker: %s.%s: %v", t, f.Name, err)
+		}
+		if tg.Skip {
+			continue
+		}
+		c, err := buildCodec(f.Type, tg, building)
+		if err != nil {
+			return nil, fmt.Errorf("binpacker: %s.%s: %v", t, f.Name, err)
+		}
+		sc.fields = append(sc.fields, structField{index: i, codec: c})
+	}
+	return sc, nil
+}
+
+func buildCodec(t reflect.Type, tg bintag.Tag, building map[reflect.Type]*structCodec) (codec, error) {
+	c, err := buildValueCodec(t, tg, building)
+	if err != nil || tg.Order == nil {
+		return c, err
+	}
+	return orderCodec{order: tg.Order, codec: c}, nil
+}
+
+func buildValueCodec(t reflect.Type, tg bintag.Tag, building map[reflect.Type]*structCodec) (codec, error) {
+	elem := bintag.Tag{Type: tg.Type, Length: -1, Fixed: tg.Fixed, Overflow: tg.Overflow}
+	switch t.Kind() {
+	case reflect.String:
+		if tg.Type != "" && tg.Type != "string" {
+			return nil, fmt.Errorf("cannot encode string as %s", tg.Type)
+		}
+		return stringCodec{lengthCodec: newLengthCodec(tg)}, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && (tg.Type == "" || tg.Type == "bytes") {
+			return bytesCodec{lengthCodec: newLengthCodec(tg)}, nil
+		}
+		c, err := buildValueCodec(t.Elem(), elem, building)
+		if err != nil {
+			return nil, err
+		}
+		return sliceCodec{lengthCodec: newLengthCodec(tg), elem: c}, nil
+	case reflect.Array:
+		if tg.Prefix != 0 || tg.Length >= 0 {
+			return nil, errors.New("prefix and len are not allowed on arrays")
+		}
+		if t.Elem().Kind() == reflect.Uint8 && (tg.Type == "" || tg.Type == "bytes") {
+			return byteArrayCodec{}, nil
+		}
+		c, err := buildValueCodec(t.Elem(), elem, building)
+		if err != nil {
+			return nil, err
+		}
+		return arrayCodec{elem: c}, nil
+	case reflect.Struct:
+		if tg.Type != "" {
+			return nil, fmt.Errorf("cannot encode struct as %s", tg.Type)
+		}
+		return buildStructCodec(t, building)
+	}
+	if tg.Prefix != 0 || tg.Length >= 0 {
+		return nil, fmt.Errorf("prefix and len are not allowed on %s", t)
+	}
+	if tg.Fixed != nil {
+		if t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+			return nil, fmt.Errorf("cannot encode %s as %s", t, tg.Type)
+		}
+		c := fixedCodec{format: Fixed{IntBits: tg.Fixed.IntBits, FracBits: tg.Fixed.FracBits, Signed: tg.Fixed.Signed}}
+		switch tg.Overflow {
+		case "saturate":
+			c.overflow = SaturateOutOfRange
+		case "reject":
+			c.overflow = RejectOutOfRange
+		default:
+			c.packer = true
+		}
+		return c, nil
+	}
+	typ := tg.Type
+	if typ == "" {
+		typ = kindTypes[t.Kind()]
+	}
+	st, ok := bintag.Scalars[typ]
+	if !ok {
+		if typ == "" {
+			return nil, fmt.Errorf("%s needs an explicit type", t)
+		}
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if !st.Float {
+			return nil, fmt.Errorf("cannot encode %s as %s", t, typ)
+		}
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if st.Float {
+			return nil, fmt.Errorf("cannot encode %s as %s", t, typ)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+	return scalarCodec{width: st.Width, signed: st.Signed, float: st.Float}, nil
+}
+
+type structField struct {
+	index int
+	codec codec
+}
+
+type structCodec struct {
+	name   string // type name, for tracing
+	fields []structField
+}
+
+func (c *structCodec) encode(p *Packer, v reflect.Value) {
+	p.traceEnter(c.name)
+	for _, f := range c.fields {
+		if p.err != nil {
+			break
+		}
+		f.codec.encode(p, v.Field(f.index))
+	}
+	p.traceLeave(c.name)
+}
+
+func (c *structCodec) decode(u *Unpacker, v reflect.Value) {
+	u.traceEnter(c.name)
+	if err := u.enter(); err != nil {
+		u.fail("FetchStruct", err)
+	}
+	for _, f := range c.fields {
+		if u.err != nil {
+			break
+		}
+		f.codec.decode(u, v.Field(f.index))
+	}
+	u.leave()
+	u.traceLeave(c.name)
+}
+
+// orderCodec runs its codec with a different byte order.
+type orderCodec struct {
+	order binary.ByteOrder
+	codec codec
+}
+
+func (c orderCodec) encode(p *Packer, v reflect.Value) {
+	endian := p.endian
+	p.endian = c.order
+	c.codec.encode(p, v)
+	p.endian = endian
+}
+
+func (c orderCodec) decode(u *Unpacker, v reflect.Value) {
+	endian := u.endian
+	u.endian = c.order
+	c.codec.decode(u, v)
+	u.endian = endian
+}
+
+// fixedCodec encodes floats as Q-format fixed-point values.
+type fixedCodec struct {
+	format   Fixed
+	overflow FixedOverflow
+	packer   bool // whether the Packer's policy applies instead of overflow
+}
+
+func (c fixedCodec) encode(p *Packer, v reflect.Value) {
+	o := c.overflow
+	if c.packer {
+		o = p.fixed
+	}
+	p.pushFixedFloat(v.Float(), c.format, o)
+}
+
+func (c fixedCodec) decode(u *Unpacker, v reflect.Value) {
+	f, err := u.shiftFixed("ShiftFixed", c.format)
+	if err != nil {
+		u.err = err
+		return
+	}
+	v.SetFloat(f.Float64())
+}
+
+// scalarCodec encodes booleans, integers and floats as fixed-width values.
+type scalarCodec struct {
+	width  int
+	signed bool
+	float  bool
+}
+
+func (c scalarCodec) encode(p *Packer, v reflect.Value) {
+	var x uint64
+	fits := true
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			x = 1
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		x = uint64(i)
+		if c.signed {
+			fits = signExtend(x, c.width) == i
+		} else {
+			fits = i >= 0 && c.fitsUnsigned(x)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x = v.Uint()
+		if c.signed {
+			fits = x <= math.MaxInt64 && signExtend(x, c.width) == int64(x)
+		} else {
+			fits = c.fitsUnsigned(x)
+		}
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if c.width == 4 {
+			x = uint64(math.Float32bits(float32(f)))
+			fits = math.IsInf(f, 0) || !math.IsInf(float64(float32(f)), 0)
+		} else {
+			x = math.Float64bits(f)
+		}
+	}
+	if !fits {
+		p.fail("PushStruct", ErrValueOverflow)
+		return
+	}
+	op := pushOps[c.kind()]
+	raw := putUintN(p.endian, p.scratch[:], x, c.width)
+	p.write(op, raw)
+	if p.tracer != nil {
+		p.tracePushed(op, raw, v.Interface())
+	}
+}
+
+// kind returns the kind of Number that c encodes.
+func (c scalarCodec) kind() int {
+	switch {
+	case c.width == 1 && c.signed:
+		return kindInt8
+	case c.width == 1:
+		return kindUint8
+	case c.width == 2 && c.signed:
+		return kindInt16
+	case c.width == 2:
+		return kindUint16
+	case c.width == 4 && c.float:
+		return kindFloat32
+	case c.width == 4 && c.signed:
+		return kindInt32
+	case c.width == 4:
+		return kindUint32
+	case c.float:
+		return kindFloat64
+	case c.signed:
+		return kindInt64
+	}
+	return kindUint64
+}
+
+// fitsUnsigned reports whether x fits in the width of c.
+func (c scalarCodec) fitsUnsigned(x uint64) bool {
+	return c.width == 8 || x < 1<<(8*uint(c.width))
+}
+
+func (c scalarCodec) decode(u *Unpacker, v reflect.Value) {
+	op := shiftOps[c.kind()]
+	raw, err := u.read(op, uint64(c.width))
+	if err != nil {
+		u.err = err
+		return
+	}
+	// A read from a reader returns the start of scratch, so decode in the rest.
+	x := getUintN(u.endian, u.scratch[8:], raw)
+	var overflow bool
+	switch v.Kind() {
+	case reflect.Bool:
+		if u.bools && x > 1 {
+			u.err = u.traceError(&Error{Op: "FetchStruct", Offset: u.offset - int64(c.width), Err: ErrInvalidBool})
+			return
+		}
+		v.SetBool(x != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := int64(x)
+		if c.signed {
+			i = signExtend(x, c.width)
+		}
+		if overflow = !c.signed && x > math.MaxInt64 || v.OverflowInt(i); !overflow {
+			v.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if overflow = c.signed && signExtend(x, c.width) < 0 || v.OverflowUint(x); !overflow {
+			v.SetUint(x)
+		}
+	case reflect.Float32, reflect.Float64:
+		f := math.Float64frombits(x)
+		if c.width == 4 {
+			f = float64(math.Float32frombits(uint32(x)))
+		}
+		if overflow = v.OverflowFloat(f); !overflow {
+			v.SetFloat(f)
+		}
+	}
+	if overflow {
+		u.err = u.traceError(&Error{Op: "FetchStruct", Offset: u.offset - int64(c.width), Err: ErrValueOverflow})
+		return
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, raw, v.Interface())
+	}
+}
+
+func pushUint(p *Packer, width int, x uint64) {
+	switch width {
+	case 1:
+		p.PushUint8(uint8(x))
+	case 2:
+		p.PushUint16(uint16(x))
+	case 4:
+		p.PushUint32(uint32(x))
+	case 8:
+		p.PushUint64(x)
+	}
+}
+
+func shiftUint(u *Unpacker, width int) uint64 {
+	var x uint64
+	switch width {
+	case 1:
+		var i uint8
+		u.FetchUint8(&i)
+		x = uint64(i)
+	case 2:
+		var i uint16
+		u.FetchUint16(&i)
+		x = uint64(i)
+	case 4:
+		var i uint32
+		u.FetchUint32(&i)
+		x = uint64(i)
+	case 8:
+		u.FetchUint64(&x)
+	}
+	return x
+}
+
+// lengthCodec writes and reads the length of strings and slices, either as a
+// prefix or as a fixed length.
+type lengthCodec struct {
+	prefix int
+	length int
+}
+
+func newLengthCodec(tg bintag.Tag) lengthCodec {
+	c := lengthCodec{prefix: tg.Prefix, length: tg.Length}
+	if c.prefix == 0 && c.length < 0 {
+		c.prefix = bintag.DefaultPrefix
+	}
+	return c
+}
+
+func (c lengthCodec) encodeLength(p *Packer, n int) {
+	p.errFilter(func() {
+		if c.length >= 0 {
+			if n != c.length {
+				p.fail("PushStruct", ErrLengthMismatch)
+			}
+			return
+		}
+		if c.prefix < 8 && uint64(n) >= 1<<(8*uint(c.prefix)) {
+			p.fail("PushStruct", ErrLengthOverflow)
+			return
+		}
+		pushUint(p, c.prefix, uint64(n))
+	})
+}
+
+func (c lengthCodec) decodeLength(u *Unpacker) uint64 {
+	if c.length >= 0 {
+		return uint64(c.length)
+	}
+	return shiftUint(u, c.prefix)
+}
+
+type stringCodec struct {
+	lengthCodec
+}
+
+func (c stringCodec) encode(p *Packer, v reflect.Value) {
+	c.encodeLength(p, v.Len())
+	p.PushString(v.String())
+}
+
+func (c stringCodec) decode(u *Unpacker, v reflect.Value) {
+	var s string
+	u.FetchString(c.decodeLength(u), &s)
+	v.SetString(s)
+}
+
+type bytesCodec struct {
+	lengthCodec
+}
+
+func (c bytesCodec) encode(p *Packer, v reflect.Value) {
+	c.encodeLength(p, v.Len())
+	p.PushBytes(v.Bytes())
+}
+
+func (c bytesCodec) decode(u *Unpacker, v reflect.Value) {
+	var b []byte
+	u.FetchBytes(c.decodeLength(u), &b)
+	if u.err == nil {
+		v.SetBytes(b)
+	}
+}
+
+type sliceCodec struct {
+	lengthCodec
+	elem codec
+}
+
+func (c sliceCodec) encode(p *Packer, v reflect.Value) {
+	c.encodeLength(p, v.Len())
+	for i := 0; i < v.Len() && p.err == nil; i++ {
+		c.elem.encode(p, v.Index(i))
+	}
+}
+
+func (c sliceCodec) decode(u *Unpacker, v reflect.Value) {
+	n := c.decodeLength(u)
+	if u.err != nil {
+		return
+	}
+	if err := u.checkElements(n); err != nil {
+		u.fail("FetchStruct", err)
+		return
+	}
+	// Grow the slice as elements arrive instead of trusting the length.
+	s := reflect.MakeSlice(v.Type(), 0, int(minUint64(n, 1024)))
+	for i := uint64(0); i < n && u.err == nil; i++ {
+		s = reflect.Append(s, reflect.Zero(v.Type().Elem()))
+		c.elem.decode(u, s.Index(s.Len()-1))
+	}
+	if u.err == nil {
+		v.Set(s)
+	}
+}
+
+type byteArrayCodec struct{}
+
+func (byteArrayCodec) encode(p *Packer, v reflect.Value) {
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	p.PushBytes(b)
+}
+
+func (byteArrayCodec) decode(u *Unpacker, v reflect.Value) {
+	var b []byte
+	u.FetchBytes(uint64(v.Len()), &b)
+	if u.err == nil {
+		reflect.Copy(v, reflect.ValueOf(b))
+	}
+}
+
+type arrayCodec struct {
+	elem codec
+}
+
+func (c arrayCodec) encode(p *Packer, v reflect.Value) {
+	for i := 0; i < v.Len() && p.err == nil; i++ {
+		c.elem.encode(p, v.Index(i))
+	}
+}
+
+func (c arrayCodec) decode(u *Unpacker, v reflect.Value) {
+	for i := 0; i < v.Len() && u.err == nil; i++ {
+		c.elem.decode(u, v.Index(i))
+	}
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
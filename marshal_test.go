@@ -0,0 +1,187 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type marshalInner struct {
+	X uint16
+	Y int8
+}
+
+type marshalHeader struct {
+	Magic   [4]byte
+	Version uint16 `bin:",le"`
+	Flags   uint32
+	Length  int `bin:"uint16"`
+	Enabled bool
+	Ratio   float32
+	Name    string   `bin:"string,prefix=uint8"`
+	Payload []byte   `bin:"bytes,prefix=uint16"`
+	Tag     string   `bin:",len=2"`
+	Points  []uint16 `bin:"uint16,prefix=uint8,le"`
+	Inner   marshalInner
+	Inners  [2]marshalInner
+	Ignored string `bin:"-"`
+	private uint32
+}
+
+func TestMarshal(t *testing.T) {
+	h := marshalHeader{
+		Magic:   [4]byte{'B', 'I', 'N', 'P'},
+		Version: 1,
+		Flags:   2,
+		Length:  3,
+		Enabled: true,
+		Ratio:   1,
+		Name:    "Hi",
+		Payload: []byte{0xAA},
+		Tag:     "ok",
+		Points:  []uint16{1, 2},
+		Inner:   marshalInner{X: 4, Y: -1},
+		Inners:  [2]marshalInner{{X: 5, Y: 6}, {X: 7, Y: 8}},
+		Ignored: "ignored",
+		private: 9,
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, Marshal(binary.BigEndian, buf, &h))
+	assert.Equal(t, []byte{
+		'B', 'I', 'N', 'P',
+		1, 0,
+		0, 0, 0, 2,
+		0, 3,
+		1,
+		0x3f, 0x80, 0, 0,
+		2, 'H', 'i',
+		0, 1, 0xAA,
+		'o', 'k',
+		2, 1, 0, 2, 0,
+		0, 4, 0xff,
+		0, 5, 6, 0, 7, 8,
+	}, buf.Bytes(), "marshal error.")
+
+	var got marshalHeader
+	assert.NoError(t, Unmarshal(binary.BigEndian, buf, &got))
+	h.Ignored, h.private = "", 0
+	assert.Equal(t, h, got, "unmarshal error.")
+}
+
+type marshalNode struct {
+	Value    uint8
+	Children []marshalNode `bin:",prefix=uint8"`
+}
+
+func TestMarshalRecursive(t *testing.T) {
+	n := marshalNode{Value: 1, Children: []marshalNode{{Value: 2, Children: []marshalNode{}}}}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, Marshal(binary.BigEndian, buf, n))
+	assert.Equal(t, []byte{1, 1, 2, 0}, buf.Bytes(), "marshal error.")
+	var got marshalNode
+	assert.NoError(t, Unmarshal(binary.BigEndian, buf, &got))
+	assert.Equal(t, n, got, "unmarshal error.")
+}
+
+type marshalBadTree struct {
+	Leaf marshalBadLeaf
+	Size int
+}
+
+type marshalBadLeaf struct {
+	Value  uint8
+	Parent []marshalBadTree `bin:",prefix=uint8"`
+}
+
+func TestMarshalRecursiveError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Error(t, Marshal(binary.BigEndian, buf, marshalBadTree{}))
+	// marshalBadLeaf was built before Size failed, and must not be cached with
+	// the incomplete codec of marshalBadTree.
+	assert.Error(t, Marshal(binary.BigEndian, buf, marshalBadLeaf{Value: 1}))
+	var leaf marshalBadLeaf
+	assert.Error(t, Unmarshal(binary.BigEndian, bytes.NewReader([]byte{1, 0}), &leaf))
+}
+
+func TestMarshalErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.Error(t, Marshal(binary.BigEndian, buf, 1))
+	assert.Error(t, Marshal(binary.BigEndian, buf, struct{ A int }{}))
+	assert.Error(t, Marshal(binary.BigEndian, buf, struct {
+		A uint16 `bin:"float32"`
+	}{}))
+	assert.Error(t, Marshal(binary.BigEndian, buf, struct {
+		A string `bin:",prefix=uint3"`
+	}{}))
+	assert.Error(t, Marshal(binary.BigEndian, buf, struct {
+		A string `bin:",prefix=uint8"`
+	}{A: string(make([]byte, 256))}))
+	assert.Error(t, Marshal(binary.BigEndian, buf, struct {
+		A string `bin:",len=3"`
+	}{A: "Hi"}))
+	var s struct{ A uint16 }
+	assert.Error(t, Unmarshal(binary.BigEndian, buf, s))
+	assert.Error(t, Unmarshal(binary.BigEndian, bytes.NewReader([]byte{1}), &s))
+}
+
+func TestMarshalRange(t *testing.T) {
+	for _, v := range []interface{}{
+		struct {
+			A int `bin:"uint8"`
+		}{A: 300},
+		struct {
+			A int `bin:"uint8"`
+		}{A: -1},
+		struct {
+			A int `bin:"int8"`
+		}{A: 128},
+		struct {
+			A uint32 `bin:"int16"`
+		}{A: 40000},
+		struct {
+			A float64 `bin:"float32"`
+		}{A: 1e300},
+	} {
+		err := Marshal(binary.BigEndian, new(bytes.Buffer), v)
+		assert.ErrorIs(t, err, ErrValueOverflow, "%+v", v)
+	}
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, Marshal(binary.BigEndian, buf, struct {
+		A int    `bin:"uint8"`
+		B int    `bin:"int8"`
+		C uint64 `bin:"int64"`
+	}{A: 255, B: -128, C: 1 << 62}))
+	assert.Equal(t, []byte{0xFF, 0x80, 0x40, 0, 0, 0, 0, 0, 0, 0}, buf.Bytes(), "marshal error.")
+
+	var a struct {
+		A uint8 `bin:"uint16"`
+	}
+	err := Unmarshal(binary.BigEndian, bytes.NewReader([]byte{0x12, 0x34}), &a)
+	var e *Error
+	assert.ErrorAs(t, err, &e)
+	assert.Equal(t, ErrValueOverflow, e.Err, "overflow error.")
+	assert.Equal(t, int64(0), e.Offset, "offset error.")
+	assert.NoError(t, Unmarshal(binary.BigEndian, bytes.NewReader([]byte{0, 0xFF}), &a))
+	assert.Equal(t, uint8(0xFF), a.A, "unmarshal error.")
+
+	var b struct {
+		A uint16 `bin:"int8"`
+		B int8   `bin:"uint8"`
+		C int64  `bin:"uint64"`
+	}
+	for _, data := range [][]byte{
+		{0xFF},
+		{1, 0x80},
+		{1, 1, 0x80, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		err := Unmarshal(binary.BigEndian, bytes.NewReader(data), &b)
+		assert.ErrorIs(t, err, ErrValueOverflow, "%x", data)
+	}
+	var f struct {
+		A float32 `bin:"float64"`
+	}
+	assert.ErrorIs(t, Unmarshal(binary.BigEndian, bytes.NewReader([]byte{0x7F, 0xE0, 0, 0, 0, 0, 0, 0}), &f), ErrValueOverflow)
+}
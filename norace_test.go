@@ -0,0 +1,7 @@
+//go:build !race
+
+package binpacker
+
+// raceEnabled reports whether the tests run with the race detector, which
+// changes escape analysis and so the number of allocations.
+const raceEnabled = false
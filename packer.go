@@ -2,17 +2,42 @@ package binpacker
 
 import (
 	"encoding/binary"
+	"errors"
 	"io"
 	"math"
+	"unsafe"
+)
+
+var (
+	// ErrLengthOverflow is returned when a length does not fit in its prefix.
+	ErrLengthOverflow = errors.New("binpacker: length overflows the prefix")
+	// ErrLengthMismatch is returned when a string or slice does not have the
+	// fixed length of its field.
+	ErrLengthMismatch = errors.New("binpacker: length does not match the fixed length")
 )
 
 // Packer is a binary packer helps you pack data into an io.Writer.
 type Packer struct {
-	writer io.Writer
-	endian binary.ByteOrder
-	err    error
+	writer    io.Writer
+	endian    binary.ByteOrder
+	err       error
+	offset    int64 // bytes written so far
+	appending bool  // whether data is appended to buf instead of writer
+	holding   bool  // whether data is held in buf until placeholders are filled
+	buf       []byte
+	reserved  []int64 // offsets of unfilled placeholders
+	sections  int     // number of open Checksummed sections
+	overflow  StringOverflow
+	fixed     FixedOverflow
+	tracer    Tracer
+	scopes    ScopeTracer // tracer, if it is a ScopeTracer
+	scratch   [scratchSize]byte
 }
 
+// scratchSize is the size of the scratch buffers, enough for a Uint128 or a
+// varint.
+const scratchSize = 16
+
 // NewPacker returns a *Packer hold an io.Writer. User must provide the byte order explicitly.
 func NewPacker(endian binary.ByteOrder, writer io.Writer) *Packer {
 	return &Packer{
@@ -21,91 +46,315 @@ func NewPacker(endian binary.ByteOrder, writer io.Writer) *Packer {
 	}
 }
 
+// NewAppendPacker returns a *Packer which appends to dst instead of writing to
+// an io.Writer. Pushing values does not allocate once the buffer has grown
+// large enough; use Bytes to get the packed data and Reset to reuse the
+// buffer.
+func NewAppendPacker(endian binary.ByteOrder, dst []byte) *Packer {
+	return &Packer{
+		endian:    endian,
+		appending: true,
+		buf:       dst,
+	}
+}
+
+// Bytes returns the data appended so far by a Packer made with
+// NewAppendPacker, including the initial contents of dst.
+func (p *Packer) Bytes() []byte {
+	return p.buf
+}
+
+// Reset empties the buffer of a Packer made with NewAppendPacker, keeping its
+// capacity, and clears the error and offset.
+func (p *Packer) Reset() {
+	p.buf = p.buf[:0]
+	p.reserved = p.reserved[:0]
+	p.holding = false
+	p.err = nil
+	p.offset = 0
+}
+
 // Error returns an error if any errors exists
 func (p *Packer) Error() error {
 	return p.err
 }
 
+// Offset returns the number of bytes written so far.
+func (p *Packer) Offset() int64 {
+	return p.offset
+}
+
 // PushByte write a single byte into writer.
 func (p *Packer) PushByte(b byte) *Packer {
-	return p.errFilter(func() {
-		_, p.err = p.writer.Write([]byte{b})
-	})
+	return pushUint8(p, "PushByte", b)
 }
 
 // PushBytes write a bytes array into writer.
 func (p *Packer) PushBytes(bytes []byte) *Packer {
 	return p.errFilter(func() {
-		_, p.err = p.writer.Write(bytes)
+		p.write("PushBytes", bytes)
+		if p.tracer != nil {
+			p.tracePushed("PushBytes", bytes, bytes)
+		}
 	})
 }
 
 // PushUint8 write a uint8 into writer.
 func (p *Packer) PushUint8(i uint8) *Packer {
+	return pushUint8(p, "PushUint8", i)
+}
+
+// pushUint8 writes v, a 1-byte Number, for op and traces it as it is.
+func pushUint8[T Number](p *Packer, op string, v T) *Packer {
 	return p.errFilter(func() {
-		_, p.err = p.writer.Write([]byte{byte(i)})
+		p.scratch[0] = *(*uint8)(unsafe.Pointer(&v))
+		p.write(op, p.scratch[:1])
+		if p.tracer != nil {
+			p.tracePushed(op, p.scratch[:1], v)
+		}
+	})
+}
+
+// PushInt8 write a int8 into writer.
+func (p *Packer) PushInt8(i int8) *Packer {
+	return pushUint8(p, "PushInt8", i)
+}
+
+// PushBool write a bool as a byte, 1 for true and 0 for false, into writer.
+func (p *Packer) PushBool(v bool) *Packer {
+	return p.errFilter(func() {
+		const op = "PushBool"
+		p.scratch[0] = 0
+		if v {
+			p.scratch[0] = 1
+		}
+		p.write(op, p.scratch[:1])
+		if p.tracer != nil {
+			p.tracePushed(op, p.scratch[:1], v)
+		}
 	})
 }
 
 // PushUint16 write a uint16 into writer.
 func (p *Packer) PushUint16(i uint16) *Packer {
+	return pushUint16(p, "PushUint16", p.endian, i)
+}
+
+// pushUint16 writes v, a 2-byte Number, in order for op and traces it as it
+// is.
+func pushUint16[T Number](p *Packer, op string, order binary.ByteOrder, v T) *Packer {
 	return p.errFilter(func() {
-		buffer := make([]byte, 2)
-		p.endian.PutUint16(buffer, i)
-		_, p.err = p.writer.Write(buffer)
+		order.PutUint16(p.scratch[:2], *(*uint16)(unsafe.Pointer(&v)))
+		p.write(op, p.scratch[:2])
+		if p.tracer != nil {
+			p.tracePushed(op, p.scratch[:2], v)
+		}
 	})
 }
 
 // PushUint16 write a int16 into writer.
 func (p *Packer) PushInt16(i int16) *Packer {
-	return p.PushUint16(uint16(i))
+	return pushUint16(p, "PushInt16", p.endian, i)
 }
 
 // PushUint32 write a uint32 into writer.
 func (p *Packer) PushUint32(i uint32) *Packer {
+	return pushUint32(p, "PushUint32", p.endian, i)
+}
+
+// pushUint32 writes v, a 4-byte Number, in order for op and traces it as it
+// is.
+func pushUint32[T Number](p *Packer, op string, order binary.ByteOrder, v T) *Packer {
 	return p.errFilter(func() {
-		buffer := make([]byte, 4)
-		p.endian.PutUint32(buffer, i)
-		_, p.err = p.writer.Write(buffer)
+		order.PutUint32(p.scratch[:4], *(*uint32)(unsafe.Pointer(&v)))
+		p.write(op, p.scratch[:4])
+		if p.tracer != nil {
+			p.tracePushed(op, p.scratch[:4], v)
+		}
 	})
 }
 
 // PushInt32 write a int32 into writer.
 func (p *Packer) PushInt32(i int32) *Packer {
-	return p.PushUint32(uint32(i))
+	return pushUint32(p, "PushInt32", p.endian, i)
 }
 
 // PushUint64 write a uint64 into writer.
 func (p *Packer) PushUint64(i uint64) *Packer {
+	return pushUint64(p, "PushUint64", p.endian, i)
+}
+
+// pushUint64 writes v, an 8-byte Number, in order for op and traces it as it
+// is.
+func pushUint64[T Number](p *Packer, op string, order binary.ByteOrder, v T) *Packer {
 	return p.errFilter(func() {
-		buffer := make([]byte, 8)
-		p.endian.PutUint64(buffer, i)
-		_, p.err = p.writer.Write(buffer)
+		order.PutUint64(p.scratch[:8], *(*uint64)(unsafe.Pointer(&v)))
+		p.write(op, p.scratch[:8])
+		if p.tracer != nil {
+			p.tracePushed(op, p.scratch[:8], v)
+		}
 	})
 }
 
 // PushInt64 write a int64 into writer.
 func (p *Packer) PushInt64(i int64) *Packer {
-	return p.PushUint64(uint64(i))
+	return pushUint64(p, "PushInt64", p.endian, i)
 }
 
 // PushFloat32 write a float32 into writer.
 func (p *Packer) PushFloat32(i float32) *Packer {
-	return p.PushUint32(math.Float32bits(i))
+	return pushUint32(p, "PushFloat32", p.endian, i)
 }
 
 // PushFloat64 write a float64 into writer.
 func (p *Packer) PushFloat64(i float64) *Packer {
-	return p.PushUint64(math.Float64bits(i))
+	return pushUint64(p, "PushFloat64", p.endian, i)
 }
 
 // PushString write a string into writer.
 func (p *Packer) PushString(s string) *Packer {
 	return p.errFilter(func() {
-		_, p.err = p.writer.Write([]byte(s))
+		p.writeString("PushString", s)
+	})
+}
+
+// PushStringWithUint8Prefix write the length of string as a uint8 prefix,
+// then string into writer.
+func (p *Packer) PushStringWithUint8Prefix(s string) *Packer {
+	return p.errFilter(func() {
+		if uint64(len(s)) > math.MaxUint8 {
+			p.fail("PushStringWithUint8Prefix", ErrLengthOverflow)
+			return
+		}
+		p.PushUint8(uint8(len(s))).PushString(s)
 	})
 }
 
+// PushStringWithUint16Prefix write the length of string as a uint16 prefix,
+// then string into writer.
+func (p *Packer) PushStringWithUint16Prefix(s string) *Packer {
+	return p.errFilter(func() {
+		if uint64(len(s)) > math.MaxUint16 {
+			p.fail("PushStringWithUint16Prefix", ErrLengthOverflow)
+			return
+		}
+		p.PushUint16(uint16(len(s))).PushString(s)
+	})
+}
+
+// PushStringWithUint32Prefix write the length of string as a uint32 prefix,
+// then string into writer.
+func (p *Packer) PushStringWithUint32Prefix(s string) *Packer {
+	return p.errFilter(func() {
+		if uint64(len(s)) > math.MaxUint32 {
+			p.fail("PushStringWithUint32Prefix", ErrLengthOverflow)
+			return
+		}
+		p.PushUint32(uint32(len(s))).PushString(s)
+	})
+}
+
+// PushStringWithUint64Prefix write the length of string as a uint64 prefix,
+// then string into writer.
+func (p *Packer) PushStringWithUint64Prefix(s string) *Packer {
+	return p.PushUint64(uint64(len(s))).PushString(s)
+}
+
+// PushBytesWithUint8Prefix write the length of bytes as a uint8 prefix,
+// then bytes into writer.
+func (p *Packer) PushBytesWithUint8Prefix(bytes []byte) *Packer {
+	return p.errFilter(func() {
+		if uint64(len(bytes)) > math.MaxUint8 {
+			p.fail("PushBytesWithUint8Prefix", ErrLengthOverflow)
+			return
+		}
+		p.PushUint8(uint8(len(bytes))).PushBytes(bytes)
+	})
+}
+
+// PushBytesWithUint16Prefix write the length of bytes as a uint16 prefix,
+// then bytes into writer.
+func (p *Packer) PushBytesWithUint16Prefix(bytes []byte) *Packer {
+	return p.errFilter(func() {
+		if uint64(len(bytes)) > math.MaxUint16 {
+			p.fail("PushBytesWithUint16Prefix", ErrLengthOverflow)
+			return
+		}
+		p.PushUint16(uint16(len(bytes))).PushBytes(bytes)
+	})
+}
+
+// PushBytesWithUint32Prefix write the length of bytes as a uint32 prefix,
+// then bytes into writer.
+func (p *Packer) PushBytesWithUint32Prefix(bytes []byte) *Packer {
+	return p.errFilter(func() {
+		if uint64(len(bytes)) > math.MaxUint32 {
+			p.fail("PushBytesWithUint32Prefix", ErrLengthOverflow)
+			return
+		}
+		p.PushUint32(uint32(len(bytes))).PushBytes(bytes)
+	})
+}
+
+// PushBytesWithUint64Prefix write the length of bytes as a uint64 prefix,
+// then bytes into writer.
+func (p *Packer) PushBytesWithUint64Prefix(bytes []byte) *Packer {
+	return p.PushUint64(uint64(len(bytes))).PushBytes(bytes)
+}
+
+// write writes b for the operation op. Errors are recorded as *Error; the
+// value is traced by the caller, which has it.
+func (p *Packer) write(op string, b []byte) {
+	offset := p.offset
+	if p.appending || p.holding {
+		p.buf = append(p.buf, b...)
+		p.offset += int64(len(b))
+		return
+	}
+	n, err := p.writer.Write(b)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	p.offset += int64(n)
+	if err != nil {
+		p.err = p.traceError(&Error{Op: op, Offset: offset, Want: uint64(len(b)), Got: uint64(n), Err: err})
+	}
+}
+
+// writeString is write for strings, avoiding a copy where possible, and
+// traces s.
+func (p *Packer) writeString(op string, s string) {
+	offset := p.offset
+	if p.appending || p.holding {
+		p.buf = append(p.buf, s...)
+		p.offset += int64(len(s))
+		if p.tracer != nil {
+			p.tracePushed(op, p.buf[len(p.buf)-len(s):], s)
+		}
+		return
+	}
+	if sw, ok := p.writer.(io.StringWriter); ok {
+		n, err := sw.WriteString(s)
+		if err == nil && n < len(s) {
+			err = io.ErrShortWrite
+		}
+		p.offset += int64(n)
+		if err != nil {
+			p.err = p.traceError(&Error{Op: op, Offset: offset, Want: uint64(len(s)), Got: uint64(n), Err: err})
+			return
+		}
+		if p.tracer != nil {
+			p.tracePushed(op, []byte(s), s)
+		}
+		return
+	}
+	b := []byte(s)
+	p.write(op, b)
+	if p.tracer != nil {
+		p.tracePushed(op, b, s)
+	}
+}
+
 func (p *Packer) errFilter(f func()) *Packer {
 	if p.err == nil {
 		f()
@@ -113,3 +113,134 @@ func TestCombinedPush(t *testing.T) {
 	assert.Equal(t, p.Error(), nil, "Has error.")
 	assert.Equal(t, b.Bytes(), []byte{0, 1, 'H', 'i'}, "combine push error.")
 }
+
+func TestPushWithPrefix(t *testing.T) {
+	b := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, b)
+	p.PushStringWithUint8Prefix("Hi").
+		PushStringWithUint16Prefix("Hi").
+		PushStringWithUint32Prefix("Hi").
+		PushStringWithUint64Prefix("Hi")
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, b.Bytes(), []byte{
+		2, 'H', 'i',
+		0, 2, 'H', 'i',
+		0, 0, 0, 2, 'H', 'i',
+		0, 0, 0, 0, 0, 0, 0, 2, 'H', 'i',
+	}, "string with prefix error.")
+
+	b.Reset()
+	p = NewPacker(binary.LittleEndian, b)
+	p.PushBytesWithUint8Prefix([]byte("Hi")).
+		PushBytesWithUint16Prefix([]byte("Hi")).
+		PushBytesWithUint32Prefix([]byte("Hi")).
+		PushBytesWithUint64Prefix([]byte("Hi"))
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, b.Bytes(), []byte{
+		2, 'H', 'i',
+		2, 0, 'H', 'i',
+		2, 0, 0, 0, 'H', 'i',
+		2, 0, 0, 0, 0, 0, 0, 0, 'H', 'i',
+	}, "bytes with prefix error.")
+}
+
+func TestPushWithPrefixOverflow(t *testing.T) {
+	b := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, b)
+	p.PushBytesWithUint8Prefix(make([]byte, math.MaxUint8+1)).PushByte(1)
+	assert.ErrorIs(t, p.Error(), ErrLengthOverflow, "overflow error.")
+	assert.Equal(t, b.Len(), 0, "overflow error.")
+
+	p = NewPacker(binary.BigEndian, b)
+	p.PushStringWithUint16Prefix(string(make([]byte, math.MaxUint16+1)))
+	assert.ErrorIs(t, p.Error(), ErrLengthOverflow, "overflow error.")
+	assert.Equal(t, b.Len(), 0, "overflow error.")
+
+	p = NewPacker(binary.BigEndian, b)
+	p.PushStringWithUint16Prefix(string(make([]byte, math.MaxUint16)))
+	assert.Equal(t, p.Error(), nil, "Has error.")
+}
+
+func TestAppendPacker(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, []byte{0xff})
+	p.PushByte(0x01).
+		PushBytes([]byte{0x02, 0x03}).
+		PushUint16(1).
+		PushInt32(-1).
+		PushUint64(1).
+		PushFloat32(math.SmallestNonzeroFloat32).
+		PushString("Hi").
+		PushUvarint(300)
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, p.Offset(), int64(25), "offset error.")
+	assert.Equal(t, p.Bytes(), []byte{
+		0xff,
+		0x01,
+		0x02, 0x03,
+		0, 1,
+		255, 255, 255, 255,
+		0, 0, 0, 0, 0, 0, 0, 1,
+		0, 0, 0, 1,
+		'H', 'i',
+		0xac, 0x02,
+	}, "append error.")
+
+	p.Reset()
+	p.PushUint16(2)
+	assert.Equal(t, p.Offset(), int64(2), "offset error.")
+	assert.Equal(t, p.Bytes(), []byte{0, 2}, "reset error.")
+}
+
+func TestAppendPackerAllocs(t *testing.T) {
+	p := NewAppendPacker(binary.LittleEndian, make([]byte, 0, 1024))
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Reset()
+		p.PushByte(1).PushUint16(2).PushUint32(3).PushUint64(4).PushFloat64(5).PushString("Hi").PushUvarint(300)
+	})
+	assert.Equal(t, allocs, float64(0), "allocation error.")
+}
+
+func BenchmarkAppendPackerPushUint16(b *testing.B) {
+	p := NewAppendPacker(binary.BigEndian, make([]byte, 0, 1024))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if i%512 == 0 {
+			p.Reset()
+		}
+		p.PushUint16(uint16(i))
+	}
+}
+
+func BenchmarkAppendPackerPushUint32(b *testing.B) {
+	p := NewAppendPacker(binary.BigEndian, make([]byte, 0, 1024))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if i%256 == 0 {
+			p.Reset()
+		}
+		p.PushUint32(uint32(i))
+	}
+}
+
+func BenchmarkAppendPackerPushUint64(b *testing.B) {
+	p := NewAppendPacker(binary.BigEndian, make([]byte, 0, 1024))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if i%128 == 0 {
+			p.Reset()
+		}
+		p.PushUint64(uint64(i))
+	}
+}
+
+func BenchmarkPackerPushUint32(b *testing.B) {
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, buf)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if i%256 == 0 {
+			buf.Reset()
+		}
+		p.PushUint32(uint32(i))
+	}
+}
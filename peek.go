@@ -0,0 +1,297 @@
+package binpacker
+
+import (
+	"errors"
+	"io"
+)
+
+// MaxLookahead is the number of bytes an Unpacker buffers for Peek, and for
+// Mark on a reader that is not an io.Seeker.
+const MaxLookahead = 64 << 10
+
+var (
+	// ErrNotSeekable is returned by Seek when the reader is not an io.Seeker.
+	ErrNotSeekable = errors.New("binpacker: reader is not seekable")
+	// ErrNoMark is returned by Reset when Mark was not called.
+	ErrNoMark = errors.New("binpacker: no mark set")
+	// ErrMarkLost is returned by Reset when more than MaxLookahead bytes were
+	// read from a non-seekable reader since Mark.
+	ErrMarkLost = errors.New("binpacker: mark lost, lookahead buffer exceeded")
+	// ErrInvalidSeek is returned by Seek for an unknown whence or a negative
+	// position.
+	ErrInvalidSeek = errors.New("binpacker: invalid seek")
+)
+
+// mark is the state saved by Mark.
+type mark struct {
+	set    bool
+	offset int64
+	total  uint64
+	depth  int
+	err    error
+	seek   bool   // whether pos is valid
+	pos    int64  // reader position at the mark
+	buf    []byte // bytes consumed since the mark, when not seeking
+	lost   bool   // whether buf overflowed
+}
+
+// PeekUint8 returns the next byte without consuming it.
+func (u *Unpacker) PeekUint8() (uint8, error) {
+	buffer, err := u.peek("PeekUint8", 1)
+	if err != nil {
+		return 0, err
+	}
+	return buffer[0], nil
+}
+
+// PeekUint16 returns the next uint16 without consuming it.
+func (u *Unpacker) PeekUint16() (uint16, error) {
+	buffer, err := u.peek("PeekUint16", 2)
+	if err != nil {
+		return 0, err
+	}
+	return u.endian.Uint16(buffer), nil
+}
+
+// PeekUint32 returns the next uint32 without consuming it.
+func (u *Unpacker) PeekUint32() (uint32, error) {
+	buffer, err := u.peek("PeekUint32", 4)
+	if err != nil {
+		return 0, err
+	}
+	return u.endian.Uint32(buffer), nil
+}
+
+// PeekUint64 returns the next uint64 without consuming it.
+func (u *Unpacker) PeekUint64() (uint64, error) {
+	buffer, err := u.peek("PeekUint64", 8)
+	if err != nil {
+		return 0, err
+	}
+	return u.endian.Uint64(buffer), nil
+}
+
+// peek returns the next n bytes without consuming them. Bytes read from the
+// reader are kept in the lookahead buffer until read consumes them.
+func (u *Unpacker) peek(op string, n uint64) ([]byte, error) {
+	if u.slice {
+		rest := u.data[u.offset:]
+		if n > uint64(len(rest)) {
+			return nil, u.traceError(&Error{Op: op, Offset: u.offset, Want: n, Got: uint64(len(rest)), Err: eofError(len(rest))})
+		}
+		return rest[:n], nil
+	}
+	have := uint64(len(u.pending))
+	if have >= n {
+		return u.pending[:n], nil
+	}
+	if n > MaxLookahead {
+		return nil, u.traceError(&Error{Op: op, Offset: u.offset, Want: n, Err: &LimitError{Limit: "MaxLookahead", Max: MaxLookahead, Requested: n}})
+	}
+	if uint64(cap(u.pending)) < n {
+		if uint64(cap(u.ahead)) < n {
+			u.ahead = make([]byte, 0, n)
+		}
+		u.pending = append(u.ahead[:0], u.pending...)
+	}
+	u.pending = u.pending[:n]
+	got, err := io.ReadFull(u.reader, u.pending[have:])
+	u.pending = u.pending[:have+uint64(got)]
+	if err != nil {
+		if err == io.EOF && have > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, u.traceError(&Error{Op: op, Offset: u.offset, Want: n, Got: uint64(len(u.pending)), Err: err})
+	}
+	return u.pending, nil
+}
+
+// Skip discards the next n bytes without allocating.
+func (u *Unpacker) Skip(n uint64) *Unpacker {
+	return u.errFilter(func() {
+		u.err = u.skip("Skip", n)
+	})
+}
+
+func (u *Unpacker) skip(op string, n uint64) error {
+	offset := u.offset
+	if err := u.checkTotal(n); err != nil {
+		return u.traceError(&Error{Op: op, Offset: offset, Want: n, Err: err})
+	}
+	var got uint64
+	var err error
+	switch {
+	case u.slice:
+		got = minUint64(n, uint64(len(u.data))-uint64(u.offset))
+		if got < n {
+			err = eofError(int(got))
+		}
+		for _, h := range u.hashes {
+			h.Write(u.data[u.offset : u.offset+int64(got)])
+		}
+	case u.recording() || len(u.hashes) > 0:
+		// The skipped bytes are needed to replay the mark or to checksum.
+		var buffer []byte
+		buffer, err = u.readStream(n)
+		got = uint64(len(buffer))
+		for _, h := range u.hashes {
+			h.Write(buffer)
+		}
+	default:
+		got = minUint64(n, uint64(len(u.pending)))
+		u.pending = u.pending[got:]
+		if got < n {
+			u.limited = io.LimitedReader{R: u.reader, N: int64(n - got)}
+			var k int64
+			k, err = io.Discard.(io.ReaderFrom).ReadFrom(&u.limited)
+			u.limited.R = nil
+			got += uint64(k)
+			if err == nil && got < n {
+				err = eofError(int(got))
+			}
+		}
+	}
+	u.offset += int64(got)
+	u.total += got
+	if err != nil {
+		return u.traceError(&Error{Op: op, Offset: offset, Want: n, Got: got, Err: err})
+	}
+	if u.tracer != nil {
+		// The skipped bytes are only at hand for a SliceUnpacker.
+		var raw []byte
+		if u.slice {
+			raw = u.data[offset:u.offset]
+		}
+		u.tracer.OnShift(op, offset, raw, n)
+	}
+	return nil
+}
+
+// Seek sets the offset of the next read, interpreted according to whence like
+// io.Seeker. Offsets are counted from where the Unpacker started. Seek only
+// works on a SliceUnpacker or when the reader is an io.Seeker.
+func (u *Unpacker) Seek(offset int64, whence int) (int64, error) {
+	if u.slice {
+		var pos int64
+		switch whence {
+		case io.SeekStart:
+			pos = offset
+		case io.SeekCurrent:
+			pos = u.offset + offset
+		case io.SeekEnd:
+			pos = int64(len(u.data)) + offset
+		default:
+			return u.offset, u.traceError(&Error{Op: "Seek", Offset: u.offset, Err: ErrInvalidSeek})
+		}
+		if pos < 0 || pos > int64(len(u.data)) {
+			return u.offset, u.traceError(&Error{Op: "Seek", Offset: u.offset, Err: ErrInvalidSeek})
+		}
+		u.offset = pos
+		return pos, nil
+	}
+	seeker, ok := u.reader.(io.Seeker)
+	if !ok {
+		return u.offset, u.traceError(&Error{Op: "Seek", Offset: u.offset, Err: ErrNotSeekable})
+	}
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return u.offset, u.traceError(&Error{Op: "Seek", Offset: u.offset, Err: err})
+	}
+	// base is the reader position at offset 0.
+	base := current - int64(len(u.pending)) - u.offset
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos, err = seeker.Seek(base+offset, io.SeekStart)
+	case io.SeekCurrent:
+		pos, err = seeker.Seek(base+u.offset+offset, io.SeekStart)
+	case io.SeekEnd:
+		pos, err = seeker.Seek(offset, io.SeekEnd)
+	default:
+		err = ErrInvalidSeek
+	}
+	if err != nil {
+		return u.offset, u.traceError(&Error{Op: "Seek", Offset: u.offset, Err: err})
+	}
+	u.pending = u.pending[:0]
+	u.offset = pos - base
+	return u.offset, nil
+}
+
+// Mark remembers the current position, so that Reset can roll back to it.
+// When the reader is not an io.Seeker, at most MaxLookahead bytes may be read
+// after Mark.
+func (u *Unpacker) Mark() {
+	u.mark = mark{
+		set:    true,
+		offset: u.offset,
+		total:  u.total,
+		depth:  u.depth,
+		err:    u.err,
+		buf:    u.mark.buf[:0],
+	}
+	if u.slice {
+		return
+	}
+	if seeker, ok := u.reader.(io.Seeker); ok {
+		// Readers like pipes are io.Seekers that fail to seek; they fall
+		// back to the lookahead buffer.
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			u.mark.seek = true
+			u.mark.pos = pos - int64(len(u.pending))
+		}
+	}
+}
+
+// Reset rolls back to the last Mark, including the error state. The mark is
+// kept, so Reset may be called again.
+func (u *Unpacker) Reset() error {
+	m := &u.mark
+	if !m.set {
+		return ErrNoMark
+	}
+	switch {
+	case u.slice:
+	case m.seek:
+		if _, err := u.reader.(io.Seeker).Seek(m.pos, io.SeekStart); err != nil {
+			return u.traceError(&Error{Op: "Reset", Offset: u.offset, Err: err})
+		}
+		u.pending = u.pending[:0]
+	case m.lost:
+		return u.traceError(&Error{Op: "Reset", Offset: u.offset, Err: ErrMarkLost})
+	default:
+		replay := make([]byte, 0, len(m.buf)+len(u.pending))
+		replay = append(append(replay, m.buf...), u.pending...)
+		u.pending, u.ahead = replay, replay
+		m.buf = m.buf[:0]
+	}
+	u.offset, u.total, u.depth, u.err = m.offset, m.total, m.depth, m.err
+	return nil
+}
+
+// recording reports whether consumed bytes must be kept for Reset.
+func (u *Unpacker) recording() bool {
+	return u.mark.set && !u.slice && !u.mark.seek && !u.mark.lost
+}
+
+// record keeps b for Reset if a mark needs it.
+func (u *Unpacker) record(b []byte) {
+	if !u.recording() {
+		return
+	}
+	if len(u.mark.buf)+len(b) > MaxLookahead {
+		u.mark.lost = true
+		u.mark.buf = u.mark.buf[:0]
+		return
+	}
+	u.mark.buf = append(u.mark.buf, b...)
+}
+
+// eofError returns io.EOF if nothing was read and io.ErrUnexpectedEOF
+// otherwise.
+func eofError(got int) error {
+	if got == 0 {
+		return io.EOF
+	}
+	return io.ErrUnexpectedEOF
+}
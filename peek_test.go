@@ -0,0 +1,150 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeek(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A}
+	for name, u := range map[string]*Unpacker{
+		"reader": NewUnpacker(binary.BigEndian, &testReader{data: append([]byte(nil), data...), stride: 1}),
+		"slice":  NewSliceUnpacker(binary.BigEndian, data).Unpacker,
+	} {
+		b, err := u.PeekUint8()
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, b, uint8(0x01), name+": uint8 error.")
+		i16, err := u.PeekUint16()
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, i16, uint16(0x0102), name+": uint16 error.")
+		i32, err := u.PeekUint32()
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, i32, uint32(0x01020304), name+": uint32 error.")
+		assert.Equal(t, u.Offset(), int64(0), name+": offset error.")
+
+		i16, err = u.ShiftUint16()
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, i16, uint16(0x0102), name+": uint16 error.")
+		i64, err := u.PeekUint64()
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, i64, uint64(0x030405060708090A), name+": uint64 error.")
+		bs, err := u.ShiftBytes(8)
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, bs, data[2:], name+": bytes error.")
+
+		_, err = u.PeekUint8()
+		assert.ErrorIs(t, err, io.EOF, name+": EOF error.")
+	}
+}
+
+func TestPeekTooLarge(t *testing.T) {
+	u := NewUnpacker(binary.BigEndian, bytes.NewBuffer(make([]byte, MaxLookahead+1)))
+	_, err := u.peek("PeekBytes", MaxLookahead+1)
+	assert.ErrorIs(t, err, ErrLimitExceeded, "limit error.")
+}
+
+func TestSkip(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05}
+	for name, u := range map[string]*Unpacker{
+		"reader": NewUnpacker(binary.BigEndian, &testReader{data: append([]byte(nil), data...), stride: 2}),
+		"slice":  NewSliceUnpacker(binary.BigEndian, data).Unpacker,
+	} {
+		var b uint8
+		u.PeekUint8()
+		u.Skip(3).FetchUint8(&b)
+		assert.Equal(t, u.Error(), nil, name+": Has error.")
+		assert.Equal(t, b, uint8(0x04), name+": uint8 error.")
+		u.Skip(2)
+		assert.ErrorIs(t, u.Error(), io.ErrUnexpectedEOF, name+": EOF error.")
+		assert.Equal(t, u.Offset(), int64(5), name+": offset error.")
+	}
+}
+
+func TestSkipAllocs(t *testing.T) {
+	r := bytes.NewReader(make([]byte, 1<<20))
+	u := NewUnpacker(binary.BigEndian, r)
+	u.Skip(1)
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Seek(0, io.SeekStart)
+		u.Skip(1 << 20)
+		u.err = nil
+	})
+	assert.Equal(t, allocs, float64(0), "Skip should not allocate.")
+}
+
+func TestSeek(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05}
+	r := bytes.NewReader(data)
+	r.Seek(1, io.SeekStart)
+	for name, u := range map[string]*Unpacker{
+		"reader": NewUnpacker(binary.BigEndian, r),
+		"slice":  NewSliceUnpacker(binary.BigEndian, data[1:]).Unpacker,
+	} {
+		u.PeekUint16()
+		pos, err := u.Seek(2, io.SeekStart)
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, pos, int64(2), name+": offset error.")
+		b, _ := u.ShiftUint8()
+		assert.Equal(t, b, uint8(0x04), name+": uint8 error.")
+		pos, err = u.Seek(-2, io.SeekCurrent)
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, pos, int64(1), name+": offset error.")
+		b, _ = u.ShiftUint8()
+		assert.Equal(t, b, uint8(0x03), name+": uint8 error.")
+		pos, err = u.Seek(-1, io.SeekEnd)
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, pos, int64(3), name+": offset error.")
+		b, _ = u.ShiftUint8()
+		assert.Equal(t, b, uint8(0x05), name+": uint8 error.")
+	}
+
+	u := NewUnpacker(binary.BigEndian, bytes.NewBuffer(data))
+	_, err := u.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrNotSeekable, "seek error.")
+	_, err = NewSliceUnpacker(binary.BigEndian, data).Seek(6, io.SeekStart)
+	assert.ErrorIs(t, err, ErrInvalidSeek, "seek error.")
+}
+
+func TestMarkReset(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A}
+	for name, u := range map[string]*Unpacker{
+		"reader":   NewUnpacker(binary.BigEndian, &testReader{data: append([]byte(nil), data...), stride: 3}),
+		"seeker":   NewUnpacker(binary.BigEndian, bytes.NewReader(data)),
+		"slice":    NewSliceUnpacker(binary.BigEndian, data).Unpacker,
+		"buffered": NewUnpacker(binary.BigEndian, bytes.NewBuffer(data)),
+	} {
+		assert.ErrorIs(t, u.Reset(), ErrNoMark, name+": mark error.")
+		u.ShiftUint8()
+		u.PeekUint16()
+		u.Mark()
+		var i16 uint16
+		var bs []byte
+		u.FetchUint16(&i16).FetchBytes(9, &bs)
+		assert.ErrorIs(t, u.Error(), io.ErrUnexpectedEOF, name+": EOF error.")
+
+		assert.Equal(t, u.Reset(), nil, name+": Has error.")
+		assert.Equal(t, u.Error(), nil, name+": Has error.")
+		assert.Equal(t, u.Offset(), int64(1), name+": offset error.")
+		u.FetchUint16(&i16).Skip(1).FetchBytes(5, &bs)
+		assert.Equal(t, u.Error(), nil, name+": Has error.")
+		assert.Equal(t, i16, uint16(0x0203), name+": uint16 error.")
+		assert.Equal(t, bs, data[4:9], name+": bytes error.")
+
+		assert.Equal(t, u.Reset(), nil, name+": Has error.")
+		bs, err := u.ShiftBytes(9)
+		assert.Equal(t, err, nil, name+": Has error.")
+		assert.Equal(t, bs, data[1:], name+": bytes error.")
+	}
+}
+
+func TestMarkLost(t *testing.T) {
+	u := NewUnpacker(binary.BigEndian, bytes.NewBuffer(make([]byte, MaxLookahead+1)))
+	u.Mark()
+	u.Skip(MaxLookahead + 1)
+	assert.Equal(t, u.Error(), nil, "Has error.")
+	assert.ErrorIs(t, u.Reset(), ErrMarkLost, "mark error.")
+}
@@ -0,0 +1,395 @@
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/zhuangsirui/binpacker"
+	"github.com/zhuangsirui/binpacker/internal/bintag"
+)
+
+// defaultMaxDepth bounds the nesting of structs, which recursive types could
+// otherwise drive as deep as the input allows, unless the Unpacker sets
+// MaxDepth.
+const defaultMaxDepth = 256
+
+var (
+	errTooDeep    = errors.New("structs nested too deeply")
+	errNoProgress = errors.New("repeat: eos item read no bytes")
+)
+
+// Node is a decoded field and its position in the input, as returned by
+// DecodeNodes.
+type Node struct {
+	Name   string `json:"name"` // field name, name[i] for elements of a repeated field
+	Path   string `json:"path"` // e.g. "header.items[2].name"
+	Type   string `json:"type"` // field type, with "[]" appended for repeated fields
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	// Value is the value Decode returns for scalars, bytes and strings. It
+	// is nil for structs and repeated fields, whose parts are in Children.
+	Value    interface{} `json:"value,omitempty"`
+	Children []*Node     `json:"children,omitempty"`
+}
+
+// Decode reads the fields of s from u and returns them as a tree. Structs
+// become map[string]interface{} and repeated fields []interface{}; unsigned
+// integers are uint64, signed integers int64, floats float64, enums
+// EnumValue, and bools, bytes and strings their Go types. Fields whose If is
+// false are left out.
+func Decode(s *Schema, u *binpacker.Unpacker) (map[string]interface{}, error) {
+	d := &decoder{s: s, u: u}
+	d.env.enums = s.Enums
+	m, _, err := d.fields("", s.Fields, byteOrder(s.Endian, binary.BigEndian))
+	return m, err
+}
+
+// DecodeNodes is like Decode, but returns the fields with their offsets. On
+// error, the nodes decoded so far are returned with it.
+func DecodeNodes(s *Schema, u *binpacker.Unpacker) (*Node, error) {
+	d := &decoder{s: s, u: u, nodes: true}
+	d.env.enums = s.Enums
+	root := d.node("", "", "struct")
+	_, children, err := d.fields("", s.Fields, byteOrder(s.Endian, binary.BigEndian))
+	root.Children = children
+	d.finish(root, nil)
+	return root, err
+}
+
+type decoder struct {
+	s     *Schema
+	u     *binpacker.Unpacker
+	base  int64 // offset of u in the input, for sized structs
+	nodes bool  // whether to build nodes
+	env   env
+}
+
+func (d *decoder) offset() int64 {
+	return d.base + d.u.Offset()
+}
+
+func (d *decoder) node(name, path, typ string) *Node {
+	if !d.nodes {
+		return nil
+	}
+	return &Node{Name: name, Path: path, Type: typ, Offset: d.offset()}
+}
+
+func (d *decoder) finish(n *Node, v interface{}) {
+	if n != nil {
+		n.Length = d.offset() - n.Offset
+		n.Value = v
+	}
+}
+
+func (d *decoder) fields(path string, fields []Field, order binary.ByteOrder) (map[string]interface{}, []*Node, error) {
+	if err := d.checkDepth(len(d.env.scope) + 1); err != nil {
+		return nil, nil, &Error{Path: orRoot(path), Offset: d.offset(), Err: err}
+	}
+	m := make(map[string]interface{}, len(fields))
+	var nodes []*Node
+	d.env.scope = append(d.env.scope, m)
+	defer func() { d.env.scope = d.env.scope[:len(d.env.scope)-1] }()
+	for i := range fields {
+		f := &fields[i]
+		p := joinPath(path, f.Name)
+		if f.If != "" {
+			ok, err := d.env.cond(f.If)
+			if err != nil {
+				return m, nodes, wrap(p, d.offset(), err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		v, n, err := d.field(p, f, order)
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+		if err != nil {
+			return m, nodes, err
+		}
+		m[f.Name] = v
+	}
+	return m, nodes, nil
+}
+
+func (d *decoder) field(path string, f *Field, order binary.ByteOrder) (interface{}, *Node, error) {
+	order = byteOrder(f.Endian, order)
+	if !repeated(f) {
+		return d.value(path, f.Name, f, order)
+	}
+	node := d.node(f.Name, path, f.Type+"[]")
+	var n uint64
+	bounded := f.Count != "" || f.CountPrefix != ""
+	if bounded {
+		var err error
+		if n, err = d.count(f.Count, f.CountPrefix, order); err == nil {
+			err = d.checkElements(n)
+		}
+		if err != nil {
+			d.finish(node, nil)
+			return nil, node, wrap(path, d.offset(), err)
+		}
+	}
+	saved := d.env
+	defer func() { d.env.repeat, d.env.index, d.env.item = saved.repeat, saved.index, saved.item }()
+	list := make([]interface{}, 0, minUint64(n, 1024))
+	for i := uint64(0); !bounded || i < n; i++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if !bounded {
+			if err := d.checkElements(i + 1); err != nil {
+				d.finish(node, nil)
+				return list, node, wrap(path, d.offset(), err)
+			}
+		}
+		if f.RepeatEOS {
+			eof, err := d.eof()
+			if err != nil {
+				d.finish(node, nil)
+				return list, node, wrap(itemPath, d.offset(), err)
+			}
+			if eof {
+				break
+			}
+		}
+		d.env.repeat, d.env.index, d.env.item = true, int64(i), nil
+		start := d.offset()
+		v, child, err := d.value(itemPath, fmt.Sprintf("%s[%d]", f.Name, i), f, order)
+		if child != nil {
+			node.Children = append(node.Children, child)
+		}
+		if err != nil {
+			d.finish(node, nil)
+			return list, node, err
+		}
+		list = append(list, v)
+		if f.RepeatEOS && d.offset() == start {
+			// An empty item would be repeated forever.
+			d.finish(node, nil)
+			return list, node, wrap(itemPath, start, errNoProgress)
+		}
+		if f.RepeatUntil != "" {
+			d.env.item = v
+			done, err := d.env.cond(f.RepeatUntil)
+			if err != nil {
+				d.finish(node, nil)
+				return list, node, wrap(itemPath, d.offset(), err)
+			}
+			if done {
+				break
+			}
+		}
+	}
+	d.finish(node, nil)
+	return list, node, nil
+}
+
+func (d *decoder) value(path, name string, f *Field, order binary.ByteOrder) (interface{}, *Node, error) {
+	offset := d.offset()
+	node := d.node(name, path, f.Type)
+	var v interface{}
+	var err error
+	switch f.Type {
+	case "bytes", "string":
+		var b []byte
+		var start int64
+		if b, start, err = d.sized(f, order); err != nil {
+			break
+		}
+		if err = checkContents(f, b, start); err != nil {
+			break
+		}
+		v = b
+		if f.Type == "string" {
+			v = string(b)
+		}
+	case "struct":
+		return d.structValue(path, node, f, f.Fields, order)
+	default:
+		sc, ok := bintag.Scalars[f.Type]
+		if !ok {
+			fields, ok := d.s.Types[f.Type]
+			if !ok {
+				return nil, node, wrap(path, offset, fmt.Errorf("unknown type %q", f.Type))
+			}
+			return d.structValue(path, node, f, fields, order)
+		}
+		var x uint64
+		if x, err = d.uint(sc.Width, order); err == nil {
+			v = scalarValue(f.Type, sc, x)
+			if f.Enum != "" {
+				i, _ := toInt(v)
+				if u, ok := v.(uint64); ok {
+					i = int64(u)
+				}
+				v = EnumValue{Name: d.s.Enums[f.Enum][i], Value: i}
+			}
+		}
+	}
+	d.finish(node, v)
+	if err != nil {
+		return nil, node, wrap(path, offset, err)
+	}
+	return v, node, nil
+}
+
+// structValue decodes a struct, from exactly its length if it has one.
+func (d *decoder) structValue(path string, node *Node, f *Field, fields []Field, order binary.ByteOrder) (interface{}, *Node, error) {
+	if f.Length == "" && !f.LengthEOS && f.Prefix == "" {
+		m, children, err := d.fields(path, fields, order)
+		if node != nil {
+			node.Children = children
+		}
+		d.finish(node, nil)
+		return m, node, err
+	}
+	offset := d.offset()
+	b, start, err := d.sized(f, order)
+	if err != nil {
+		d.finish(node, nil)
+		return nil, node, wrap(path, offset, err)
+	}
+	end := d.offset()
+	u, base := d.u, d.base
+	d.u, d.base = d.u.Sub(b).Unpacker, start
+	m, children, err := d.fields(path, fields, order)
+	d.u, d.base = u, base
+	if node != nil {
+		node.Children = children
+		node.Length = end - node.Offset
+	}
+	return m, node, err
+}
+
+// sized reads a field with a length, returning its data and the offset at
+// which the data starts.
+func (d *decoder) sized(f *Field, order binary.ByteOrder) ([]byte, int64, error) {
+	if f.LengthEOS {
+		start := d.offset()
+		b, err := d.rest()
+		return b, start, err
+	}
+	n, err := d.count(f.Length, f.Prefix, order)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := d.offset()
+	b, err := d.u.ShiftBytes(n)
+	return b, start, err
+}
+
+// rest reads until the end of the input.
+func (d *decoder) rest() ([]byte, error) {
+	return d.u.ShiftRest()
+}
+
+// eof reports whether the input is at its end.
+func (d *decoder) eof() (bool, error) {
+	_, err := d.u.PeekUint8()
+	if isEOF(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+func isEOF(err error) bool {
+	return errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// count evaluates e if set, and reads a prefix of type prefix otherwise.
+func (d *decoder) count(e Expr, prefix string, order binary.ByteOrder) (uint64, error) {
+	if e != "" {
+		return d.env.count(e)
+	}
+	width, ok := bintag.PrefixWidths[prefix]
+	if !ok {
+		return 0, fmt.Errorf("invalid prefix %q", prefix)
+	}
+	return d.uint(width, order)
+}
+
+func (d *decoder) uint(width int, order binary.ByteOrder) (x uint64, err error) {
+	if err := d.u.Error(); err != nil {
+		return 0, err
+	}
+	d.u.WithEndian(order, func(u *binpacker.Unpacker) {
+		x, err = u.ShiftUintN(width)
+	})
+	return x, err
+}
+
+// checkElements fails as the Unpacker does if n elements are more than its
+// MaxElements allows.
+func (d *decoder) checkElements(n uint64) error {
+	if max := d.u.Options().MaxElements; max > 0 && n > max {
+		return &binpacker.LimitError{Limit: "MaxElements", Max: max, Requested: n}
+	}
+	return nil
+}
+
+// checkDepth fails as the Unpacker does if structs nested depth deep are more
+// than its MaxDepth allows, and with errTooDeep beyond defaultMaxDepth if it
+// sets none.
+func (d *decoder) checkDepth(depth int) error {
+	max := d.u.Options().MaxDepth
+	if max <= 0 {
+		if depth > defaultMaxDepth {
+			return errTooDeep
+		}
+		return nil
+	}
+	if depth > max {
+		return &binpacker.LimitError{Limit: "MaxDepth", Max: uint64(max), Requested: uint64(depth)}
+	}
+	return nil
+}
+
+// scalarValue converts the raw bits x of a scalar to its tree value.
+func scalarValue(typ string, sc bintag.Scalar, x uint64) interface{} {
+	switch {
+	case typ == "bool":
+		return x != 0
+	case sc.Float && sc.Width == 4:
+		return float64(math.Float32frombits(uint32(x)))
+	case sc.Float:
+		return math.Float64frombits(x)
+	case sc.Signed:
+		shift := 64 - 8*uint(sc.Width)
+		return int64(x<<shift) >> shift
+	}
+	return x
+}
+
+func repeated(f *Field) bool {
+	return f.Count != "" || f.CountPrefix != "" || f.RepeatEOS || f.RepeatUntil != ""
+}
+
+func byteOrder(e string, def binary.ByteOrder) binary.ByteOrder {
+	switch e {
+	case "be":
+		return binary.BigEndian
+	case "le":
+		return binary.LittleEndian
+	}
+	return def
+}
+
+// wrap records path and offset in err unless an inner field already did.
+func wrap(path string, offset int64, err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{Path: orRoot(path), Offset: offset, Err: err}
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,211 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhuangsirui/binpacker"
+)
+
+var packetData = []byte{
+	'P', 'K', 'T', '1',
+	0x02, 0x00,
+	2,
+	0xFF, 0xFE, 3, 'o', 'n', 'e', 2, 7, 8,
+	0x00, 0x02, 0, 0,
+	1, 0x3F, 0xC0, 0, 0,
+}
+
+var packetTree = map[string]interface{}{
+	"magic":   []byte("PKT1"),
+	"version": uint64(2),
+	"count":   uint64(2),
+	"records": []interface{}{
+		map[string]interface{}{"id": int64(-2), "name": "one", "tags": []interface{}{uint64(7), uint64(8)}},
+		map[string]interface{}{"id": int64(2), "name": "", "tags": []interface{}{}},
+	},
+	"trailer": map[string]interface{}{"ok": true, "ratio": 1.5},
+}
+
+func TestDecode(t *testing.T) {
+	s, err := Parse([]byte(packetSchema))
+	assert.NoError(t, err)
+	u := binpacker.NewUnpacker(binary.BigEndian, bytes.NewReader(packetData))
+	tree, err := Decode(s, u)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, packetTree, "tree error.")
+	assert.Equal(t, u.Offset(), int64(len(packetData)), "offset error.")
+}
+
+func TestDecodeErrors(t *testing.T) {
+	s, err := Parse([]byte(packetSchema))
+	assert.NoError(t, err)
+	u := binpacker.NewSliceUnpacker(binary.BigEndian, packetData[:12])
+	_, err = Decode(s, u.Unpacker)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "EOF error.")
+	var e *Error
+	assert.True(t, errors.As(err, &e), "error type error.")
+	assert.Equal(t, e.Path, "records[0].name", "path error.")
+	assert.Equal(t, e.Offset, int64(9), "offset error.")
+
+	s = &Schema{Fields: []Field{{Name: "a", Type: "bytes", Length: "missing"}}}
+	_, err = Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, nil).Unpacker)
+	assert.EqualError(t, err, `schema: a at offset 0: unknown field "missing"`, "reference error.")
+}
+
+func TestDecodeRecursive(t *testing.T) {
+	s := &Schema{
+		Fields: []Field{{Name: "root", Type: "node"}},
+		Types: map[string][]Field{
+			"node": {
+				{Name: "value", Type: "uint8"},
+				{Name: "children", Type: "node", CountPrefix: "uint8"},
+			},
+		},
+	}
+	tree, err := Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, []byte{1, 1, 2, 0}).Unpacker)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, map[string]interface{}{
+		"root": map[string]interface{}{
+			"value": uint64(1),
+			"children": []interface{}{
+				map[string]interface{}{"value": uint64(2), "children": []interface{}{}},
+			},
+		},
+	}, "tree error.")
+
+	deep := bytes.Repeat([]byte{0, 1}, defaultMaxDepth)
+	_, err = Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, deep).Unpacker)
+	assert.ErrorIs(t, err, errTooDeep, "depth error.")
+
+	opts := binpacker.UnpackerOptions{MaxDepth: 3}
+	u := binpacker.NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader([]byte{1, 1, 2, 0}), opts)
+	_, err = Decode(s, u)
+	assert.NoError(t, err)
+	u = binpacker.NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader([]byte{1, 1, 2, 1, 3, 0}), opts)
+	_, err = Decode(s, u)
+	var le *binpacker.LimitError
+	assert.ErrorAs(t, err, &le)
+	assert.Equal(t, le.Limit, "MaxDepth", "limit error.")
+}
+
+var optionsSchema = &Schema{
+	Enums: map[string]map[int64]string{"kind": {1: "text", 2: "blob"}},
+	Fields: []Field{
+		{Name: "kind", Type: "uint8", Enum: "kind"},
+		{Name: "text", Type: "string", Prefix: "uint8", If: "kind == kind::text"},
+		{Name: "words", Type: "uint8", RepeatUntil: "_ == 0"},
+		{Name: "box", Type: "struct", Length: "3", Fields: []Field{
+			{Name: "a", Type: "uint8"},
+			{Name: "b", Type: "uint8", RepeatEOS: true},
+		}},
+		{Name: "rest", Type: "bytes", LengthEOS: true},
+	},
+}
+
+var optionsData = []byte{1, 2, 'h', 'i', 5, 0, 7, 8, 9, 0xAA}
+
+var optionsTree = map[string]interface{}{
+	"kind":  EnumValue{Name: "text", Value: 1},
+	"text":  "hi",
+	"words": []interface{}{uint64(5), uint64(0)},
+	"box":   map[string]interface{}{"a": uint64(7), "b": []interface{}{uint64(8), uint64(9)}},
+	"rest":  []byte{0xAA},
+}
+
+func TestDecodeOptions(t *testing.T) {
+	assert.NoError(t, optionsSchema.Validate())
+	tree, err := Decode(optionsSchema, binpacker.NewSliceUnpacker(binary.BigEndian, optionsData).Unpacker)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, optionsTree, "tree error.")
+
+	tree, err = Decode(optionsSchema, binpacker.NewSliceUnpacker(binary.BigEndian, []byte{2, 0, 1, 2, 3}).Unpacker)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, map[string]interface{}{
+		"kind":  EnumValue{Name: "blob", Value: 2},
+		"words": []interface{}{uint64(0)},
+		"box":   map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}},
+		"rest":  []byte{},
+	}, "if error.")
+}
+
+func TestDecodeNodes(t *testing.T) {
+	u := binpacker.NewUnpacker(binary.BigEndian, bytes.NewReader(optionsData))
+	root, err := DecodeNodes(optionsSchema, u)
+	assert.NoError(t, err)
+	assert.Equal(t, root.Length, int64(len(optionsData)), "root length error.")
+	assert.Equal(t, len(root.Children), 5, "children error.")
+	box := root.Children[3]
+	assert.Equal(t, box.Path, "box", "path error.")
+	assert.Equal(t, box.Offset, int64(6), "offset error.")
+	assert.Equal(t, box.Length, int64(3), "length error.")
+	b := box.Children[1]
+	assert.Equal(t, b.Type, "uint8[]", "type error.")
+	assert.Equal(t, b.Children[1].Path, "box.b[1]", "item path error.")
+	assert.Equal(t, b.Children[1].Offset, int64(8), "item offset error.")
+	assert.Equal(t, b.Children[1].Value, uint64(9), "item value error.")
+
+	root, err = DecodeNodes(optionsSchema, binpacker.NewSliceUnpacker(binary.BigEndian, optionsData[:7]).Unpacker)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "EOF error.")
+	assert.Equal(t, len(root.Children), 4, "partial children error.")
+	assert.Equal(t, root.Children[3].Path, "box", "failed child error.")
+}
+
+type shiftCounter struct {
+	shifts []string
+}
+
+func (c *shiftCounter) OnPush(op string, offset int64, raw []byte, value interface{}) {}
+func (c *shiftCounter) OnShift(op string, offset int64, raw []byte, value interface{}) {
+	c.shifts = append(c.shifts, op)
+}
+func (c *shiftCounter) OnError(op string, offset int64, err error) {}
+
+func TestDecodeLimits(t *testing.T) {
+	// An item that reads nothing must not repeat until the end forever.
+	s := &Schema{Fields: []Field{{Name: "a", Type: "struct", RepeatEOS: true}}}
+	_, err := Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, []byte{1}).Unpacker)
+	assert.ErrorIs(t, err, errNoProgress, "progress error.")
+
+	opts := binpacker.UnpackerOptions{MaxElements: 2}
+	for _, s := range []*Schema{
+		{Fields: []Field{{Name: "a", Type: "uint8", CountPrefix: "uint8"}}},
+		{Fields: []Field{{Name: "a", Type: "uint8", RepeatEOS: true}}},
+		{Fields: []Field{{Name: "a", Type: "uint8", RepeatUntil: "_ == 9"}}},
+		{Fields: []Field{{Name: "a", Type: "struct", Length: "4", Fields: []Field{
+			{Name: "b", Type: "uint8", RepeatEOS: true},
+		}}}},
+	} {
+		u := binpacker.NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader([]byte{3, 1, 2, 3}), opts)
+		_, err := Decode(s, u)
+		var le *binpacker.LimitError
+		assert.True(t, errors.As(err, &le), "limit error.")
+		assert.Equal(t, le.Limit, "MaxElements", "limit error.")
+	}
+
+	// The rest of the input is read at once, within the limits.
+	s = &Schema{Fields: []Field{{Name: "a", Type: "uint8"}, {Name: "rest", Type: "bytes", LengthEOS: true}}}
+	tr := &shiftCounter{}
+	u := binpacker.NewUnpacker(binary.BigEndian, bytes.NewReader([]byte{1, 2, 3})).WithTracer(tr)
+	tree, err := Decode(s, u)
+	assert.NoError(t, err)
+	assert.Equal(t, tree["rest"], []byte{2, 3}, "rest error.")
+	assert.Equal(t, tr.shifts, []string{"ShiftUintN", "ShiftRest"}, "shift error.")
+	opts = binpacker.UnpackerOptions{MaxBytesPerField: 2}
+	u = binpacker.NewUnpackerWithLimits(binary.BigEndian, bytes.NewReader([]byte{1, 2, 3, 4}), opts)
+	_, err = Decode(s, u)
+	assert.ErrorIs(t, err, binpacker.ErrLimitExceeded, "rest limit error.")
+
+	// A sized struct is decoded with the tracer of the Unpacker.
+	s = &Schema{Fields: []Field{{Name: "a", Type: "struct", Length: "2", Fields: []Field{
+		{Name: "b", Type: "uint16"},
+	}}}}
+	tr = &shiftCounter{}
+	_, err = Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, []byte{1, 2}).WithTracer(tr))
+	assert.NoError(t, err)
+	assert.Equal(t, tr.shifts, []string{"ShiftBytes", "ShiftUintN"}, "sub tracer error.")
+}
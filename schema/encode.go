@@ -0,0 +1,261 @@
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/zhuangsirui/binpacker"
+	"github.com/zhuangsirui/binpacker/internal/bintag"
+)
+
+// Encode writes the tree v, as returned by Decode, through p.
+//
+// Integers may be given as any Go integer type, float64 or json.Number, enums
+// also by name, and bytes as []byte or string, so that trees decoded from
+// JSON can be encoded too. Prefixes are computed from the data; Length and
+// Count expressions must agree with it.
+func Encode(s *Schema, p *binpacker.Packer, v map[string]interface{}) error {
+	e := &encoder{s: s, p: p}
+	e.env.enums = s.Enums
+	return e.fields("", s.Fields, v, byteOrder(s.Endian, binary.BigEndian))
+}
+
+type encoder struct {
+	s   *Schema
+	p   *binpacker.Packer
+	env env
+}
+
+func (e *encoder) fields(path string, fields []Field, m map[string]interface{}, order binary.ByteOrder) error {
+	if len(e.env.scope) >= defaultMaxDepth {
+		return &Error{Path: orRoot(path), Offset: e.p.Offset(), Err: errTooDeep}
+	}
+	// Expressions see the fields encoded so far, as Decode would return
+	// them.
+	seen := make(map[string]interface{}, len(fields))
+	e.env.scope = append(e.env.scope, seen)
+	defer func() { e.env.scope = e.env.scope[:len(e.env.scope)-1] }()
+	for i := range fields {
+		f := &fields[i]
+		p := joinPath(path, f.Name)
+		if f.If != "" {
+			ok, err := e.env.cond(f.If)
+			if err != nil {
+				return wrap(p, e.p.Offset(), err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		v, ok := m[f.Name]
+		if !ok {
+			return &Error{Path: p, Offset: e.p.Offset(), Err: fmt.Errorf("missing field")}
+		}
+		if err := e.field(p, f, v, order); err != nil {
+			return err
+		}
+		if f.Enum != "" && !repeated(f) {
+			v, _ = e.enumValue(f.Enum, v)
+			i, _ := toInt(v)
+			v = EnumValue{Name: e.s.Enums[f.Enum][i], Value: i}
+		}
+		seen[f.Name] = v
+	}
+	return nil
+}
+
+func (e *encoder) field(path string, f *Field, v interface{}, order binary.ByteOrder) error {
+	order = byteOrder(f.Endian, order)
+	if !repeated(f) {
+		return e.value(path, f, v, order)
+	}
+	offset := e.p.Offset()
+	list, ok := v.([]interface{})
+	if !ok {
+		return wrap(path, offset, fmt.Errorf("repeated field is %T, not []interface{}", v))
+	}
+	if f.Count != "" || f.CountPrefix != "" {
+		if err := e.count(f.Count, f.CountPrefix, uint64(len(list)), order); err != nil {
+			return wrap(path, offset, err)
+		}
+	}
+	saved := e.env
+	defer func() { e.env.repeat, e.env.index, e.env.item = saved.repeat, saved.index, saved.item }()
+	for i, item := range list {
+		e.env.repeat, e.env.index, e.env.item = true, int64(i), nil
+		if err := e.value(fmt.Sprintf("%s[%d]", path, i), f, item, order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) value(path string, f *Field, v interface{}, order binary.ByteOrder) error {
+	offset := e.p.Offset()
+	var err error
+	switch f.Type {
+	case "bytes", "string":
+		var b []byte
+		switch x := v.(type) {
+		case []byte:
+			b = x
+		case string:
+			b = []byte(x)
+		default:
+			err = fmt.Errorf("%s field is %T", f.Type, v)
+		}
+		if err == nil {
+			// The data follows the prefix, if there is one.
+			err = checkContents(f, b, offset+int64(bintag.PrefixWidths[f.Prefix]))
+		}
+		if err == nil {
+			err = e.sized(f, b, order)
+		}
+	case "struct":
+		err = e.structValue(path, f, f.Fields, v, order)
+	default:
+		sc, ok := bintag.Scalars[f.Type]
+		if !ok {
+			fields, ok := e.s.Types[f.Type]
+			if !ok {
+				return wrap(path, offset, fmt.Errorf("unknown type %q", f.Type))
+			}
+			err = e.structValue(path, f, fields, v, order)
+			break
+		}
+		if f.Enum != "" {
+			if v, err = e.enumValue(f.Enum, v); err != nil {
+				break
+			}
+		}
+		var x uint64
+		if x, err = scalarBits(f.Type, sc, v); err == nil {
+			err = e.uint(sc.Width, x, order)
+		}
+	}
+	if err != nil {
+		return wrap(path, offset, err)
+	}
+	return nil
+}
+
+// structValue encodes a struct, with its length if it has one.
+func (e *encoder) structValue(path string, f *Field, fields []Field, v interface{}, order binary.ByteOrder) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("struct field is %T, not map[string]interface{}", v)
+	}
+	if f.Length == "" && !f.LengthEOS && f.Prefix == "" {
+		return e.fields(path, fields, m, order)
+	}
+	p := e.p
+	e.p = binpacker.NewAppendPacker(order, nil)
+	err := e.fields(path, fields, m, order)
+	b := e.p.Bytes()
+	e.p = p
+	if err != nil {
+		return err
+	}
+	return e.sized(f, b, order)
+}
+
+// sized writes the data b of a field with a length.
+func (e *encoder) sized(f *Field, b []byte, order binary.ByteOrder) error {
+	if !f.LengthEOS {
+		if err := e.count(f.Length, f.Prefix, uint64(len(b)), order); err != nil {
+			return err
+		}
+	}
+	return e.p.PushBytes(b).Error()
+}
+
+// enumValue converts an enum given by name to its value.
+func (e *encoder) enumValue(enum string, v interface{}) (interface{}, error) {
+	name, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	for value, n := range e.s.Enums[enum] {
+		if n == name {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("enum %s has no value %q", enum, name)
+}
+
+// count checks n against the expression ex if set, and writes it as a prefix
+// of type prefix otherwise.
+func (e *encoder) count(ex Expr, prefix string, n uint64, order binary.ByteOrder) error {
+	if ex != "" {
+		want, err := e.env.count(ex)
+		if err != nil {
+			return err
+		}
+		if want != n {
+			return fmt.Errorf("%s is %d, but the data has %d", ex, want, n)
+		}
+		return nil
+	}
+	width, ok := bintag.PrefixWidths[prefix]
+	if !ok {
+		return fmt.Errorf("invalid prefix %q", prefix)
+	}
+	if width < 8 && n>>(8*uint(width)) != 0 {
+		return binpacker.ErrLengthOverflow
+	}
+	return e.uint(width, n, order)
+}
+
+func (e *encoder) uint(width int, x uint64, order binary.ByteOrder) error {
+	e.p.WithEndian(order, func(p *binpacker.Packer) {
+		p.PushUintN(x, width)
+	})
+	return e.p.Error()
+}
+
+// scalarBits converts the tree value v of a scalar to its raw bits.
+func scalarBits(typ string, sc bintag.Scalar, v interface{}) (uint64, error) {
+	bits := 8 * uint(sc.Width)
+	switch {
+	case typ == "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return 0, fmt.Errorf("bool field is %T", v)
+		}
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+	case sc.Float:
+		f, err := toFloat(v)
+		if err != nil {
+			return 0, err
+		}
+		if sc.Width == 4 {
+			return uint64(math.Float32bits(float32(f))), nil
+		}
+		return math.Float64bits(f), nil
+	case sc.Signed:
+		i, err := toInt(v)
+		if err != nil {
+			return 0, err
+		}
+		if bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+			return 0, fmt.Errorf("value %d overflows %s", i, typ)
+		}
+		if bits < 64 {
+			// Two's complement in the width of the field.
+			return uint64(i) & (1<<bits - 1), nil
+		}
+		return uint64(i), nil
+	}
+	x, err := toUint(v)
+	if err != nil {
+		return 0, err
+	}
+	if bits < 64 && x>>bits != 0 {
+		return 0, fmt.Errorf("value %d overflows %s", x, typ)
+	}
+	return x, nil
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhuangsirui/binpacker"
+)
+
+func TestEncode(t *testing.T) {
+	s, err := Parse([]byte(packetSchema))
+	assert.NoError(t, err)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, Encode(s, binpacker.NewPacker(binary.BigEndian, buf), packetTree))
+	assert.Equal(t, buf.Bytes(), packetData, "encode error.")
+}
+
+func TestEncodeJSONTree(t *testing.T) {
+	s, err := Parse([]byte(packetSchema))
+	assert.NoError(t, err)
+	data, err := json.Marshal(packetTree)
+	assert.NoError(t, err)
+	var tree map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &tree))
+	tree["magic"] = "PKT1"
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, Encode(s, binpacker.NewPacker(binary.BigEndian, buf), tree))
+	assert.Equal(t, buf.Bytes(), packetData, "encode error.")
+}
+
+func TestEncodeSigned(t *testing.T) {
+	s := &Schema{Endian: "le", Fields: []Field{
+		{Name: "a", Type: "int8"},
+		{Name: "b", Type: "int16"},
+		{Name: "c", Type: "int64"},
+	}}
+	buf := new(bytes.Buffer)
+	tree := map[string]interface{}{"a": -1, "b": -2, "c": -3}
+	assert.NoError(t, Encode(s, binpacker.NewPacker(binary.BigEndian, buf), tree))
+	want := []byte{0xFF, 0xFE, 0xFF, 0xFD, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	assert.Equal(t, buf.Bytes(), want, "encode error.")
+}
+
+func TestEncodeErrors(t *testing.T) {
+	s, err := Parse([]byte(packetSchema))
+	assert.NoError(t, err)
+	for _, change := range []func(map[string]interface{}){
+		func(m map[string]interface{}) { delete(m, "version") },
+		func(m map[string]interface{}) { m["version"] = 65536 },
+		func(m map[string]interface{}) { m["version"] = -1 },
+		func(m map[string]interface{}) { m["version"] = "1" },
+		func(m map[string]interface{}) { m["count"] = 3 },
+		func(m map[string]interface{}) { m["magic"] = []byte("PKT") },
+		func(m map[string]interface{}) { m["records"] = []interface{}{1, 2} },
+		func(m map[string]interface{}) { m["trailer"] = map[string]interface{}{"ok": 1, "ratio": 1} },
+	} {
+		tree := make(map[string]interface{})
+		for k, v := range packetTree {
+			tree[k] = v
+		}
+		change(tree)
+		assert.Error(t, Encode(s, binpacker.NewPacker(binary.BigEndian, new(bytes.Buffer)), tree))
+	}
+}
+
+func TestEncodeOptions(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, Encode(optionsSchema, binpacker.NewPacker(binary.BigEndian, buf), optionsTree))
+	assert.Equal(t, buf.Bytes(), optionsData, "encode error.")
+
+	tree := map[string]interface{}{
+		"kind":  "blob",
+		"text":  "ignored",
+		"words": []interface{}{0},
+		"box":   map[string]interface{}{"a": 1, "b": []interface{}{2, 3}},
+		"rest":  []byte{},
+	}
+	buf.Reset()
+	assert.NoError(t, Encode(optionsSchema, binpacker.NewPacker(binary.BigEndian, buf), tree))
+	assert.Equal(t, buf.Bytes(), []byte{2, 0, 1, 2, 3}, "if error.")
+
+	tree["kind"] = "other"
+	assert.Error(t, Encode(optionsSchema, binpacker.NewPacker(binary.BigEndian, new(bytes.Buffer)), tree))
+	tree["kind"] = 2
+	tree["box"] = map[string]interface{}{"a": 1, "b": []interface{}{2}}
+	assert.Error(t, Encode(optionsSchema, binpacker.NewPacker(binary.BigEndian, new(bytes.Buffer)), tree))
+}
+
+func TestContents(t *testing.T) {
+	s := &Schema{Fields: []Field{{Name: "magic", Type: "bytes", Prefix: "uint8", Contents: []byte("PK")}}}
+	assert.NoError(t, s.Validate())
+	p := binpacker.NewAppendPacker(binary.BigEndian, nil)
+	assert.NoError(t, Encode(s, p, map[string]interface{}{"magic": []byte("PK")}))
+	assert.Equal(t, p.Bytes(), []byte{2, 'P', 'K'}, "encode error.")
+
+	err := Encode(s, binpacker.NewAppendPacker(binary.BigEndian, nil), map[string]interface{}{"magic": []byte("PX")})
+	var ce *ContentsError
+	assert.True(t, errors.As(err, &ce), "error type error.")
+	assert.Equal(t, ce.Offset, int64(2), "offset error.")
+
+	_, err = Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, []byte{1, 'P'}).Unpacker)
+	assert.True(t, errors.As(err, &ce), "error type error.")
+	assert.Equal(t, ce, &ContentsError{Offset: 2, Expected: []byte("PK"), Actual: []byte("P")}, "decode error.")
+
+	s.Fields[0].Type = "string"
+	assert.Error(t, s.Validate())
+}
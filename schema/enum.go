@@ -0,0 +1,29 @@
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// EnumValue is the decoded value of a field with an enum.
+type EnumValue struct {
+	Name  string // empty if the enum has no name for Value
+	Value int64
+}
+
+// String returns the name, or the value if there is no name.
+func (e EnumValue) String() string {
+	if e.Name == "" {
+		return strconv.FormatInt(e.Value, 10)
+	}
+	return e.Name
+}
+
+// MarshalJSON encodes the name as a string, or the value as a number if there
+// is no name. Encode accepts both.
+func (e EnumValue) MarshalJSON() ([]byte, error) {
+	if e.Name == "" {
+		return json.Marshal(e.Value)
+	}
+	return json.Marshal(e.Name)
+}
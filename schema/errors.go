@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrContentsMismatch is matched by errors.Is for every *ContentsError.
+var ErrContentsMismatch = errors.New("contents mismatch")
+
+// Error records the field at which decoding or encoding failed.
+type Error struct {
+	Path   string // field path, e.g. "header.items[2].name"
+	Offset int64  // offset at which the field started
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "binpacker: ")
+	return fmt.Sprintf("schema: %s at offset %d: %s", e.Path, e.Offset, msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ContentsError is returned when a field with Contents holds other bytes.
+type ContentsError struct {
+	Offset   int64 // offset of the first byte that differs
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *ContentsError) Error() string {
+	return fmt.Sprintf("contents mismatch at offset %d: expected % x, actual % x", e.Offset, e.Expected, e.Actual)
+}
+
+// Is reports whether target is ErrContentsMismatch.
+func (e *ContentsError) Is(target error) bool {
+	return target == ErrContentsMismatch
+}
+
+// checkContents returns a *ContentsError if the data b of f, which starts at
+// offset, is not its Contents.
+func checkContents(f *Field, b []byte, offset int64) error {
+	if f.Contents == nil || bytes.Equal(b, f.Contents) {
+		return nil
+	}
+	i := 0
+	for i < len(b) && i < len(f.Contents) && b[i] == f.Contents[i] {
+		i++
+	}
+	return &ContentsError{Offset: offset + int64(i), Expected: f.Contents, Actual: b}
+}
@@ -0,0 +1,542 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// node is a parsed expression.
+type node interface {
+	eval(e *env) (interface{}, error)
+}
+
+// env is what expressions are evaluated against.
+type env struct {
+	scope    scope
+	enums    map[string]map[int64]string
+	item     interface{} // value of _ in RepeatUntil
+	index    int64       // value of _index in repeated fields
+	repeat   bool        // whether item and index are set
+	compiled map[Expr]node
+}
+
+// eval evaluates the expression x.
+func (e *env) eval(x Expr) (interface{}, error) {
+	n, ok := e.compiled[x]
+	if !ok {
+		var err error
+		if n, err = parseExpr(string(x)); err != nil {
+			return nil, err
+		}
+		if e.compiled == nil {
+			e.compiled = make(map[Expr]node)
+		}
+		e.compiled[x] = n
+	}
+	return n.eval(e)
+}
+
+// count evaluates x as a non-negative integer.
+func (e *env) count(x Expr) (uint64, error) {
+	v, err := e.eval(x)
+	if err != nil {
+		return 0, err
+	}
+	return toUint(v)
+}
+
+// cond evaluates x as a condition.
+func (e *env) cond(x Expr) (bool, error) {
+	v, err := e.eval(x)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v)
+}
+
+// scope holds the structs being decoded or encoded, innermost last.
+type scope []map[string]interface{}
+
+func (sc scope) lookup(name string) (interface{}, bool) {
+	for i := len(sc) - 1; i >= 0; i-- {
+		if v, ok := sc[i][name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+type literal struct {
+	v interface{}
+}
+
+func (l literal) eval(*env) (interface{}, error) {
+	return l.v, nil
+}
+
+// name is a field reference or one of _root, _parent, _ and _index.
+type name struct {
+	id string
+}
+
+func (n name) eval(e *env) (interface{}, error) {
+	switch n.id {
+	case "_root":
+		if len(e.scope) > 0 {
+			return e.scope[0], nil
+		}
+	case "_parent":
+		if len(e.scope) > 1 {
+			return e.scope[len(e.scope)-2], nil
+		}
+	case "_":
+		if e.repeat && e.item != nil {
+			return e.item, nil
+		}
+	case "_index":
+		if e.repeat {
+			return e.index, nil
+		}
+	default:
+		if v, ok := e.scope.lookup(n.id); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown field %q", n.id)
+}
+
+// member is x.name: a struct field, or the length, size or to_i of x.
+type member struct {
+	x    node
+	name string
+}
+
+func (m member) eval(e *env) (interface{}, error) {
+	v, err := m.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := v.(map[string]interface{}); ok {
+		if f, ok := s[m.name]; ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("unknown field %q", m.name)
+	}
+	switch m.name {
+	case "length", "size":
+		switch x := v.(type) {
+		case []interface{}:
+			return int64(len(x)), nil
+		case []byte:
+			return int64(len(x)), nil
+		case string:
+			return int64(len(x)), nil
+		}
+	case "to_i":
+		i, err := toInt(v)
+		if err == nil {
+			return i, nil
+		}
+	}
+	return nil, fmt.Errorf("%T has no member %q", v, m.name)
+}
+
+// index is x[i] on a repeated field, bytes or string.
+type index struct {
+	x, i node
+}
+
+func (n index) eval(e *env) (interface{}, error) {
+	v, err := n.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := n.i.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	i, err := toInt(iv)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	switch x := v.(type) {
+	case []interface{}:
+		length = len(x)
+	case []byte:
+		length = len(x)
+	case string:
+		length = len(x)
+	default:
+		return nil, fmt.Errorf("cannot index %T", v)
+	}
+	if i < 0 || i >= int64(length) {
+		return nil, fmt.Errorf("index %d out of range [0:%d]", i, length)
+	}
+	switch x := v.(type) {
+	case []interface{}:
+		return x[i], nil
+	case []byte:
+		return uint64(x[i]), nil
+	}
+	return uint64(v.(string)[i]), nil
+}
+
+// enumConst is enum::name.
+type enumConst struct {
+	enum, name string
+}
+
+func (c enumConst) eval(e *env) (interface{}, error) {
+	values, ok := e.enums[c.enum]
+	if !ok {
+		return nil, fmt.Errorf("unknown enum %q", c.enum)
+	}
+	for v, name := range values {
+		if name == c.name {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("enum %s has no value %q", c.enum, c.name)
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+func (u unary) eval(e *env) (interface{}, error) {
+	v, err := u.x.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch u.op {
+	case "not":
+		b, err := truthy(v)
+		return !b, err
+	case "-":
+		if f, ok := v.(float64); ok {
+			return -f, nil
+		}
+		i, err := toInt(v)
+		return -i, err
+	}
+	i, err := toInt(v)
+	return ^i, err
+}
+
+type binaryOp struct {
+	op   string
+	l, r node
+}
+
+func (b binaryOp) eval(e *env) (interface{}, error) {
+	l, err := b.l.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "and", "or":
+		lb, err := truthy(l)
+		if err != nil || lb == (b.op == "or") {
+			return lb, err
+		}
+		r, err := b.r.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(r)
+	}
+	r, err := b.r.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		c, err := compare(l, r, b.op == "==" || b.op == "!=")
+		if err != nil {
+			return nil, err
+		}
+		switch b.op {
+		case "==":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "+":
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				return ls + rs, nil
+			}
+		}
+	}
+	if isFloat(l) || isFloat(r) {
+		lf, err := toFloat(l)
+		if err != nil {
+			return nil, err
+		}
+		rf, err := toFloat(r)
+		if err != nil {
+			return nil, err
+		}
+		switch b.op {
+		case "+":
+			return lf + rf, nil
+		case "-":
+			return lf - rf, nil
+		case "*":
+			return lf * rf, nil
+		case "/":
+			return lf / rf, nil
+		}
+		return nil, fmt.Errorf("operator %s does not apply to floats", b.op)
+	}
+	li, err := toInt(l)
+	if err != nil {
+		return nil, err
+	}
+	ri, err := toInt(r)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "+":
+		return li + ri, nil
+	case "-":
+		return li - ri, nil
+	case "*":
+		return li * ri, nil
+	case "/", "%":
+		if ri == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		// Division rounds down and the remainder takes the sign of the
+		// divisor, as in Kaitai Struct.
+		q, m := li/ri, li%ri
+		if m != 0 && (m < 0) != (ri < 0) {
+			q, m = q-1, m+ri
+		}
+		if b.op == "/" {
+			return q, nil
+		}
+		return m, nil
+	case "<<":
+		return li << uint64(ri), nil
+	case ">>":
+		return li >> uint64(ri), nil
+	case "&":
+		return li & ri, nil
+	case "|":
+		return li | ri, nil
+	}
+	return li ^ ri, nil
+}
+
+type ternary struct {
+	c, a, b node
+}
+
+func (t ternary) eval(e *env) (interface{}, error) {
+	v, err := t.c.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	c, err := truthy(v)
+	if err != nil {
+		return nil, err
+	}
+	if c {
+		return t.a.eval(e)
+	}
+	return t.b.eval(e)
+}
+
+// compare returns the order of l and r. Numbers compare by value, strings and
+// bytes lexically, and bools only for equality.
+func compare(l, r interface{}, equality bool) (int, error) {
+	if isNumber(l) && isNumber(r) {
+		if isFloat(l) || isFloat(r) {
+			lf, _ := toFloat(l)
+			rf, _ := toFloat(r)
+			switch {
+			case lf < rf:
+				return -1, nil
+			case lf > rf:
+				return 1, nil
+			}
+			return 0, nil
+		}
+		li, lerr := toInt(l)
+		ri, rerr := toInt(r)
+		if lerr == nil && rerr == nil {
+			switch {
+			case li < ri:
+				return -1, nil
+			case li > ri:
+				return 1, nil
+			}
+			return 0, nil
+		}
+		lu, lerr := toUint(l)
+		ru, rerr := toUint(r)
+		if lerr == nil && rerr == nil {
+			switch {
+			case lu < ru:
+				return -1, nil
+			case lu > ru:
+				return 1, nil
+			}
+			return 0, nil
+		}
+		// One side is negative, the other beyond int64.
+		if lerr != nil {
+			return -1, nil
+		}
+		return 1, nil
+	}
+	switch x := l.(type) {
+	case string:
+		if y, ok := r.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case []byte:
+		if y, ok := r.([]byte); ok {
+			return bytes.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := r.(bool); ok && equality {
+			if x == y {
+				return 0, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", l, r)
+}
+
+func truthy(v interface{}) (bool, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	if isNumber(v) {
+		f, err := toFloat(v)
+		return f != 0, err
+	}
+	return false, fmt.Errorf("%T is not a condition", v)
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number, EnumValue:
+		return true
+	}
+	return false
+}
+
+func isFloat(v interface{}) bool {
+	switch x := v.(type) {
+	case float32, float64:
+		return true
+	case json.Number:
+		_, err := x.Int64()
+		return err != nil
+	}
+	return false
+}
+
+// toUint converts a decoded or user supplied value to a uint64.
+func toUint(v interface{}) (uint64, error) {
+	switch x := v.(type) {
+	case uint64:
+		return x, nil
+	case uint:
+		return uint64(x), nil
+	case uint32:
+		return uint64(x), nil
+	case uint16:
+		return uint64(x), nil
+	case uint8:
+		return uint64(x), nil
+	case json.Number:
+		if u, err := strconv.ParseUint(string(x), 10, 64); err == nil {
+			return u, nil
+		}
+	}
+	i, err := toInt(v)
+	if err != nil {
+		if f, ok := v.(float64); ok && f >= 0 && f < math.MaxUint64 && f == math.Trunc(f) {
+			return uint64(f), nil
+		}
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("negative value %d", i)
+	}
+	return uint64(i), nil
+}
+
+// toInt converts a decoded or user supplied value to an int64.
+func toInt(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case int16:
+		return int64(x), nil
+	case int8:
+		return int64(x), nil
+	case uint64:
+		if x > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", x)
+		}
+		return int64(x), nil
+	case uint, uint32, uint16, uint8:
+		u, _ := toUint(x)
+		return int64(u), nil
+	case float64:
+		if x != math.Trunc(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer", x)
+		}
+		return int64(x), nil
+	case json.Number:
+		return x.Int64()
+	case EnumValue:
+		return x.Value, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("value of type %T is not an integer", v)
+}
+
+// toFloat converts a decoded or user supplied value to a float64.
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case json.Number:
+		return x.Float64()
+	case uint64:
+		return float64(x), nil
+	}
+	i, err := toInt(v)
+	return float64(i), err
+}
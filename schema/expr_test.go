@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpr(t *testing.T) {
+	e := &env{
+		scope: scope{
+			{"header": map[string]interface{}{"length": uint64(5)}, "n": int64(3), "name": "abc"},
+			{"n": float64(4), "items": []interface{}{uint64(7), uint64(9)}, "kind": EnumValue{"b", 2}},
+		},
+		enums: map[string]map[int64]string{"kind": {1: "a", 2: "b"}},
+	}
+	for expr, want := range map[Expr]uint64{
+		"7":             7,
+		"0x10":          16,
+		"1_000":         1000,
+		"n":             4,
+		"header.length": 5,
+		"_root.n":       3,
+		"_parent.n":     3,
+		"items[1]":      9,
+		"items.size":    2,
+		"name.length":   3,
+		"name[0]":       'a',
+		"1 + 2 * 3":     7,
+		"(1 + 2) * 3":   9,
+		"-7 / 2 + 5":    1,
+		"-7 % 3":        2,
+		"1 << 4 | 1":    17,
+		"0xff & ~0x0f":  0xf0,
+		"6 ^ 3":         5,
+		"n > 3 ? 1 : 2": 1,
+		"kind.to_i":     2,
+	} {
+		got, err := e.count(expr)
+		assert.NoError(t, err, string(expr))
+		assert.Equal(t, got, want, string(expr))
+	}
+	for _, expr := range []Expr{"-1", "x", "n.m", "header.size", "1x", "", "1 +", "(1", "1 / 0", "name + 1", "kind::c", "colour::a", "items[2]"} {
+		_, err := e.count(expr)
+		assert.Error(t, err, string(expr))
+	}
+}
+
+func TestExprCond(t *testing.T) {
+	e := &env{
+		scope: scope{{"n": uint64(3), "s": "abc", "b": []byte{1, 2}, "kind": EnumValue{"b", 2}}},
+		enums: map[string]map[int64]string{"kind": {1: "a", 2: "b"}},
+	}
+	for expr, want := range map[Expr]bool{
+		"n == 3":                    true,
+		"n != 3":                    false,
+		"n >= 3 and n < 4":          true,
+		"n < 3 or n > 3":            false,
+		"not n == 3":                false,
+		"n == 3.0":                  true,
+		"n / 2.0 > 1.4":             true,
+		"s == \"abc\"":              true,
+		"s + 'd' == 'abcd'":         true,
+		"s < 'abd'":                 true,
+		"kind == kind::b":           true,
+		"kind == other::kind::a":    false,
+		"true and not false":        true,
+		"false and undefined_field": false,
+		"n":                         true,
+		"b.length == 2 ? true : n":  true,
+	} {
+		got, err := e.cond(expr)
+		assert.NoError(t, err, string(expr))
+		assert.Equal(t, got, want, string(expr))
+	}
+	for _, expr := range []Expr{"s", "s < 1", "true < false", "_", "_index", "_parent"} {
+		_, err := e.cond(expr)
+		assert.Error(t, err, string(expr))
+	}
+	e.repeat, e.index, e.item = true, 1, uint64(0)
+	got, err := e.cond("_ == 0 and _index == 1")
+	assert.NoError(t, err)
+	assert.Equal(t, got, true, "repeat error.")
+}
+
+func TestExprJSON(t *testing.T) {
+	var f Field
+	assert.NoError(t, json.Unmarshal([]byte(`{"length": 12, "count": "n"}`), &f))
+	assert.Equal(t, f.Length, Expr("12"), "number error.")
+	assert.Equal(t, f.Count, Expr("n"), "string error.")
+}
+
+func TestToInt(t *testing.T) {
+	for _, v := range []interface{}{int8(-3), int64(-3), float64(-3), json.Number("-3")} {
+		i, err := toInt(v)
+		assert.NoError(t, err)
+		assert.Equal(t, i, int64(-3), "int error.")
+	}
+	for _, v := range []interface{}{1.5, uint64(1 << 63), "3", nil} {
+		_, err := toInt(v)
+		assert.Error(t, err)
+	}
+}
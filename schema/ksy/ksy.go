@@ -0,0 +1,364 @@
+// Package ksy loads Kaitai Struct (.ksy) format descriptions as schemas, so
+// that existing .ksy files can be decoded with schema.Decode and
+// schema.DecodeNodes.
+//
+// The commonly used subset of the language is supported: meta (id, endian and
+// encoding), seq, nested types and enums, the integer, float, str and byte
+// array types, size and size-eos, repeat (expr, eos and until), if, enum and
+// the expressions described at schema.Expr. contents is checked when decoding,
+// failing with a *schema.ContentsError. Anything else, such as instances,
+// switch-on types, bit fields, strz and process, is rejected with an error
+// rather than silently decoded wrongly.
+//
+// Kaitai Struct scopes type and enum names to the type they are defined in;
+// a schema has a single namespace, so nested names are flattened and must be
+// unique.
+package ksy
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zhuangsirui/binpacker/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Parse parses a .ksy file and returns the equivalent schema.
+func Parse(data []byte) (*schema.Schema, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var root spec
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("ksy: %w", err)
+	}
+	if len(root.Meta.Imports) > 0 {
+		return nil, fmt.Errorf("ksy: imports are not supported")
+	}
+	c := &converter{
+		s: &schema.Schema{
+			Types: make(map[string][]schema.Field),
+			Enums: make(map[string]map[int64]string),
+		},
+	}
+	endian, err := checkEndian(root.Meta.Endian, "")
+	if err != nil {
+		return nil, fmt.Errorf("ksy: %w", err)
+	}
+	c.s.Endian = endian
+	if c.s.Fields, err = c.spec(orID(root.Meta.ID), &root, endian, "UTF-8"); err != nil {
+		return nil, err
+	}
+	if err := c.s.Validate(); err != nil {
+		return nil, fmt.Errorf("ksy: %w", err)
+	}
+	return c.s, nil
+}
+
+// spec is a type: the file itself or an entry of types.
+type spec struct {
+	Meta      meta                       `yaml:"meta"`
+	Doc       string                     `yaml:"doc"`
+	DocRef    yaml.Node                  `yaml:"doc-ref"`
+	Params    yaml.Node                  `yaml:"params"`
+	Seq       []attr                     `yaml:"seq"`
+	Types     map[string]*spec           `yaml:"types"`
+	Enums     map[string]map[int64]label `yaml:"enums"`
+	Instances yaml.Node                  `yaml:"instances"`
+}
+
+type meta struct {
+	ID            string    `yaml:"id"`
+	Title         string    `yaml:"title"`
+	Application   yaml.Node `yaml:"application"`
+	FileExtension yaml.Node `yaml:"file-extension"`
+	XRef          yaml.Node `yaml:"xref"`
+	Tags          []string  `yaml:"tags"`
+	License       string    `yaml:"license"`
+	KSVersion     yaml.Node `yaml:"ks-version"`
+	KSDebug       bool      `yaml:"ks-debug"`
+	Imports       []string  `yaml:"imports"`
+	Encoding      string    `yaml:"encoding"`
+	Endian        yaml.Node `yaml:"endian"`
+	BitEndian     string    `yaml:"bit-endian"`
+}
+
+type attr struct {
+	ID          string    `yaml:"id"`
+	Doc         string    `yaml:"doc"`
+	DocRef      yaml.Node `yaml:"doc-ref"`
+	Type        yaml.Node `yaml:"type"`
+	Size        expr      `yaml:"size"`
+	SizeEOS     bool      `yaml:"size-eos"`
+	Contents    yaml.Node `yaml:"contents"`
+	Repeat      string    `yaml:"repeat"`
+	RepeatExpr  expr      `yaml:"repeat-expr"`
+	RepeatUntil expr      `yaml:"repeat-until"`
+	If          expr      `yaml:"if"`
+	Enum        string    `yaml:"enum"`
+	Encoding    string    `yaml:"encoding"`
+
+	// Unsupported keys, listed for a clear error.
+	Terminator yaml.Node `yaml:"terminator"`
+	Consume    yaml.Node `yaml:"consume"`
+	Include    yaml.Node `yaml:"include"`
+	EOSError   yaml.Node `yaml:"eos-error"`
+	PadRight   yaml.Node `yaml:"pad-right"`
+	Process    yaml.Node `yaml:"process"`
+	Valid      yaml.Node `yaml:"valid"`
+}
+
+// expr is an expression, given as a YAML scalar of any type.
+type expr string
+
+func (e *expr) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expression must be a scalar", n.Line)
+	}
+	*e = expr(n.Value)
+	return nil
+}
+
+// label is the name of an enum value, given as "name" or "{id: name}".
+type label string
+
+func (l *label) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = label(n.Value)
+		return nil
+	}
+	var v struct {
+		ID     string    `yaml:"id"`
+		Doc    string    `yaml:"doc"`
+		DocRef yaml.Node `yaml:"doc-ref"`
+		OrigID string    `yaml:"-orig-id"`
+	}
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	*l = label(v.ID)
+	return nil
+}
+
+type converter struct {
+	s *schema.Schema
+}
+
+// spec converts the type t at path and returns its fields. endian and
+// encoding are the defaults inherited from the enclosing type.
+func (c *converter) spec(path string, t *spec, endian, encoding string) ([]schema.Field, error) {
+	switch {
+	case !t.Params.IsZero():
+		return nil, fmt.Errorf("ksy: %s: params are not supported", path)
+	case !t.Instances.IsZero():
+		return nil, fmt.Errorf("ksy: %s: instances are not supported", path)
+	}
+	endian, err := checkEndian(t.Meta.Endian, endian)
+	if err != nil {
+		return nil, fmt.Errorf("ksy: %s: %w", path, err)
+	}
+	if t.Meta.Encoding != "" {
+		encoding = t.Meta.Encoding
+	}
+	for name, values := range t.Enums {
+		if _, ok := c.s.Enums[name]; ok {
+			return nil, fmt.Errorf("ksy: %s: enum %s is defined more than once", path, name)
+		}
+		m := make(map[int64]string, len(values))
+		for v, l := range values {
+			m[v] = string(l)
+		}
+		c.s.Enums[name] = m
+	}
+	for name, sub := range t.Types {
+		if _, ok := c.s.Types[name]; ok {
+			return nil, fmt.Errorf("ksy: %s: type %s is defined more than once", path, name)
+		}
+		// Reserve the name first, so that nested types cannot reuse it.
+		c.s.Types[name] = nil
+		fields, err := c.spec(path+"::"+name, sub, endian, encoding)
+		if err != nil {
+			return nil, err
+		}
+		c.s.Types[name] = fields
+	}
+	fields := make([]schema.Field, 0, len(t.Seq))
+	for i := range t.Seq {
+		a := &t.Seq[i]
+		f, err := c.attr(a, endian, encoding)
+		if err != nil {
+			id := a.ID
+			if id == "" {
+				id = strconv.Itoa(i)
+			}
+			return nil, fmt.Errorf("ksy: %s.%s: %w", path, id, err)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// attr converts a seq entry.
+func (c *converter) attr(a *attr, endian, encoding string) (schema.Field, error) {
+	f := schema.Field{
+		Name:        a.ID,
+		Length:      schema.Expr(a.Size),
+		LengthEOS:   a.SizeEOS,
+		If:          schema.Expr(a.If),
+		Enum:        lastName(a.Enum),
+		RepeatUntil: schema.Expr(a.RepeatUntil),
+	}
+	for _, k := range []struct {
+		key  string
+		node *yaml.Node
+	}{
+		{"terminator", &a.Terminator}, {"consume", &a.Consume}, {"include", &a.Include},
+		{"eos-error", &a.EOSError}, {"pad-right", &a.PadRight}, {"process", &a.Process},
+		{"valid", &a.Valid},
+	} {
+		if !k.node.IsZero() {
+			return f, fmt.Errorf("%s is not supported", k.key)
+		}
+	}
+	switch a.Repeat {
+	case "":
+	case "expr":
+		f.Count = schema.Expr(a.RepeatExpr)
+	case "eos":
+		f.RepeatEOS = true
+	case "until":
+	default:
+		return f, fmt.Errorf("invalid repeat %q", a.Repeat)
+	}
+	if (a.Repeat == "expr") != (a.RepeatExpr != "") || (a.Repeat == "until") != (a.RepeatUntil != "") {
+		return f, fmt.Errorf("repeat does not match repeat-expr and repeat-until")
+	}
+	if !a.Contents.IsZero() {
+		b, err := contents(&a.Contents)
+		if err != nil {
+			return f, err
+		}
+		f.Type, f.Length, f.Contents = "bytes", schema.Expr(strconv.Itoa(len(b))), b
+		return f, nil
+	}
+	if a.Type.IsZero() {
+		f.Type = "bytes"
+		return f, nil
+	}
+	if a.Type.Kind != yaml.ScalarNode {
+		return f, fmt.Errorf("switch-on types are not supported")
+	}
+	typ := a.Type.Value
+	switch {
+	case typ == "str":
+		if a.Encoding != "" {
+			encoding = a.Encoding
+		}
+		switch strings.ToUpper(encoding) {
+		case "UTF-8", "UTF8", "ASCII":
+		default:
+			return f, fmt.Errorf("encoding %s is not supported", encoding)
+		}
+		f.Type = "string"
+	case typ == "strz":
+		return f, fmt.Errorf("strz is not supported")
+	case isBits(typ):
+		return f, fmt.Errorf("bit fields are not supported")
+	case strings.Contains(typ, "("):
+		return f, fmt.Errorf("type parameters are not supported")
+	default:
+		var ok bool
+		if f.Type, f.Endian, ok = scalar(typ); ok {
+			if f.Endian == "" {
+				f.Endian = endian
+			}
+		} else {
+			f.Type = lastName(typ)
+		}
+	}
+	return f, nil
+}
+
+// scalar converts a Kaitai integer or float type such as "u4le" to a schema
+// type and byte order.
+func scalar(typ string) (string, string, bool) {
+	endian := ""
+	if n := len(typ); n > 2 && (typ[n-2:] == "le" || typ[n-2:] == "be") {
+		typ, endian = typ[:n-2], typ[n-2:]
+	}
+	t, ok := map[string]string{
+		"u1": "uint8", "u2": "uint16", "u4": "uint32", "u8": "uint64",
+		"s1": "int8", "s2": "int16", "s4": "int32", "s8": "int64",
+		"f4": "float32", "f8": "float64",
+	}[typ]
+	if ok && endian != "" && (typ == "u1" || typ == "s1") {
+		return "", "", false
+	}
+	return t, endian, ok
+}
+
+func isBits(typ string) bool {
+	if len(typ) < 2 || typ[0] != 'b' {
+		return false
+	}
+	n := strings.TrimSuffix(strings.TrimSuffix(typ[1:], "le"), "be")
+	_, err := strconv.Atoi(n)
+	return err == nil
+}
+
+// contents returns the bytes of a contents value: a string, or a list of byte
+// values and strings.
+func contents(n *yaml.Node) ([]byte, error) {
+	if n.Kind == yaml.ScalarNode {
+		return []byte(n.Value), nil
+	}
+	if n.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: invalid contents", n.Line)
+	}
+	b := []byte{}
+	for _, item := range n.Content {
+		if item.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: invalid contents", item.Line)
+		}
+		if item.Tag != "!!int" {
+			b = append(b, item.Value...)
+			continue
+		}
+		c, err := strconv.ParseUint(item.Value, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid contents byte %s", item.Line, item.Value)
+		}
+		b = append(b, byte(c))
+	}
+	return b, nil
+}
+
+// checkEndian returns the byte order given by n, or def if n is not set.
+func checkEndian(n yaml.Node, def string) (string, error) {
+	if n.IsZero() {
+		return def, nil
+	}
+	if n.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("switch-on endian is not supported")
+	}
+	if n.Value != "le" && n.Value != "be" {
+		return "", fmt.Errorf("invalid endian %q", n.Value)
+	}
+	return n.Value, nil
+}
+
+// lastName strips the enclosing types from a reference such as "a::b".
+func lastName(ref string) string {
+	if i := strings.LastIndex(ref, "::"); i >= 0 {
+		return ref[i+2:]
+	}
+	return ref
+}
+
+func orID(id string) string {
+	if id == "" {
+		return "root"
+	}
+	return id
+}
@@ -0,0 +1,145 @@
+package ksy
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhuangsirui/binpacker"
+	"github.com/zhuangsirui/binpacker/schema"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.ksy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		base := strings.TrimSuffix(path, ".ksy")
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(base + ".bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := Parse(src)
+			assert.NoError(t, err)
+			u := binpacker.NewSliceUnpacker(binary.BigEndian, data)
+			root, err := schema.DecodeNodes(s, u.Unpacker)
+			assert.NoError(t, err)
+			assert.Equal(t, root.Length, int64(len(data)), "length error.")
+			got, err := json.MarshalIndent(root, "", "\t")
+			assert.NoError(t, err)
+			got = append(got, '\n')
+			if *update {
+				if err := os.WriteFile(base+".golden", got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(base + ".golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(want), string(got), "golden file mismatch, run go test -update.")
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`
+meta:
+  id: sample
+  endian: be
+seq:
+  - id: magic
+    contents: [0x7f, "ELF", 1]
+  - id: a
+    type: u4le
+  - id: b
+    type: f8
+  - id: items
+    type: outer::inner
+    repeat: eos
+types:
+  outer:
+    meta:
+      endian: le
+    types:
+      inner:
+        seq:
+          - id: n
+            type: s2
+            enum: outer::kind
+    enums:
+      kind:
+        1: one
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, s.Endian, "be", "endian error.")
+	assert.Equal(t, s.Fields, []schema.Field{
+		{Name: "magic", Type: "bytes", Length: "5", Contents: []byte("\x7fELF\x01")},
+		{Name: "a", Type: "uint32", Endian: "le"},
+		{Name: "b", Type: "float64", Endian: "be"},
+		{Name: "items", Type: "inner", RepeatEOS: true},
+	}, "fields error.")
+	assert.Equal(t, s.Types["inner"], []schema.Field{
+		{Name: "n", Type: "int16", Endian: "le", Enum: "kind"},
+	}, "nested type error.")
+	assert.Equal(t, s.Enums, map[string]map[int64]string{"kind": {1: "one"}}, "enum error.")
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"seq: [{id: a, type: u4, unknown: 1}]",
+		"seq: [{id: a, type: strz}]",
+		"seq: [{id: a, type: b3}]",
+		"seq: [{id: a, type: str, size: 2, encoding: UTF-16LE}]",
+		"seq: [{id: a, type: {switch-on: x, cases: {}}}]",
+		"seq: [{id: a, size: 2, process: xor(1)}]",
+		"seq: [{id: a, type: u1, repeat: expr}]",
+		"seq: [{id: a, type: u1, repeat: forever}]",
+		"seq: [{id: a, type: missing}]",
+		"seq: [{id: a, type: u1, if: 'a +'}]",
+		"seq: [{id: a, type: u1le}]",
+		"seq: [{id: a, contents: [256]}]",
+		"meta: {endian: middle}",
+		"meta: {imports: [other]}",
+		"instances: {a: {pos: 0, type: u1}}",
+		"types: {a: {types: {a: {seq: []}}}}",
+		"types: {a: {enums: {e: {}}}, b: {enums: {e: {}}}}",
+	} {
+		_, err := Parse([]byte(src))
+		assert.Error(t, err, src)
+	}
+}
+
+func TestContents(t *testing.T) {
+	s, err := Parse([]byte(`
+seq:
+  - id: magic
+    contents: [0x7f, "ELF"]
+  - id: a
+    type: u1
+`))
+	assert.NoError(t, err)
+	tree, err := schema.Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, []byte("\x7fELF\x02")).Unpacker)
+	assert.NoError(t, err)
+	assert.Equal(t, tree["magic"], []byte("\x7fELF"), "magic error.")
+
+	_, err = schema.Decode(s, binpacker.NewSliceUnpacker(binary.BigEndian, []byte("\x7fEXF\x02")).Unpacker)
+	assert.ErrorIs(t, err, schema.ErrContentsMismatch, "mismatch error.")
+	var ce *schema.ContentsError
+	assert.True(t, errors.As(err, &ce), "error type error.")
+	assert.Equal(t, ce, &schema.ContentsError{Offset: 2, Expected: []byte("\x7fELF"), Actual: []byte("\x7fEXF")}, "contents error.")
+}
@@ -0,0 +1,301 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators lists the operator tokens, longest first.
+var operators = []string{
+	"::", "<<", ">>", "<=", ">=", "==", "!=",
+	"+", "-", "*", "/", "%", "&", "|", "^", "~", "<", ">", "(", ")", "[", "]", "?", ":", ".",
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case '0' <= c && c <= '9':
+			j := i + 1
+			for j < len(s) && (isIdentByte(s[j]) || s[j] == '.' && j+1 < len(s) && '0' <= s[j+1] && s[j+1] <= '9') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j], i})
+			i = j
+		case isIdentByte(c):
+			j := i + 1
+			for j < len(s) && isIdentByte(s[j]) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j], i})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text := s[i+1 : j]
+			if c == '"' {
+				var err error
+				if text, err = strconv.Unquote(s[i : j+1]); err != nil {
+					return nil, fmt.Errorf("invalid string at %d", i)
+				}
+			}
+			toks = append(toks, token{tokString, text, i})
+			i = j + 1
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// binding powers of the binary operators.
+var infix = map[string]int{
+	"or": 2, "and": 3,
+	"==": 5, "!=": 5, "<": 5, "<=": 5, ">": 5, ">=": 5,
+	"|": 6, "^": 7, "&": 8, "<<": 9, ">>": 9,
+	"+": 10, "-": 10, "*": 11, "/": 11, "%": 11,
+}
+
+const (
+	bpTernary = 1
+	bpNot     = 4
+	bpUnary   = 12
+)
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parseExpr parses an expression in the subset of the Kaitai Struct
+// expression language described at Expr.
+func parseExpr(s string) (node, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", s, err)
+	}
+	p := &parser{toks: toks}
+	n, err := p.expr(0)
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", s, err)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	return unexpected(p.peek())
+}
+
+func unexpected(t token) error {
+	if t.kind == tokEOF {
+		return fmt.Errorf("unexpected end")
+	}
+	return fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// expr parses an expression whose operators bind tighter than min.
+func (p *parser) expr(min int) (node, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokOp && t.text == "?" && min < bpTernary {
+			p.next()
+			a, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			b, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			left = ternary{left, a, b}
+			continue
+		}
+		bp, ok := infix[t.text]
+		if !ok || t.kind == tokString || t.kind == tokNumber || bp <= min {
+			return left, nil
+		}
+		p.next()
+		right, err := p.expr(bp)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryOp{t.text, left, right}
+	}
+}
+
+func (p *parser) prefix() (node, error) {
+	t := p.next()
+	var n node
+	switch {
+	case t.kind == tokNumber:
+		v, err := parseNumber(t.text)
+		if err != nil {
+			return nil, err
+		}
+		n = literal{v}
+	case t.kind == tokString:
+		n = literal{t.text}
+	case t.kind == tokIdent && t.text == "not":
+		x, err := p.expr(bpNot)
+		if err != nil {
+			return nil, err
+		}
+		return unary{"not", x}, nil
+	case t.kind == tokIdent && (t.text == "true" || t.text == "false"):
+		n = literal{t.text == "true"}
+	case t.kind == tokIdent:
+		path := []string{t.text}
+		for p.accept("::") {
+			id := p.next()
+			if id.kind != tokIdent {
+				return nil, unexpected(id)
+			}
+			path = append(path, id.text)
+		}
+		if len(path) == 1 {
+			n = name{t.text}
+		} else {
+			// type::enum::value refers to enum in type; enums are global.
+			n = enumConst{path[len(path)-2], path[len(path)-1]}
+		}
+	case t.kind == tokOp && (t.text == "-" || t.text == "~"):
+		x, err := p.expr(bpUnary)
+		if err != nil {
+			return nil, err
+		}
+		return unary{t.text, x}, nil
+	case t.kind == tokOp && t.text == "(":
+		x, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		n = x
+	default:
+		return nil, unexpected(t)
+	}
+	return p.postfix(n)
+}
+
+func (p *parser) postfix(n node) (node, error) {
+	for {
+		switch {
+		case p.accept("."):
+			id := p.next()
+			if id.kind != tokIdent {
+				return nil, unexpected(id)
+			}
+			n = member{n, id.text}
+		case p.accept("["):
+			i, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = index{n, i}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// parseNumber parses an integer in decimal, 0x, 0o or 0b notation with
+// optional underscores, or a decimal float.
+func parseNumber(s string) (interface{}, error) {
+	if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return i, nil
+	}
+	if u, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return u, nil
+	}
+	if strings.ContainsAny(s, ".eE") && !strings.HasPrefix(s, "0x") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid number %q", s)
+}
@@ -0,0 +1,244 @@
+// Package schema describes binary layouts as data, so that formats can be
+// loaded at runtime and decoded or encoded with binpacker without generating
+// code.
+//
+// A Schema is a list of fields. Scalar types use the names of the "bin"
+// struct tag ("uint16", "int32", "float64", "bool", ...), and "bytes" and
+// "string" fields take their length from a Length expression, a Prefix or
+// the rest of the input. Fields of type "struct" hold nested Fields, and other
+// type names refer to Schema.Types. A Count, CountPrefix, RepeatEOS or
+// RepeatUntil repeats a field, and If makes it optional.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zhuangsirui/binpacker/internal/bintag"
+)
+
+// Schema describes a binary layout.
+type Schema struct {
+	// Endian is the default byte order, "be" or "le". It defaults to "be".
+	Endian string `json:"endian,omitempty"`
+	// Fields are the top level fields.
+	Fields []Field `json:"fields"`
+	// Types are named structs that fields may use as their type.
+	Types map[string][]Field `json:"types,omitempty"`
+	// Enums name the values of integer fields, see Field.Enum.
+	Enums map[string]map[int64]string `json:"enums,omitempty"`
+}
+
+// Field describes a single field of a struct.
+type Field struct {
+	Name string `json:"name"`
+	// Type is a scalar type name, "bytes", "string", "struct" for the inline
+	// Fields, or the name of an entry in Schema.Types.
+	Type string `json:"type"`
+	// Endian overrides the byte order for this field and, for structs, the
+	// fields in it.
+	Endian string `json:"endian,omitempty"`
+	// Enum is the name of an entry in Schema.Enums. The field must be an
+	// integer and decodes to an EnumValue.
+	Enum string `json:"enum,omitempty"`
+	// If makes the field optional: it is only present when If is true.
+	If Expr `json:"if,omitempty"`
+
+	// Length is the length in bytes of a bytes or string field. A struct
+	// with a Length is decoded from exactly that many bytes.
+	Length Expr `json:"length,omitempty"`
+	// LengthEOS makes the field extend to the end of the input.
+	LengthEOS bool `json:"lengthEos,omitempty"`
+	// Prefix is the type of a length prefix, e.g. "uint16", used instead
+	// of Length.
+	Prefix string `json:"prefix,omitempty"`
+	// Contents are the bytes a bytes field must hold, such as a magic
+	// number. Other bytes fail with a *ContentsError.
+	Contents []byte `json:"contents,omitempty"`
+
+	// Count repeats the field; its value becomes a []interface{}.
+	Count Expr `json:"count,omitempty"`
+	// CountPrefix is the type of a count prefix, used instead of Count.
+	CountPrefix string `json:"countPrefix,omitempty"`
+	// RepeatEOS repeats the field until the end of the input.
+	RepeatEOS bool `json:"repeatEos,omitempty"`
+	// RepeatUntil repeats the field until the expression is true for the
+	// last element, which is available as _.
+	RepeatUntil Expr `json:"repeatUntil,omitempty"`
+
+	// Fields are the fields of an inline struct.
+	Fields []Field `json:"fields,omitempty"`
+}
+
+// Expr is an expression in a subset of the Kaitai Struct expression language.
+// It has integer, float, string and bool literals, references to earlier
+// fields such as "length" or "header.count", the arithmetic, bitwise,
+// comparison and logical operators ("and", "or", "not"), "c ? a : b",
+// indexing, the .length and .to_i members and enum constants such as
+// "color::red". Names are looked up in the enclosing structs from the
+// innermost out; _root and _parent refer to the outermost and the enclosing
+// struct, and _ and _index to the last element and the index in a repeat.
+type Expr string
+
+// UnmarshalJSON accepts both numbers and strings.
+func (e *Expr) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*e = Expr(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("schema: expression must be a number or a string: %s", data)
+	}
+	*e = Expr(s)
+	return nil
+}
+
+// Parse parses a JSON schema and validates it.
+func Parse(data []byte) (*Schema, error) {
+	s := new(Schema)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks that s is well formed.
+func (s *Schema) Validate() error {
+	if err := checkEndian(s.Endian); err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	for name, fields := range s.Types {
+		if isBuiltin(name) {
+			return fmt.Errorf("schema: type %s: shadows a builtin type", name)
+		}
+		if err := s.validateFields(name, fields); err != nil {
+			return err
+		}
+	}
+	return s.validateFields("", s.Fields)
+}
+
+func (s *Schema) validateFields(path string, fields []Field) error {
+	seen := make(map[string]bool, len(fields))
+	for i := range fields {
+		f := &fields[i]
+		p := joinPath(path, f.Name)
+		if f.Name == "" {
+			return fmt.Errorf("schema: %s: field %d has no name", orRoot(path), i)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("schema: %s: duplicate field", p)
+		}
+		seen[f.Name] = true
+		if err := s.validateField(f); err != nil {
+			return fmt.Errorf("schema: %s: %w", p, err)
+		}
+		if f.Type == "struct" {
+			if err := s.validateFields(p, f.Fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateField(f *Field) error {
+	if err := checkEndian(f.Endian); err != nil {
+		return err
+	}
+	if f.Contents != nil && f.Type != "bytes" {
+		return fmt.Errorf("contents on a %s field", f.Type)
+	}
+	for _, e := range []Expr{f.If, f.Length, f.Count, f.RepeatUntil} {
+		if e == "" {
+			continue
+		}
+		if _, err := parseExpr(string(e)); err != nil {
+			return err
+		}
+	}
+	for _, prefix := range []string{f.Prefix, f.CountPrefix} {
+		if _, ok := bintag.PrefixWidths[prefix]; prefix != "" && !ok {
+			return fmt.Errorf("invalid prefix %q", prefix)
+		}
+	}
+	if count(f.Count != "", f.CountPrefix != "", f.RepeatEOS, f.RepeatUntil != "") > 1 {
+		return fmt.Errorf("more than one of count, countPrefix, repeatEos and repeatUntil is set")
+	}
+	lengths := count(f.Length != "", f.LengthEOS, f.Prefix != "")
+	if lengths > 1 {
+		return fmt.Errorf("more than one of length, lengthEos and prefix is set")
+	}
+	sc, scalar := bintag.Scalars[f.Type]
+	switch {
+	case f.Type == "bytes" || f.Type == "string":
+		if lengths == 0 {
+			return fmt.Errorf("one of length, lengthEos and prefix is required")
+		}
+	case f.Type == "struct":
+		if len(f.Fields) == 0 {
+			return fmt.Errorf("struct has no fields")
+		}
+	case scalar:
+		if lengths > 0 {
+			return fmt.Errorf("length, lengthEos and prefix do not apply to %s", f.Type)
+		}
+	default:
+		if _, ok := s.Types[f.Type]; !ok {
+			return fmt.Errorf("unknown type %q", f.Type)
+		}
+	}
+	if f.Type != "struct" && len(f.Fields) > 0 {
+		return fmt.Errorf("fields only apply to struct")
+	}
+	if f.Enum != "" {
+		if _, ok := s.Enums[f.Enum]; !ok {
+			return fmt.Errorf("unknown enum %q", f.Enum)
+		}
+		if !scalar || sc.Float || f.Type == "bool" {
+			return fmt.Errorf("enum needs an integer type")
+		}
+	}
+	return nil
+}
+
+func count(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+func checkEndian(e string) error {
+	if e != "" && e != "be" && e != "le" {
+		return fmt.Errorf("invalid endian %q", e)
+	}
+	return nil
+}
+
+func isBuiltin(name string) bool {
+	_, ok := bintag.Scalars[name]
+	return ok || name == "bytes" || name == "string" || name == "struct"
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func orRoot(path string) string {
+	if path == "" {
+		return "root"
+	}
+	return path
+}
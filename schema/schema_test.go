@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const packetSchema = `{
+	"endian": "be",
+	"fields": [
+		{"name": "magic", "type": "bytes", "length": 4},
+		{"name": "version", "type": "uint16", "endian": "le"},
+		{"name": "count", "type": "uint8"},
+		{"name": "records", "type": "record", "count": "count"},
+		{"name": "trailer", "type": "struct", "fields": [
+			{"name": "ok", "type": "bool"},
+			{"name": "ratio", "type": "float32"}
+		]}
+	],
+	"types": {
+		"record": [
+			{"name": "id", "type": "int16"},
+			{"name": "name", "type": "string", "prefix": "uint8"},
+			{"name": "tags", "type": "uint8", "countPrefix": "uint8"}
+		]
+	}
+}`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(packetSchema))
+	assert.NoError(t, err)
+	assert.Equal(t, s.Fields[0], Field{Name: "magic", Type: "bytes", Length: "4"}, "field error.")
+	assert.Equal(t, s.Fields[3].Count, Expr("count"), "count error.")
+	assert.Equal(t, len(s.Types["record"]), 3, "types error.")
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"fields": [{"name": "a"}]}`,
+		`{"fields": [{"type": "uint8"}]}`,
+		`{"fields": [{"name": "a", "type": "uint8"}, {"name": "a", "type": "uint8"}]}`,
+		`{"fields": [{"name": "a", "type": "uint9"}]}`,
+		`{"fields": [{"name": "a", "type": "bytes"}]}`,
+		`{"fields": [{"name": "a", "type": "bytes", "length": 1, "prefix": "uint8"}]}`,
+		`{"fields": [{"name": "a", "type": "bytes", "prefix": "uint3"}]}`,
+		`{"fields": [{"name": "a", "type": "bytes", "length": "1 +"}]}`,
+		`{"fields": [{"name": "a", "type": "uint8", "length": 1}]}`,
+		`{"fields": [{"name": "a", "type": "uint8", "endian": "middle"}]}`,
+		`{"fields": [{"name": "a", "type": "struct"}]}`,
+		`{"fields": [{"name": "a", "type": "uint8", "count": true}]}`,
+		`{"fields": [], "types": {"uint8": [{"name": "a", "type": "uint8"}]}}`,
+		`{"fields": [], "types": {"t": [{"name": "a", "type": "nope"}]}}`,
+	} {
+		_, err := Parse([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
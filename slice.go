@@ -0,0 +1,138 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"io"
+	"unsafe"
+)
+
+// Decoder is the Shift*/Fetch* API shared by Unpacker and SliceUnpacker, so
+// that decoders can be written once for both.
+type Decoder interface {
+	Error() error
+	Offset() int64
+
+	ShiftByte() (byte, error)
+	ShiftBytes(n uint64) ([]byte, error)
+	ShiftUint8() (uint8, error)
+	ShiftUint16() (uint16, error)
+	ShiftInt16() (int16, error)
+	ShiftUint32() (uint32, error)
+	ShiftInt32() (int32, error)
+	ShiftUint64() (uint64, error)
+	ShiftInt64() (int64, error)
+	ShiftFloat32() (float32, error)
+	ShiftFloat64() (float64, error)
+	ShiftString(n uint64) (string, error)
+
+	FetchByte(b *byte) *Unpacker
+	FetchBytes(n uint64, bytes *[]byte) *Unpacker
+	FetchUint8(i *uint8) *Unpacker
+	FetchUint16(i *uint16) *Unpacker
+	FetchInt16(i *int16) *Unpacker
+	FetchUint32(i *uint32) *Unpacker
+	FetchInt32(i *int32) *Unpacker
+	FetchUint64(i *uint64) *Unpacker
+	FetchInt64(i *int64) *Unpacker
+	FetchFloat32(i *float32) *Unpacker
+	FetchFloat64(i *float64) *Unpacker
+	FetchString(n uint64, s *string) *Unpacker
+}
+
+// SliceUnpacker is an Unpacker over data that is already in memory. Fixed-width
+// reads do not allocate, and the NoCopy methods return views of the input
+// instead of copies.
+type SliceUnpacker struct {
+	*Unpacker
+}
+
+// NewSliceUnpacker returns a *SliceUnpacker reading data. User must provide
+// the byte order explicitly.
+func NewSliceUnpacker(endian binary.ByteOrder, data []byte) *SliceUnpacker {
+	return &SliceUnpacker{&Unpacker{
+		endian: endian,
+		slice:  true,
+		data:   data,
+	}}
+}
+
+// Sub returns a *SliceUnpacker reading data, typically a section u has read,
+// with the byte order, limits, tracer and settings of u. Its offsets start at
+// 0, and MaxTotalBytes does not apply as data is in memory already.
+func (u *Unpacker) Sub(data []byte) *SliceUnpacker {
+	s := NewSliceUnpacker(u.endian, data)
+	s.opts = u.opts
+	s.opts.MaxTotalBytes = 0
+	s.depth = u.depth
+	s.tracer, s.scopes = u.tracer, u.scopes
+	s.strict, s.bools = u.strict, u.bools
+	return s
+}
+
+// ShiftBytesNoCopy fetch n bytes. The returned slice aliases the input and
+// must not be modified unless the input may be.
+func (s *SliceUnpacker) ShiftBytesNoCopy(n uint64) ([]byte, error) {
+	b, err := s.shiftBytes("ShiftBytesNoCopy", n)
+	if err == nil && s.tracer != nil {
+		s.traceShifted("ShiftBytesNoCopy", b, b)
+	}
+	return b, err
+}
+
+// FetchBytesNoCopy read n bytes without copying them and set to bytes.
+func (s *SliceUnpacker) FetchBytesNoCopy(n uint64, bytes *[]byte) *SliceUnpacker {
+	s.errFilter(func() {
+		*bytes, s.err = s.ShiftBytesNoCopy(n)
+	})
+	return s
+}
+
+// ShiftStringNoCopy fetch n bytes and return them as a string sharing memory
+// with the input. The input must not be modified while the string is in use.
+func (s *SliceUnpacker) ShiftStringNoCopy(n uint64) (string, error) {
+	const op = "ShiftStringNoCopy"
+	offset := s.offset
+	b, err := s.shiftBytes(op, n)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkUTF8(op, offset, b); err != nil {
+		return "", err
+	}
+	str := unsafe.String(unsafe.SliceData(b), len(b))
+	if s.tracer != nil {
+		s.traceShifted(op, b, str)
+	}
+	return str, nil
+}
+
+// FetchStringNoCopy read n bytes as a string sharing memory with the input and
+// set it to str.
+func (s *SliceUnpacker) FetchStringNoCopy(n uint64, str *string) *SliceUnpacker {
+	s.errFilter(func() {
+		*str, s.err = s.ShiftStringNoCopy(n)
+	})
+	return s
+}
+
+// Remaining returns the unread part of the input.
+func (s *SliceUnpacker) Remaining() []byte {
+	return s.data[s.offset:]
+}
+
+// Len returns the number of unread bytes.
+func (s *SliceUnpacker) Len() int {
+	return len(s.data) - int(s.offset)
+}
+
+// readSlice returns the next n bytes of the input of a SliceUnpacker.
+func (u *Unpacker) readSlice(n uint64) ([]byte, error) {
+	rest := u.data[u.offset:]
+	if n > uint64(len(rest)) {
+		if len(rest) == 0 {
+			return rest, io.EOF
+		}
+		return rest, io.ErrUnexpectedEOF
+	}
+	return rest[:n], nil
+}
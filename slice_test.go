@@ -0,0 +1,105 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSliceUnpacker(t *testing.T) {
+	data := []byte{0x01, 0, 1, 255, 255, 255, 255, 'H', 'i', 0, 0, 0, 2, 'o', 'k'}
+	s := NewSliceUnpacker(binary.BigEndian, data)
+	var b byte
+	var ui16 uint16
+	var i32 int32
+	var str string
+	s.FetchByte(&b).FetchUint16(&ui16).FetchInt32(&i32)
+	assert.Equal(t, s.Error(), nil, "Has error.")
+	assert.Equal(t, b, byte(1), "byte error.")
+	assert.Equal(t, ui16, uint16(1), "uint16 error.")
+	assert.Equal(t, i32, int32(-1), "int32 error.")
+	assert.Equal(t, s.Len(), 8, "len error.")
+	assert.Equal(t, s.Remaining(), data[7:], "remaining error.")
+
+	bs, err := s.ShiftBytesNoCopy(2)
+	assert.Equal(t, err, nil, "Has error.")
+	assert.Equal(t, bs, []byte("Hi"), "bytes error.")
+	assert.True(t, &bs[0] == &data[7], "bytes should alias the input.")
+
+	s.StringWithUint32Prefix(&str)
+	assert.Equal(t, s.Error(), nil, "Has error.")
+	assert.Equal(t, str, "ok", "string error.")
+	assert.Equal(t, s.Len(), 0, "len error.")
+	assert.Equal(t, s.Offset(), int64(len(data)), "offset error.")
+
+	_, err = s.ShiftUint8()
+	assert.ErrorIs(t, err, io.EOF, "EOF error.")
+}
+
+func TestSliceUnpackerCopies(t *testing.T) {
+	data := []byte("Hello")
+	s := NewSliceUnpacker(binary.BigEndian, data)
+	bs, err := s.ShiftBytes(2)
+	assert.Equal(t, err, nil, "Has error.")
+	str, err := s.ShiftStringNoCopy(3)
+	assert.Equal(t, err, nil, "Has error.")
+	data[0], data[2] = 'J', 'L'
+	assert.Equal(t, bs, []byte("He"), "ShiftBytes should copy.")
+	assert.Equal(t, str, "Llo", "ShiftStringNoCopy should alias.")
+}
+
+func TestSliceUnpackerShortRead(t *testing.T) {
+	s := NewSliceUnpacker(binary.BigEndian, []byte{1, 2, 3})
+	_, err := s.ShiftUint32()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "EOF error.")
+	assert.Equal(t, &Error{Op: "ShiftUint32", Offset: 0, Want: 4, Got: 3, Err: io.ErrUnexpectedEOF}, err, "error error.")
+	assert.Equal(t, s.Len(), 0, "len error.")
+}
+
+func decodeWith(d Decoder) (uint16, string, error) {
+	var i uint16
+	var s string
+	err := d.FetchUint16(&i).FetchString(2, &s).Error()
+	return i, s, err
+}
+
+func TestDecoder(t *testing.T) {
+	data := []byte{0, 7, 'H', 'i'}
+	for _, d := range []Decoder{NewSliceUnpacker(binary.BigEndian, data), NewUnpacker(binary.BigEndian, &testReader{data: data, stride: 1})} {
+		i, s, err := decodeWith(d)
+		assert.Equal(t, err, nil, "Has error.")
+		assert.Equal(t, i, uint16(7), "uint16 error.")
+		assert.Equal(t, s, "Hi", "string error.")
+	}
+}
+
+func TestSliceUnpackerAllocs(t *testing.T) {
+	data := make([]byte, 64)
+	var b []byte
+	var i16 uint16
+	var i32 uint32
+	var i64 uint64
+	var f64 float64
+	var str string
+	s := NewSliceUnpacker(binary.LittleEndian, data)
+	allocs := testing.AllocsPerRun(100, func() {
+		s.offset = 0
+		s.FetchUint16(&i16).FetchUint32(&i32).FetchUint64(&i64).FetchFloat64(&f64)
+		s.FetchBytesNoCopy(8, &b).FetchStringNoCopy(8, &str)
+	})
+	assert.Equal(t, allocs, float64(0), "allocation error.")
+}
+
+func BenchmarkSliceUnpackerShiftUint32(b *testing.B) {
+	data := make([]byte, 1024)
+	s := NewSliceUnpacker(binary.BigEndian, data)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if s.Len() < 4 {
+			s = NewSliceUnpacker(binary.BigEndian, data)
+		}
+		s.ShiftUint32()
+	}
+}
@@ -0,0 +1,285 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInvalidUTF8 is returned for a string that is not valid UTF-8: when
+	// encoding it as UTF-16 or Latin-1, or when reading it from an Unpacker
+	// set to WithStrictUTF8.
+	ErrInvalidUTF8 = errors.New("binpacker: invalid UTF-8")
+	// ErrNotLatin1 is returned by PushStringLatin1 for a character above
+	// U+00FF.
+	ErrNotLatin1 = errors.New("binpacker: character not representable in Latin-1")
+	// ErrInvalidSurrogate is matched by errors.Is for every *SurrogateError.
+	ErrInvalidSurrogate = errors.New("binpacker: invalid UTF-16 surrogate")
+)
+
+// SurrogateError is returned for UTF-16 text with an unpaired surrogate.
+type SurrogateError struct {
+	Unit  uint16 // the surrogate
+	Index int    // its index in the string, in units
+}
+
+func (e *SurrogateError) Error() string {
+	return fmt.Sprintf("binpacker: unpaired UTF-16 surrogate %#04x at unit %d", e.Unit, e.Index)
+}
+
+// Is reports whether target is ErrInvalidSurrogate.
+func (e *SurrogateError) Is(target error) bool {
+	return target == ErrInvalidSurrogate
+}
+
+// WithStrictUTF8 sets whether the string methods of u, such as ShiftString,
+// ShiftCString and ShiftFixedString, reject invalid UTF-8 with ErrInvalidUTF8.
+func (u *Unpacker) WithStrictUTF8(strict bool) *Unpacker {
+	u.strict = strict
+	return u
+}
+
+// checkUTF8 returns an error for b, read at offset, if u is strict and b is
+// not valid UTF-8.
+func (u *Unpacker) checkUTF8(op string, offset int64, b []byte) error {
+	if !u.strict || utf8.Valid(b) {
+		return nil
+	}
+	i := 0
+	for {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size <= 1 {
+			break
+		}
+		i += size
+	}
+	return u.traceError(&Error{Op: op, Offset: offset + int64(i), Err: ErrInvalidUTF8})
+}
+
+// encodeUTF16 returns s as UTF-16 units, or false if s is not valid UTF-8.
+func encodeUTF16(s string) ([]uint16, bool) {
+	if !utf8.ValidString(s) {
+		return nil, false
+	}
+	return utf16.Encode([]rune(s)), true
+}
+
+// decodeUTF16 converts UTF-16 units, given as bytes in order, to a string.
+func decodeUTF16(order binary.ByteOrder, b []byte) (string, error) {
+	n := len(b) / 2
+	buf := make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		c := order.Uint16(b[2*i:])
+		r := rune(c)
+		switch {
+		case !utf16.IsSurrogate(r):
+		case c < 0xDC00 && i+1 < n:
+			if r = utf16.DecodeRune(r, rune(order.Uint16(b[2*i+2:]))); r == utf8.RuneError {
+				return "", &SurrogateError{Unit: c, Index: i}
+			}
+			i++
+		default:
+			return "", &SurrogateError{Unit: c, Index: i}
+		}
+		buf = utf8.AppendRune(buf, r)
+	}
+	return string(buf), nil
+}
+
+// utf16Units encodes s as UTF-16 for op.
+func (p *Packer) utf16Units(op string, s string) ([]uint16, bool) {
+	units, ok := encodeUTF16(s)
+	if !ok {
+		p.fail(op, ErrInvalidUTF8)
+	}
+	return units, ok
+}
+
+// PushStringUTF16 write a string as UTF-16 in order into writer.
+func (p *Packer) PushStringUTF16(s string, order binary.ByteOrder) *Packer {
+	return p.errFilter(func() {
+		const op = "PushStringUTF16"
+		if units, ok := p.utf16Units(op, s); ok {
+			p.pushUTF16(op, order, units, s)
+		}
+	})
+}
+
+// PushStringUTF16WithPrefix write the number of UTF-16 units of a string as a
+// width-byte prefix (1, 2, 4 or 8), then the units, both in order, into
+// writer.
+func (p *Packer) PushStringUTF16WithPrefix(width int, s string, order binary.ByteOrder) *Packer {
+	return p.errFilter(func() {
+		const op = "PushStringUTF16WithPrefix"
+		if units, ok := p.utf16Units(op, s); ok {
+			p.WithEndian(order, func(p *Packer) {
+				p.pushCount(op, width, len(units))
+				p.errFilter(func() {
+					p.pushUTF16("PushStringUTF16", order, units, s)
+				})
+			})
+		}
+	})
+}
+
+// PushCStringUTF16 write a string as UTF-16 in order, followed by a NUL unit,
+// into writer.
+func (p *Packer) PushCStringUTF16(s string, order binary.ByteOrder) *Packer {
+	return p.errFilter(func() {
+		const op = "PushCStringUTF16"
+		units, ok := p.utf16Units(op, s)
+		if !ok {
+			return
+		}
+		for i, c := range units {
+			if c == 0 {
+				p.err = p.traceError(&Error{Op: op, Offset: p.offset + 2*int64(i), Err: ErrStringNUL})
+				return
+			}
+		}
+		p.pushUTF16(op, order, append(units, 0), s)
+	})
+}
+
+// pushUTF16 writes the units of s in order for op and traces s.
+func (p *Packer) pushUTF16(op string, order binary.ByteOrder, units []uint16, s string) {
+	p.WithEndian(order, func(p *Packer) {
+		raw := p.writeUint16s(op, units)
+		if p.tracer != nil {
+			p.tracePushed(op, raw, s)
+		}
+	})
+}
+
+// shiftUTF16 decodes the first n units in raw, read by op at offset, and
+// traces them.
+func (u *Unpacker) shiftUTF16(op string, offset int64, raw []byte, n int) (string, error) {
+	s, err := decodeUTF16(u.endian, raw[:2*n])
+	if err != nil {
+		se := err.(*SurrogateError)
+		return "", u.traceError(&Error{Op: op, Offset: offset + 2*int64(se.Index), Err: err})
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, raw, s)
+	}
+	return s, nil
+}
+
+// ShiftStringUTF16 fetch n UTF-16 units in the byte order of u and convert them
+// to a string. Unpaired surrogates are a *SurrogateError.
+func (u *Unpacker) ShiftStringUTF16(n uint64) (string, error) {
+	const op = "ShiftStringUTF16"
+	offset := u.offset
+	b, err := u.readBulk(op, n, 2)
+	if err != nil {
+		return "", err
+	}
+	return u.shiftUTF16(op, offset, b, len(b)/2)
+}
+
+// FetchStringUTF16 read n UTF-16 units, convert them to a string and set it
+// to s.
+func (u *Unpacker) FetchStringUTF16(n uint64, s *string) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.ShiftStringUTF16(n)
+	})
+}
+
+// FetchStringUTF16WithPrefix read a width-byte prefix (1, 2, 4 or 8) as the
+// number of UTF-16 units, then the units, convert them to a string and set it
+// to s.
+func (u *Unpacker) FetchStringUTF16WithPrefix(width int, s *string) *Unpacker {
+	return u.errFilter(func() {
+		n := u.shiftCount("FetchStringUTF16WithPrefix", width)
+		u.FetchStringUTF16(n, s)
+	})
+}
+
+// ShiftCStringUTF16 fetch UTF-16 units in the byte order of u up to a NUL
+// unit and convert them to a string, without the NUL. At most maxUnits units
+// are read before the NUL; if there is none, the error is ErrNoTerminator.
+func (u *Unpacker) ShiftCStringUTF16(maxUnits uint64) (string, error) {
+	const op = "ShiftCStringUTF16"
+	offset := u.offset
+	b, err := u.shiftTerminated(op, 2, maxUnits)
+	if err != nil {
+		return "", err
+	}
+	return u.shiftUTF16(op, offset, b, len(b)/2-1)
+}
+
+// FetchCStringUTF16 read a NUL-terminated UTF-16 string of at most maxUnits
+// units and set it to s.
+func (u *Unpacker) FetchCStringUTF16(maxUnits uint64, s *string) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.ShiftCStringUTF16(maxUnits)
+	})
+}
+
+// PushStringLatin1 write a string as Latin-1 (ISO 8859-1) into writer, one
+// byte per character. Characters above U+00FF are rejected with ErrNotLatin1.
+func (p *Packer) PushStringLatin1(s string) *Packer {
+	return p.errFilter(func() {
+		const op = "PushStringLatin1"
+		n := 0
+		for i, r := range s {
+			switch {
+			case r == utf8.RuneError:
+				if _, size := utf8.DecodeRuneInString(s[i:]); size <= 1 {
+					p.err = p.traceError(&Error{Op: op, Offset: p.offset + int64(n), Err: ErrInvalidUTF8})
+					return
+				}
+				fallthrough
+			case r > 0xFF:
+				p.err = p.traceError(&Error{Op: op, Offset: p.offset + int64(n), Err: ErrNotLatin1})
+				return
+			}
+			n++
+		}
+		raw := p.writeBulk(op, n, func(b []byte) {
+			i := 0
+			for _, r := range s {
+				b[i] = byte(r)
+				i++
+			}
+		})
+		if p.tracer != nil {
+			p.tracePushed(op, raw, s)
+		}
+	})
+}
+
+// decodeLatin1 converts Latin-1 text to a string.
+func decodeLatin1(b []byte) string {
+	buf := make([]byte, 0, len(b))
+	for _, c := range b {
+		buf = utf8.AppendRune(buf, rune(c))
+	}
+	return string(buf)
+}
+
+// ShiftStringLatin1 fetch n bytes of Latin-1 (ISO 8859-1) text and convert
+// them to a string.
+func (u *Unpacker) ShiftStringLatin1(n uint64) (string, error) {
+	const op = "ShiftStringLatin1"
+	b, err := u.shiftBytes(op, n)
+	if err != nil {
+		return "", err
+	}
+	s := decodeLatin1(b)
+	if u.tracer != nil {
+		u.traceShifted(op, b, s)
+	}
+	return s, nil
+}
+
+// FetchStringLatin1 read n bytes of Latin-1 text, convert them to a string and
+// set it to s.
+func (u *Unpacker) FetchStringLatin1(n uint64, s *string) *Unpacker {
+	return u.errFilter(func() {
+		*s, u.err = u.ShiftStringLatin1(n)
+	})
+}
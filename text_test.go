@@ -0,0 +1,153 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushStringUTF16(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushStringUTF16("hé😀", binary.LittleEndian).
+		PushStringUTF16("A", binary.BigEndian).
+		PushStringUTF16WithPrefix(2, "B", binary.LittleEndian).
+		PushCStringUTF16("C", binary.BigEndian)
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{
+		'h', 0, 0xE9, 0, 0x3D, 0xD8, 0x00, 0xDE,
+		0, 'A',
+		1, 0, 'B', 0,
+		0, 'C', 0, 0,
+	}, "utf16 error.")
+	// The byte order of the Packer is restored.
+	p.PushUint16(1)
+	assert.Equal(t, p.Bytes()[len(p.Bytes())-2:], []byte{0, 1}, "endian error.")
+
+	p = NewAppendPacker(binary.BigEndian, nil)
+	p.PushStringUTF16("a\xffb", binary.LittleEndian)
+	assert.True(t, errors.Is(p.Error(), ErrInvalidUTF8), "utf8 error.")
+
+	p = NewAppendPacker(binary.BigEndian, nil)
+	p.PushCStringUTF16("ab\x00", binary.LittleEndian)
+	var e *Error
+	assert.True(t, errors.As(p.Error(), &e), "error type error.")
+	assert.Equal(t, e.Err, ErrStringNUL, "nul error.")
+	assert.Equal(t, e.Offset, int64(4), "offset error.")
+
+	p = NewAppendPacker(binary.BigEndian, nil)
+	p.PushStringUTF16WithPrefix(1, string(make([]byte, 256)), binary.LittleEndian)
+	assert.True(t, errors.Is(p.Error(), ErrLengthOverflow), "overflow error.")
+	assert.Equal(t, len(p.Bytes()), 0, "overflow error.")
+}
+
+func TestShiftStringUTF16(t *testing.T) {
+	data := []byte{
+		'h', 0, 0xE9, 0, 0x3D, 0xD8, 0x00, 0xDE,
+		2, 0, 'o', 0, 'k', 0,
+		'z', 0, 0, 0,
+	}
+	for _, u := range []*Unpacker{
+		NewSliceUnpacker(binary.LittleEndian, data).Unpacker,
+		NewUnpacker(binary.LittleEndian, bytes.NewReader(data)),
+	} {
+		var a, b, c string
+		u.FetchStringUTF16(4, &a).FetchStringUTF16WithPrefix(2, &b).FetchCStringUTF16(4, &c)
+		assert.Nil(t, u.Error(), "Has error.")
+		assert.Equal(t, []string{a, b, c}, []string{"hé😀", "ok", "z"}, "utf16 error.")
+	}
+
+	for _, c := range []struct {
+		data  []byte
+		unit  uint16
+		index int
+	}{
+		{[]byte{'a', 0, 0x00, 0xDC}, 0xDC00, 1},
+		{[]byte{0x3D, 0xD8, 'a', 0}, 0xD83D, 0},
+		{[]byte{'a', 0, 0x3D, 0xD8}, 0xD83D, 1},
+	} {
+		u := NewSliceUnpacker(binary.LittleEndian, c.data)
+		_, err := u.ShiftStringUTF16(2)
+		var se *SurrogateError
+		assert.True(t, errors.As(err, &se), "surrogate error.")
+		assert.True(t, errors.Is(err, ErrInvalidSurrogate), "surrogate error.")
+		assert.Equal(t, se.Unit, c.unit, "unit error.")
+		assert.Equal(t, se.Index, c.index, "index error.")
+		var e *Error
+		assert.True(t, errors.As(err, &e), "error type error.")
+		assert.Equal(t, e.Offset, int64(2*c.index), "offset error.")
+	}
+
+	u := NewSliceUnpacker(binary.BigEndian, []byte{0, 'a', 0, 'b', 0, 0})
+	_, err := u.ShiftCStringUTF16(1)
+	assert.True(t, errors.Is(err, ErrNoTerminator), "terminator error.")
+	u = NewSliceUnpacker(binary.BigEndian, []byte{0, 'a', 0, 0, 'b', 0})
+	s, err := u.ShiftCStringUTF16(1)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, s, "a", "cstring error.")
+}
+
+func TestStringLatin1(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, nil)
+	p.PushStringLatin1("café ÿ")
+	assert.Nil(t, p.Error(), "Has error.")
+	assert.Equal(t, p.Bytes(), []byte{'c', 'a', 'f', 0xE9, ' ', 0xFF}, "latin1 error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes())
+	var s string
+	u.FetchStringLatin1(6, &s)
+	assert.Nil(t, u.Error(), "Has error.")
+	assert.Equal(t, s, "café ÿ", "latin1 error.")
+
+	p.PushStringLatin1("ab€")
+	var e *Error
+	assert.True(t, errors.As(p.Error(), &e), "error type error.")
+	assert.Equal(t, e.Err, ErrNotLatin1, "latin1 error.")
+	assert.Equal(t, e.Offset, int64(8), "offset error.")
+
+	p = NewAppendPacker(binary.BigEndian, nil)
+	p.PushStringLatin1("a\xff")
+	assert.True(t, errors.Is(p.Error(), ErrInvalidUTF8), "utf8 error.")
+}
+
+func TestStrictUTF8(t *testing.T) {
+	data := []byte("ok\xffz\x00")
+	u := NewSliceUnpacker(binary.BigEndian, data)
+	s, err := u.ShiftString(4)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, s, "ok\xffz", "lenient error.")
+
+	for _, shift := range []func(u *SliceUnpacker) error{
+		func(u *SliceUnpacker) error { _, err := u.ShiftString(4); return err },
+		func(u *SliceUnpacker) error { _, err := u.ShiftStringNoCopy(4); return err },
+		func(u *SliceUnpacker) error { _, err := u.ShiftCString(8); return err },
+		func(u *SliceUnpacker) error { _, err := u.ShiftFixedString(5, TrimNUL); return err },
+	} {
+		u := NewSliceUnpacker(binary.BigEndian, data)
+		u.WithStrictUTF8(true)
+		err := shift(u)
+		var e *Error
+		assert.True(t, errors.As(err, &e), "error type error.")
+		assert.Equal(t, e.Err, ErrInvalidUTF8, "utf8 error.")
+		assert.Equal(t, e.Offset, int64(2), "offset error.")
+	}
+
+	u = NewSliceUnpacker(binary.BigEndian, []byte("héllo"))
+	u.WithStrictUTF8(true)
+	s, err = u.ShiftString(6)
+	assert.Nil(t, err, "Has error.")
+	assert.Equal(t, s, "héllo", "strict error.")
+}
+
+func TestTraceText(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushStringUTF16("hé", binary.LittleEndian).PushCStringUTF16("x", binary.BigEndian).PushStringLatin1("é")
+	assert.Equal(t, tr.lines, []string{
+		"push PushStringUTF16 0 68 00 e9 00 hé",
+		"push PushCStringUTF16 4 00 78 00 00 x",
+		"push PushStringLatin1 8 e9 é",
+	}, "trace error.")
+}
@@ -0,0 +1,194 @@
+package binpacker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tracer is told about every value a Packer writes or an Unpacker reads, and
+// about every error, once attached with WithTracer.
+//
+// op is the operation, e.g. "PushUint16" or "ShiftString", offset the offset
+// at which it started and raw the encoded bytes, which are only valid during
+// the call. value is the Go value as passed or returned, e.g. an int16 for
+// PushInt16 or a string for ShiftString; it is nil for operations such as
+// Reserve that have none. The fixed-point methods trace the Fixed written or
+// read, and the fields of PushStruct and FetchStruct their field values.
+// Composite operations such as PushStringWithUint8Prefix show up as the
+// values they are made of, and varints as a whole.
+type Tracer interface {
+	OnPush(op string, offset int64, raw []byte, value interface{})
+	OnShift(op string, offset int64, raw []byte, value interface{})
+	OnError(op string, offset int64, err error)
+}
+
+// ScopeTracer is a Tracer that is also told where structs and the sections
+// of LengthPrefixed, Checksummed and VerifyChecksummed begin and end. For
+// structs, op is the name of the type.
+type ScopeTracer interface {
+	Tracer
+	OnEnter(op string, offset int64)
+	OnLeave(op string, offset int64)
+}
+
+// WithTracer attaches t to p, or detaches the tracer if t is nil. A Packer
+// without a tracer does no tracing work beyond checking for one.
+func (p *Packer) WithTracer(t Tracer) *Packer {
+	p.tracer = t
+	p.scopes, _ = t.(ScopeTracer)
+	return p
+}
+
+// WithTracer attaches t to u, or detaches the tracer if t is nil. Peek, Seek,
+// Mark and Reset are not traced, except for their errors.
+func (u *Unpacker) WithTracer(t Tracer) *Unpacker {
+	u.tracer = t
+	u.scopes, _ = t.(ScopeTracer)
+	return u
+}
+
+// tracePushed reports v, the value of op written as raw just before the
+// current offset, unless op failed. Callers check p.tracer first, so that v is
+// only boxed when it is traced.
+func (p *Packer) tracePushed(op string, raw []byte, v interface{}) {
+	if p.err == nil {
+		p.tracer.OnPush(op, p.offset-int64(len(raw)), raw, v)
+	}
+}
+
+// traceError reports err if a tracer is attached, and returns it.
+func (p *Packer) traceError(err error) error {
+	if p.tracer != nil {
+		op, offset := errorOp(err, p.offset)
+		p.tracer.OnError(op, offset, err)
+	}
+	return err
+}
+
+func (p *Packer) traceEnter(op string) {
+	if p.scopes != nil {
+		p.scopes.OnEnter(op, p.offset)
+	}
+}
+
+func (p *Packer) traceLeave(op string) {
+	if p.scopes != nil {
+		p.scopes.OnLeave(op, p.offset)
+	}
+}
+
+// traceShifted reports v, the value of op read as raw just before the current
+// offset. Callers check u.tracer first, so that v is only boxed when it is
+// traced.
+func (u *Unpacker) traceShifted(op string, raw []byte, v interface{}) {
+	u.tracer.OnShift(op, u.offset-int64(len(raw)), raw, v)
+}
+
+// traceError reports err if a tracer is attached, and returns it.
+func (u *Unpacker) traceError(err error) error {
+	if u.tracer != nil {
+		op, offset := errorOp(err, u.offset)
+		u.tracer.OnError(op, offset, err)
+	}
+	return err
+}
+
+func (u *Unpacker) traceEnter(op string) {
+	if u.scopes != nil {
+		u.scopes.OnEnter(op, u.offset)
+	}
+}
+
+func (u *Unpacker) traceLeave(op string) {
+	if u.scopes != nil {
+		u.scopes.OnLeave(op, u.offset)
+	}
+}
+
+// errorOp returns the operation and offset recorded in err.
+func errorOp(err error, offset int64) (string, int64) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Op, e.Offset
+	}
+	return "", offset
+}
+
+// LogTracer is a ScopeTracer that writes a line per operation to a writer,
+// indented by the nesting of structs and sections:
+//
+//	00000000 LengthPrefixed {
+//	00000000   Reserve [00 00]
+//	00000002   PushString = "hello" [68 65 6c 6c 6f]
+//	00000000   Fill = 5 [00 05]
+//	00000007 }
+//
+// Write errors are ignored.
+type LogTracer struct {
+	w     io.Writer
+	depth int
+}
+
+// NewLogTracer returns a LogTracer writing to w.
+func NewLogTracer(w io.Writer) *LogTracer {
+	return &LogTracer{w: w}
+}
+
+// maxLogBytes is the number of raw bytes LogTracer shows per operation.
+const maxLogBytes = 16
+
+// OnPush logs a written value.
+func (t *LogTracer) OnPush(op string, offset int64, raw []byte, value interface{}) {
+	t.log(op, offset, raw, value)
+}
+
+// OnShift logs a read value.
+func (t *LogTracer) OnShift(op string, offset int64, raw []byte, value interface{}) {
+	t.log(op, offset, raw, value)
+}
+
+// OnError logs an error.
+func (t *LogTracer) OnError(op string, offset int64, err error) {
+	fmt.Fprintf(t.w, "%08x %s%s: %v\n", offset, t.indent(), op, err)
+}
+
+// OnEnter logs the start of a struct or section and indents what follows.
+func (t *LogTracer) OnEnter(op string, offset int64) {
+	fmt.Fprintf(t.w, "%08x %s%s {\n", offset, t.indent(), op)
+	t.depth++
+}
+
+// OnLeave logs the end of a struct or section.
+func (t *LogTracer) OnLeave(op string, offset int64) {
+	if t.depth > 0 {
+		t.depth--
+	}
+	fmt.Fprintf(t.w, "%08x %s}\n", offset, t.indent())
+}
+
+func (t *LogTracer) log(op string, offset int64, raw []byte, value interface{}) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%08x %s%s", offset, t.indent(), op)
+	switch v := value.(type) {
+	case nil, []byte:
+	case string:
+		fmt.Fprintf(&sb, " = %q", v)
+	default:
+		fmt.Fprintf(&sb, " = %v", v)
+	}
+	switch {
+	case raw == nil:
+	case len(raw) > maxLogBytes:
+		fmt.Fprintf(&sb, " [% x ...] (%d bytes)", raw[:maxLogBytes], len(raw))
+	default:
+		fmt.Fprintf(&sb, " [% x]", raw)
+	}
+	sb.WriteByte('\n')
+	io.WriteString(t.w, sb.String())
+}
+
+func (t *LogTracer) indent() string {
+	return strings.Repeat("  ", t.depth)
+}
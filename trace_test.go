@@ -0,0 +1,207 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordTracer records every call as a line.
+type recordTracer struct {
+	lines []string
+}
+
+func (t *recordTracer) OnPush(op string, offset int64, raw []byte, value interface{}) {
+	t.lines = append(t.lines, fmt.Sprintf("push %s %d % x %v", op, offset, raw, value))
+}
+
+func (t *recordTracer) OnShift(op string, offset int64, raw []byte, value interface{}) {
+	t.lines = append(t.lines, fmt.Sprintf("shift %s %d % x %v", op, offset, raw, value))
+}
+
+func (t *recordTracer) OnError(op string, offset int64, err error) {
+	t.lines = append(t.lines, fmt.Sprintf("error %s %d", op, offset))
+}
+
+// scopeTracer also records scopes.
+type scopeTracer struct {
+	recordTracer
+}
+
+func (t *scopeTracer) OnEnter(op string, offset int64) {
+	t.lines = append(t.lines, fmt.Sprintf("enter %s %d", op, offset))
+}
+
+func (t *scopeTracer) OnLeave(op string, offset int64) {
+	t.lines = append(t.lines, fmt.Sprintf("leave %s %d", op, offset))
+}
+
+func TestTracePush(t *testing.T) {
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushUint16(0x0102).
+		PushInt16(-2).
+		PushString("hi").
+		PushUvarint(300).
+		PushVarint(-3).
+		PushSLEB128(-129).
+		PushBytes([]byte{0xAA}).
+		PushFloat32(1.5)
+	assert.Nil(t, p.Error(), "push error.")
+	assert.Equal(t, tr.lines, []string{
+		"push PushUint16 0 01 02 258",
+		"push PushInt16 2 ff fe -2",
+		"push PushString 4 68 69 hi",
+		"push PushUvarint 6 ac 02 300",
+		"push PushVarint 8 05 -3",
+		"push PushSLEB128 9 ff 7e -129",
+		"push PushBytes 11 aa [170]",
+		"push PushFloat32 12 3f c0 00 00 1.5",
+	}, "trace error.")
+
+	p.WithTracer(nil).PushByte(1)
+	assert.Equal(t, len(tr.lines), 8, "detach error.")
+}
+
+func TestTraceScopes(t *testing.T) {
+	tr := &scopeTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.LengthPrefixed(2, func(p *Packer) {
+		p.PushByte(7)
+	})
+	assert.Nil(t, p.Error(), "push error.")
+	assert.Equal(t, tr.lines, []string{
+		"enter LengthPrefixed 0",
+		"push Reserve 0 00 00 <nil>",
+		"push PushByte 2 07 7",
+		"push Fill 0 00 01 1",
+		"leave LengthPrefixed 3",
+	}, "trace error.")
+}
+
+func TestTraceStruct(t *testing.T) {
+	type point struct {
+		X uint8
+		Y uint16
+	}
+	tr := &scopeTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushStruct(&point{X: 1, Y: 2})
+	assert.Nil(t, p.Error(), "push error.")
+	assert.Equal(t, tr.lines, []string{
+		"enter binpacker.point 0",
+		"push PushUint8 0 01 1",
+		"push PushUint16 1 00 02 2",
+		"leave binpacker.point 3",
+	}, "trace error.")
+}
+
+func TestTraceStructValues(t *testing.T) {
+	type reading struct {
+		On    bool
+		Level float32
+		Delta int16
+	}
+	tr := &recordTracer{}
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(tr)
+	p.PushStruct(&reading{On: true, Level: 1.5, Delta: -2})
+	assert.Nil(t, p.Error(), "push error.")
+	var r reading
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes()).WithTracer(tr)
+	u.FetchStruct(&r)
+	assert.Nil(t, u.Error(), "shift error.")
+	assert.Equal(t, tr.lines, []string{
+		"push PushUint8 0 01 true",
+		"push PushFloat32 1 3f c0 00 00 1.5",
+		"push PushInt16 5 ff fe -2",
+		"shift ShiftUint8 0 01 true",
+		"shift ShiftFloat32 1 3f c0 00 00 1.5",
+		"shift ShiftInt16 5 ff fe -2",
+	}, "trace error.")
+}
+
+func TestTraceShift(t *testing.T) {
+	tr := &recordTracer{}
+	data := []byte{0x01, 0x02, 0xAC, 0x02, 0x05, 0xFF, 0x7E, 'h', 'i', 0, 0}
+	u := NewSliceUnpacker(binary.BigEndian, data).WithTracer(tr)
+	var s string
+	u.FetchUint16(new(uint16)).
+		FetchUvarint(new(uint64)).
+		FetchVarint(new(int64)).
+		FetchSLEB128(new(int64)).
+		FetchString(2, &s)
+	assert.Nil(t, u.Error(), "shift error.")
+	_, err := u.ShiftUint32()
+	assert.NotNil(t, err, "eof error.")
+	assert.Equal(t, tr.lines, []string{
+		"shift ShiftUint16 0 01 02 258",
+		"shift ShiftUvarint 2 ac 02 300",
+		"shift ShiftVarint 4 05 -3",
+		"shift ShiftSLEB128 5 ff 7e -129",
+		"shift ShiftString 7 68 69 hi",
+		"error ShiftUint32 9",
+	}, "trace error.")
+}
+
+func TestTraceShiftReader(t *testing.T) {
+	tr := &recordTracer{}
+	u := NewUnpacker(binary.BigEndian, bytes.NewReader([]byte{1, 2, 3, 0x80})).WithTracer(tr)
+	u.Skip(2)
+	b, err := u.ShiftByte()
+	assert.Nil(t, err, "shift error.")
+	assert.Equal(t, b, byte(3), "shift error.")
+	_, err = u.ShiftUvarint()
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "uvarint error.")
+	assert.Equal(t, tr.lines, []string{
+		"shift Skip 0  2",
+		"shift ShiftByte 2 03 3",
+		"error ShiftUvarint 4",
+	}, "trace error.")
+}
+
+func TestLogTracer(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewAppendPacker(binary.BigEndian, nil).WithTracer(NewLogTracer(&buf))
+	p.LengthPrefixed(2, func(p *Packer) {
+		p.PushString("hello")
+		p.PushBytes(make([]byte, 20))
+	})
+	p.PushUint8(1)
+	u := NewSliceUnpacker(binary.BigEndian, p.Bytes()[:3]).WithTracer(NewLogTracer(&buf))
+	u.ShiftUint16()
+	u.ShiftUint16()
+	assert.Equal(t, buf.String(), ""+
+		"00000000 LengthPrefixed {\n"+
+		"00000000   Reserve [00 00]\n"+
+		"00000002   PushString = \"hello\" [68 65 6c 6c 6f]\n"+
+		"00000007   PushBytes [00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 ...] (20 bytes)\n"+
+		"00000000   Fill = 25 [00 19]\n"+
+		"0000001b }\n"+
+		"0000001b PushUint8 = 1 [01]\n"+
+		"00000000 ShiftUint16 = 25 [00 19]\n"+
+		"00000002 ShiftUint16: binpacker: ShiftUint16 at offset 2: want 2 bytes, got 1: unexpected EOF\n",
+		"log error.")
+}
+
+func TestTraceNoAllocs(t *testing.T) {
+	p := NewAppendPacker(binary.BigEndian, make([]byte, 0, 1024))
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Reset()
+		p.PushUint16(1).PushUint32(2).PushUvarint(300).PushString("abc")
+	})
+	assert.Equal(t, allocs, float64(0), "packer allocs error.")
+
+	u := NewSliceUnpacker(binary.BigEndian, []byte{0, 1, 0, 0, 0, 2, 0xAC, 0x02})
+	allocs = testing.AllocsPerRun(100, func() {
+		u.Seek(0, io.SeekStart)
+		u.ShiftUint16()
+		u.ShiftUint32()
+		u.ShiftUvarint()
+	})
+	assert.Equal(t, allocs, float64(0), "unpacker allocs error.")
+}
@@ -2,17 +2,39 @@ package binpacker
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"math"
 	"unsafe"
 )
 
+// ErrInvalidBool is returned by ShiftBool for a byte other than 0 or 1 when
+// the Unpacker is set to WithStrictBools.
+var ErrInvalidBool = errors.New("binpacker: invalid bool")
+
 // Unpacker helps you unpack binary data from an io.Reader.
 type Unpacker struct {
-	reader io.Reader
-	endian binary.ByteOrder
-	err    error
+	reader  io.Reader
+	endian  binary.ByteOrder
+	err     error
+	opts    UnpackerOptions
+	offset  int64  // bytes consumed so far
+	total   uint64 // bytes read so far, for MaxTotalBytes
+	depth   int    // current nesting depth
+	slice   bool   // whether data is read from data instead of reader
+	data    []byte
+	scratch [scratchSize]byte
+	varint  [binary.MaxVarintLen64]byte // the varint being read, for tracing
+	pending []byte                      // bytes read ahead by Peek or replayed by Reset
+	ahead   []byte                      // backing array for pending
+	mark    mark
+	limited io.LimitedReader // used by Skip
+	hashes  []hash.Hash      // open VerifyChecksummed sections
+	tracer  Tracer
+	scopes  ScopeTracer // tracer, if it is a ScopeTracer
+	strict  bool        // whether strings must be valid UTF-8
+	bools   bool        // whether bools must be 0 or 1
 }
 
 // NewUnpacker returns a *Unpacker which hold an io.Reader. User must provide the byte order explicitly.
@@ -28,12 +50,29 @@ func (u *Unpacker) Error() error {
 	return u.err
 }
 
+// Offset returns the number of bytes consumed so far.
+func (u *Unpacker) Offset() int64 {
+	return u.offset
+}
+
 // ShiftByte fetch the first byte in io.Reader. Returns a byte and an error if
 // exists.
 func (u *Unpacker) ShiftByte() (byte, error) {
-	buffer := make([]byte, 1)
-	_, err := io.ReadFull(u.reader, buffer)
-	return buffer[0], err
+	return shiftUint8[byte](u, "ShiftByte")
+}
+
+// shiftUint8 reads a T, a 1-byte Number, for op and traces it.
+func shiftUint8[T Number](u *Unpacker, op string) (T, error) {
+	var v T
+	buffer, err := u.read(op, 1)
+	if err != nil {
+		return v, err
+	}
+	*(*uint8)(unsafe.Pointer(&v)) = buffer[0]
+	if u.tracer != nil {
+		u.traceShifted(op, buffer, v)
+	}
+	return v, nil
 }
 
 // FetchByte fetch the first byte in io.Reader and set to b.
@@ -46,11 +85,25 @@ func (u *Unpacker) FetchByte(b *byte) *Unpacker {
 // ShiftBytes fetch n bytes in io.Reader. Returns a byte array and an error if
 // exists.
 func (u *Unpacker) ShiftBytes(_n uint64) ([]byte, error) {
-	buf := make([]byte, _n)
-	_, err := io.ReadFull(u.reader, buf)
+	raw, err := u.shiftBytes("ShiftBytes", _n)
+	buf := raw
+	if u.slice || _n <= uint64(len(u.scratch)) {
+		// The result of read is only borrowed, so copy it.
+		buf = append(make([]byte, 0, len(raw)), raw...)
+	}
+	if err == nil && u.tracer != nil {
+		u.traceShifted("ShiftBytes", raw, buf)
+	}
 	return buf, err
 }
 
+func (u *Unpacker) shiftBytes(op string, n uint64) ([]byte, error) {
+	if err := u.checkField(n); err != nil {
+		return nil, u.traceError(&Error{Op: op, Offset: u.offset, Want: n, Err: err})
+	}
+	return u.read(op, n)
+}
+
 // FetchBytes read n bytes and set to bytes.
 func (u *Unpacker) FetchBytes(n uint64, bytes *[]byte) *Unpacker {
 	return u.errFilter(func() {
@@ -58,11 +111,56 @@ func (u *Unpacker) FetchBytes(n uint64, bytes *[]byte) *Unpacker {
 	})
 }
 
+// ShiftRest fetch the bytes up to the end of the input in one read. More bytes
+// than MaxBytesPerField or MaxTotalBytes allow fail with a *LimitError.
+func (u *Unpacker) ShiftRest() ([]byte, error) {
+	const op = "ShiftRest"
+	var buffer []byte
+	var err error
+	if u.slice {
+		buffer, err = u.shiftBytes(op, uint64(len(u.data))-uint64(u.offset))
+	} else if buffer, err = u.readRest(op); err != nil {
+		u.traceError(err)
+	}
+	if err == nil && u.tracer != nil {
+		u.traceShifted(op, buffer, buffer)
+	}
+	return buffer, err
+}
+
+// readRest reads the lookahead buffer and then the reader up to its end.
+func (u *Unpacker) readRest(op string) ([]byte, error) {
+	offset := u.offset
+	buffer := append([]byte(nil), u.pending...)
+	u.pending = u.pending[len(u.pending):]
+	var err error
+	for err == nil {
+		start := len(buffer)
+		buffer = append(buffer, make([]byte, readChunkSize)...)
+		var k int
+		k, err = io.ReadFull(u.reader, buffer[start:])
+		buffer = buffer[:start+k]
+		if lerr := u.checkField(uint64(len(buffer))); lerr != nil {
+			err = lerr
+		} else if lerr := u.checkTotal(uint64(len(buffer))); lerr != nil {
+			err = lerr
+		}
+	}
+	u.record(buffer)
+	for _, h := range u.hashes {
+		h.Write(buffer)
+	}
+	u.offset += int64(len(buffer))
+	u.total += uint64(len(buffer))
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return buffer, &Error{Op: op, Offset: offset, Err: err}
+	}
+	return buffer, nil
+}
+
 // ShiftUint8 fetch 1 byte in io.Reader and covert it to uint8
 func (u *Unpacker) ShiftUint8() (uint8, error) {
-	buffer := make([]byte, 1)
-	_, err := io.ReadFull(u.reader, buffer)
-	return buffer[0], err
+	return shiftUint8[uint8](u, "ShiftUint8")
 }
 
 // FetchUint8 read 1 byte, convert it to uint8 and set it to i.
@@ -72,13 +170,73 @@ func (u *Unpacker) FetchUint8(i *uint8) *Unpacker {
 	})
 }
 
+// ShiftInt8 fetch 1 byte in io.Reader and convert it to int8.
+func (u *Unpacker) ShiftInt8() (int8, error) {
+	return shiftUint8[int8](u, "ShiftInt8")
+}
+
+// FetchInt8 read 1 byte, convert it to int8 and set it to i.
+func (u *Unpacker) FetchInt8(i *int8) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt8()
+	})
+}
+
+// ShiftBool fetch 1 byte in io.Reader and convert it to bool, true for any
+// value but 0. An Unpacker set to WithStrictBools rejects values other than 0
+// and 1 with ErrInvalidBool.
+func (u *Unpacker) ShiftBool() (bool, error) {
+	const op = "ShiftBool"
+	offset := u.offset
+	buffer, err := u.read(op, 1)
+	if err != nil {
+		return false, err
+	}
+	if u.bools && buffer[0] > 1 {
+		return false, u.traceError(&Error{Op: op, Offset: offset, Err: ErrInvalidBool})
+	}
+	v := buffer[0] != 0
+	if u.tracer != nil {
+		u.traceShifted(op, buffer, v)
+	}
+	return v, nil
+}
+
+// FetchBool read 1 byte, convert it to bool and set it to v.
+func (u *Unpacker) FetchBool(v *bool) *Unpacker {
+	return u.errFilter(func() {
+		*v, u.err = u.ShiftBool()
+	})
+}
+
+// WithStrictBools sets whether ShiftBool rejects values other than 0 and 1.
+func (u *Unpacker) WithStrictBools(strict bool) *Unpacker {
+	u.bools = strict
+	return u
+}
+
+// StrictBools reports whether u is set to WithStrictBools.
+func (u *Unpacker) StrictBools() bool {
+	return u.bools
+}
+
 // ShiftUint16 fetch 2 bytes in io.Reader and convert it to uint16.
 func (u *Unpacker) ShiftUint16() (uint16, error) {
-	buffer := make([]byte, 2)
-	if _, err := io.ReadFull(u.reader, buffer); err != nil {
-		return 0, err
+	return shiftUint16[uint16](u, "ShiftUint16", u.endian)
+}
+
+// shiftUint16 reads a T, a 2-byte Number, in order for op and traces it.
+func shiftUint16[T Number](u *Unpacker, op string, order binary.ByteOrder) (T, error) {
+	var v T
+	buffer, err := u.read(op, 2)
+	if err != nil {
+		return v, err
 	}
-	return u.endian.Uint16(buffer), nil
+	*(*uint16)(unsafe.Pointer(&v)) = order.Uint16(buffer)
+	if u.tracer != nil {
+		u.traceShifted(op, buffer, v)
+	}
+	return v, nil
 }
 
 // FetchUint16 read 2 bytes, convert it to uint16 and set it to i.
@@ -90,28 +248,38 @@ func (u *Unpacker) FetchUint16(i *uint16) *Unpacker {
 
 // ShiftInt16 fetch 2 bytes in io.Reader and convert it to int16.
 func (u *Unpacker) ShiftInt16() (int16, error) {
-	i, err := u.ShiftUint16()
-	return int16(i), err
+	return shiftUint16[int16](u, "ShiftInt16", u.endian)
 }
 
 // FetchInt16 read 2 bytes, convert it to int16 and set it to i.
 func (u *Unpacker) FetchInt16(i *int16) *Unpacker {
-	return u.FetchUint16((*uint16)(unsafe.Pointer(i)))
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt16()
+	})
 }
 
 // ShiftUint32 fetch 4 bytes in io.Reader and convert it to uint32.
 func (u *Unpacker) ShiftUint32() (uint32, error) {
-	buffer := make([]byte, 4)
-	if _, err := io.ReadFull(u.reader, buffer); err != nil {
-		return 0, err
+	return shiftUint32[uint32](u, "ShiftUint32", u.endian)
+}
+
+// shiftUint32 reads a T, a 4-byte Number, in order for op and traces it.
+func shiftUint32[T Number](u *Unpacker, op string, order binary.ByteOrder) (T, error) {
+	var v T
+	buffer, err := u.read(op, 4)
+	if err != nil {
+		return v, err
+	}
+	*(*uint32)(unsafe.Pointer(&v)) = order.Uint32(buffer)
+	if u.tracer != nil {
+		u.traceShifted(op, buffer, v)
 	}
-	return u.endian.Uint32(buffer), nil
+	return v, nil
 }
 
 // ShiftInt32 fetch 4 bytes in io.Reader and convert it to int32.
 func (u *Unpacker) ShiftInt32() (int32, error) {
-	i, err := u.ShiftUint32()
-	return int32(i), err
+	return shiftUint32[int32](u, "ShiftInt32", u.endian)
 }
 
 // FetchUint32 read 4 bytes, convert it to uint32 and set it to i.
@@ -123,22 +291,33 @@ func (u *Unpacker) FetchUint32(i *uint32) *Unpacker {
 
 // FetchInt32 read 4 bytes, convert it to int32 and set it to i.
 func (u *Unpacker) FetchInt32(i *int32) *Unpacker {
-	return u.FetchUint32((*uint32)(unsafe.Pointer(i)))
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt32()
+	})
 }
 
 // ShiftUint64 fetch 8 bytes in io.Reader and convert it to uint64.
 func (u *Unpacker) ShiftUint64() (uint64, error) {
-	buffer := make([]byte, 8)
-	if _, err := io.ReadFull(u.reader, buffer); err != nil {
-		return 0, err
+	return shiftUint64[uint64](u, "ShiftUint64", u.endian)
+}
+
+// shiftUint64 reads a T, an 8-byte Number, in order for op and traces it.
+func shiftUint64[T Number](u *Unpacker, op string, order binary.ByteOrder) (T, error) {
+	var v T
+	buffer, err := u.read(op, 8)
+	if err != nil {
+		return v, err
+	}
+	*(*uint64)(unsafe.Pointer(&v)) = order.Uint64(buffer)
+	if u.tracer != nil {
+		u.traceShifted(op, buffer, v)
 	}
-	return u.endian.Uint64(buffer), nil
+	return v, nil
 }
 
 // ShiftInt64 fetch 8 bytes in io.Reader and convert it to int64.
 func (u *Unpacker) ShiftInt64() (int64, error) {
-	i, err := u.ShiftUint64()
-	return int64(i), err
+	return shiftUint64[int64](u, "ShiftInt64", u.endian)
 }
 
 // FetchUint64 read 8 bytes, convert it to uint64 and set it to i.
@@ -150,25 +329,19 @@ func (u *Unpacker) FetchUint64(i *uint64) *Unpacker {
 
 // FetchInt64 read 8 bytes, convert it to int64 and set it to i.
 func (u *Unpacker) FetchInt64(i *int64) *Unpacker {
-	return u.FetchUint64((*uint64)(unsafe.Pointer(i)))
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftInt64()
+	})
 }
 
 // ShiftFloat32 fetch 4 bytes in io.Reader and convert it to float32.
 func (u *Unpacker) ShiftFloat32() (float32, error) {
-	buffer := make([]byte, 4)
-	if _, err := io.ReadFull(u.reader, buffer); err != nil {
-		return 0, err
-	}
-	return math.Float32frombits(u.endian.Uint32(buffer)), nil
+	return shiftUint32[float32](u, "ShiftFloat32", u.endian)
 }
 
 // ShiftFloat64 fetch 8 bytes in io.Reader and convert it to float64.
 func (u *Unpacker) ShiftFloat64() (float64, error) {
-	buffer := make([]byte, 8)
-	if _, err := io.ReadFull(u.reader, buffer); err != nil {
-		return 0, err
-	}
-	return math.Float64frombits(u.endian.Uint64(buffer)), nil
+	return shiftUint64[float64](u, "ShiftFloat64", u.endian)
 }
 
 // FetchFloat32 read 4 bytes, convert it to float32 and set it to i.
@@ -187,11 +360,19 @@ func (u *Unpacker) FetchFloat64(i *float64) *Unpacker {
 
 // ShiftString fetch n bytes, convert it to string. Returns string and an error.
 func (u *Unpacker) ShiftString(n uint64) (string, error) {
-	buffer := make([]byte, n)
-	if _, err := io.ReadFull(u.reader, buffer); err != nil {
+	offset := u.offset
+	buffer, err := u.shiftBytes("ShiftString", n)
+	if err != nil {
+		return "", err
+	}
+	if err := u.checkUTF8("ShiftString", offset, buffer); err != nil {
 		return "", err
 	}
-	return string(buffer), nil
+	s := string(buffer)
+	if u.tracer != nil {
+		u.traceShifted("ShiftString", buffer, s)
+	}
+	return s, nil
 }
 
 // FetchString read n bytes, convert it to string and set t to s.
@@ -201,6 +382,16 @@ func (u *Unpacker) FetchString(n uint64, s *string) *Unpacker {
 	})
 }
 
+// StringWithUint8Prefix read 1 byte as string length, then read N bytes,
+// convert it to string and set it to s.
+func (u *Unpacker) StringWithUint8Prefix(s *string) *Unpacker {
+	return u.errFilter(func() {
+		var n uint8
+		n, u.err = u.ShiftUint8()
+		u.FetchString(uint64(n), s)
+	})
+}
+
 // StringWithUint16Prefix read 2 bytes as string length, then read N bytes,
 // convert it to string and set it to s.
 func (u *Unpacker) StringWithUint16Prefix(s *string) *Unpacker {
@@ -231,6 +422,16 @@ func (u *Unpacker) StringWithUint64Prefix(s *string) *Unpacker {
 	})
 }
 
+// BytesWithUint8Prefix read 1 byte as bytes length, then read N bytes and set
+// it to bytes.
+func (u *Unpacker) BytesWithUint8Prefix(bytes *[]byte) *Unpacker {
+	return u.errFilter(func() {
+		var n uint8
+		n, u.err = u.ShiftUint8()
+		u.FetchBytes(uint64(n), bytes)
+	})
+}
+
 // BytesWithUint16Prefix read 2 bytes as bytes length, then read N bytes and set
 // it to bytes.
 func (u *Unpacker) BytesWithUint16Prefix(bytes *[]byte) *Unpacker {
@@ -261,6 +462,87 @@ func (u *Unpacker) BytesWithUint64Prefix(bytes *[]byte) *Unpacker {
 	})
 }
 
+// readChunkSize is the largest buffer read allocates before the data arrives.
+const readChunkSize = 64 << 10
+
+// read reads exactly n bytes for the operation op. Errors are traced and
+// returned as *Error; the value is traced by the caller, which has it.
+//
+// The result is only valid until the next read: reads of up to 16 bytes use a
+// scratch buffer and a SliceUnpacker returns a subslice of its input. Larger
+// reads from an io.Reader are done in chunks so that memory only grows as data
+// actually arrives.
+func (u *Unpacker) read(op string, n uint64) ([]byte, error) {
+	buffer, err := u.readUntraced(op, n)
+	if err != nil {
+		return buffer, u.traceError(err)
+	}
+	return buffer, nil
+}
+
+// readUntraced is read without tracing errors.
+func (u *Unpacker) readUntraced(op string, n uint64) ([]byte, error) {
+	offset := u.offset
+	if err := u.checkTotal(n); err != nil {
+		return nil, &Error{Op: op, Offset: offset, Want: n, Err: err}
+	}
+	var buffer []byte
+	var err error
+	if u.slice {
+		buffer, err = u.readSlice(n)
+	} else {
+		buffer, err = u.readStream(n)
+	}
+	for _, h := range u.hashes {
+		h.Write(buffer)
+	}
+	u.offset += int64(len(buffer))
+	u.total += uint64(len(buffer))
+	if err != nil {
+		return buffer, &Error{Op: op, Offset: offset, Want: n, Got: uint64(len(buffer)), Err: err}
+	}
+	return buffer, nil
+}
+
+// readStream reads n bytes from the lookahead buffer and then the reader.
+func (u *Unpacker) readStream(n uint64) ([]byte, error) {
+	var buffer []byte
+	var err error
+	if n <= uint64(len(u.scratch)) {
+		m := copy(u.scratch[:n], u.pending)
+		u.pending = u.pending[m:]
+		var k int
+		k, err = io.ReadFull(u.reader, u.scratch[m:n])
+		buffer = u.scratch[:m+k]
+		if err == io.EOF && m > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+	} else {
+		buffer, err = u.readChunked(n)
+	}
+	u.record(buffer)
+	return buffer, err
+}
+
+func (u *Unpacker) readChunked(n uint64) ([]byte, error) {
+	m := minUint64(n, uint64(len(u.pending)))
+	buffer := append(make([]byte, 0, minUint64(n, readChunkSize)), u.pending[:m]...)
+	u.pending = u.pending[m:]
+	for uint64(len(buffer)) < n {
+		k := minUint64(n-uint64(len(buffer)), readChunkSize)
+		start := uint64(len(buffer))
+		buffer = append(buffer, make([]byte, k)...)
+		got, err := io.ReadFull(u.reader, buffer[start:])
+		if err != nil {
+			if err == io.EOF && start > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return buffer[:start+uint64(got)], err
+		}
+	}
+	return buffer, nil
+}
+
 func (u *Unpacker) errFilter(f func()) *Unpacker {
 	if u.err == nil {
 		f()
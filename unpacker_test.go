@@ -228,6 +228,20 @@ func TestRead(t *testing.T) {
 	assert.Equal(t, s, "Hi", "string error.")
 }
 
+func TestReadWithUint8Prefix(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, buf)
+	u := NewUnpacker(binary.BigEndian, buf)
+	var bs []byte
+	var s string
+
+	p.PushBytesWithUint8Prefix([]byte("Hi")).PushStringWithUint8Prefix("Hi")
+	u.BytesWithUint8Prefix(&bs).StringWithUint8Prefix(&s)
+	assert.Equal(t, u.Error(), nil, "Has error.")
+	assert.Equal(t, bs, []byte("Hi"), "Bytes with prefixes error.")
+	assert.Equal(t, s, "Hi", "String with prefixes error.")
+}
+
 func TestReadWithPerfix(t *testing.T) {
 	buf := new(bytes.Buffer)
 	p := NewPacker(binary.BigEndian, buf)
@@ -0,0 +1,225 @@
+package binpacker
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrVarintOverflow is returned when a varint or LEB128 value does not fit
+	// in 64 bits.
+	ErrVarintOverflow = errors.New("binpacker: varint overflows a 64-bit integer")
+	// ErrVarintOverlong is returned when a varint or LEB128 value is not
+	// encoded in the minimal number of bytes.
+	ErrVarintOverlong = errors.New("binpacker: varint is not minimally encoded")
+)
+
+// PushUvarint write a uint64 as an unsigned varint (ULEB128) into writer.
+func (p *Packer) PushUvarint(i uint64) *Packer {
+	return pushUvarint(p, "PushUvarint", i, i)
+}
+
+// pushUvarint writes x as a varint for op and traces v, the value as the
+// caller has it.
+func pushUvarint[T uint64 | int64](p *Packer, op string, x uint64, v T) *Packer {
+	return p.errFilter(func() {
+		n := binary.PutUvarint(p.scratch[:], x)
+		p.write(op, p.scratch[:n])
+		if p.tracer != nil {
+			p.tracePushed(op, p.scratch[:n], v)
+		}
+	})
+}
+
+// PushVarint write a int64 as a zigzag encoded varint into writer.
+func (p *Packer) PushVarint(i int64) *Packer {
+	return pushUvarint(p, "PushVarint", uint64(i<<1)^uint64(i>>63), i)
+}
+
+// PushSLEB128 write a int64 as a signed LEB128 into writer.
+func (p *Packer) PushSLEB128(i int64) *Packer {
+	return p.errFilter(func() {
+		v := i
+		buffer := p.scratch[:0]
+		for {
+			b := byte(i & 0x7f)
+			i >>= 7
+			if (i == 0 && b&0x40 == 0) || (i == -1 && b&0x40 != 0) {
+				buffer = append(buffer, b)
+				break
+			}
+			buffer = append(buffer, b|0x80)
+		}
+		p.write("PushSLEB128", buffer)
+		if p.tracer != nil {
+			p.tracePushed("PushSLEB128", buffer, v)
+		}
+	})
+}
+
+// PushStringWithUvarintPrefix write the length of string as an unsigned varint
+// prefix, then string into writer.
+func (p *Packer) PushStringWithUvarintPrefix(s string) *Packer {
+	return p.PushUvarint(uint64(len(s))).PushString(s)
+}
+
+// PushBytesWithUvarintPrefix write the length of bytes as an unsigned varint
+// prefix, then bytes into writer.
+func (p *Packer) PushBytesWithUvarintPrefix(bytes []byte) *Packer {
+	return p.PushUvarint(uint64(len(bytes))).PushBytes(bytes)
+}
+
+// ShiftUvarint fetch an unsigned varint (ULEB128) in io.Reader. Returns a
+// uint64 and an error if exists.
+func (u *Unpacker) ShiftUvarint() (uint64, error) {
+	const op = "ShiftUvarint"
+	x, n, err := u.uvarint(op)
+	if err != nil {
+		return 0, u.traceError(err)
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, u.varint[:n], x)
+	}
+	return x, nil
+}
+
+// uvarint reads an unsigned varint into u.varint and returns its value and
+// length.
+func (u *Unpacker) uvarint(op string) (uint64, int, error) {
+	offset := u.offset
+	var x uint64
+	for i := 0; ; i++ {
+		b, err := u.shiftPartByte(op, i)
+		if err != nil {
+			return 0, i, err
+		}
+		u.varint[i] = b
+		if i == binary.MaxVarintLen64-1 && b > 1 {
+			return 0, i + 1, &Error{Op: op, Offset: offset, Err: ErrVarintOverflow}
+		}
+		x |= uint64(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			if b == 0 && i > 0 {
+				return 0, i + 1, &Error{Op: op, Offset: offset, Err: ErrVarintOverlong}
+			}
+			return x, i + 1, nil
+		}
+	}
+}
+
+// FetchUvarint read an unsigned varint (ULEB128) and set it to i.
+func (u *Unpacker) FetchUvarint(i *uint64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftUvarint()
+	})
+}
+
+// ShiftVarint fetch a zigzag encoded varint in io.Reader. Returns a int64 and
+// an error if exists.
+func (u *Unpacker) ShiftVarint() (int64, error) {
+	const op = "ShiftVarint"
+	x, n, err := u.uvarint(op)
+	if err != nil {
+		return 0, u.traceError(err)
+	}
+	v := int64(x>>1) ^ -int64(x&1)
+	if u.tracer != nil {
+		u.traceShifted(op, u.varint[:n], v)
+	}
+	return v, nil
+}
+
+// FetchVarint read a zigzag encoded varint and set it to i.
+func (u *Unpacker) FetchVarint(i *int64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftVarint()
+	})
+}
+
+// ShiftSLEB128 fetch a signed LEB128 in io.Reader. Returns a int64 and an
+// error if exists.
+func (u *Unpacker) ShiftSLEB128() (int64, error) {
+	const op = "ShiftSLEB128"
+	x, n, err := u.sleb128(op)
+	if err != nil {
+		return 0, u.traceError(err)
+	}
+	if u.tracer != nil {
+		u.traceShifted(op, u.varint[:n], x)
+	}
+	return x, nil
+}
+
+// sleb128 reads a signed LEB128 into u.varint and returns its value and
+// length.
+func (u *Unpacker) sleb128(op string) (int64, int, error) {
+	offset := u.offset
+	var x int64
+	var prev byte
+	for i := 0; ; i++ {
+		b, err := u.shiftPartByte(op, i)
+		if err != nil {
+			return 0, i, err
+		}
+		u.varint[i] = b
+		shift := 7 * uint(i)
+		if i == binary.MaxVarintLen64-1 {
+			// Only the lowest bit is left, the others must extend the sign.
+			if b != 0x00 && b != 0x7f {
+				return 0, i + 1, &Error{Op: op, Offset: offset, Err: ErrVarintOverflow}
+			}
+		}
+		x |= int64(b&0x7f) << shift
+		if b < 0x80 {
+			if i > 0 && ((b == 0x00 && prev&0x40 == 0) || (b == 0x7f && prev&0x40 != 0)) {
+				return 0, i + 1, &Error{Op: op, Offset: offset, Err: ErrVarintOverlong}
+			}
+			if shift+7 < 64 && b&0x40 != 0 {
+				x |= -1 << (shift + 7)
+			}
+			return x, i + 1, nil
+		}
+		prev = b
+	}
+}
+
+// FetchSLEB128 read a signed LEB128 and set it to i.
+func (u *Unpacker) FetchSLEB128(i *int64) *Unpacker {
+	return u.errFilter(func() {
+		*i, u.err = u.ShiftSLEB128()
+	})
+}
+
+// shiftPartByte reads the i-th byte of a value that is traced as a whole, such
+// as a varint. Running out of data after the first byte is an unexpected EOF.
+func (u *Unpacker) shiftPartByte(op string, i int) (byte, error) {
+	buffer, err := u.readUntraced(op, 1)
+	if err != nil {
+		if e, ok := err.(*Error); ok && e.Err == io.EOF && i > 0 {
+			e.Err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	return buffer[0], nil
+}
+
+// StringWithUvarintPrefix read an unsigned varint as string length, then read
+// N bytes, convert it to string and set it to s.
+func (u *Unpacker) StringWithUvarintPrefix(s *string) *Unpacker {
+	return u.errFilter(func() {
+		var n uint64
+		n, u.err = u.ShiftUvarint()
+		u.FetchString(n, s)
+	})
+}
+
+// BytesWithUvarintPrefix read an unsigned varint as bytes length, then read N
+// bytes and set it to bytes.
+func (u *Unpacker) BytesWithUvarintPrefix(bytes *[]byte) *Unpacker {
+	return u.errFilter(func() {
+		var n uint64
+		n, u.err = u.ShiftUvarint()
+		u.FetchBytes(n, bytes)
+	})
+}
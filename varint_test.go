@@ -0,0 +1,123 @@
+package binpacker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushUvarint(t *testing.T) {
+	for _, i := range []uint64{0, 1, 127, 128, 300, math.MaxUint32, math.MaxUint64} {
+		b := new(bytes.Buffer)
+		p := NewPacker(binary.BigEndian, b)
+		p.PushUvarint(i)
+		assert.Equal(t, p.Error(), nil, "Has error.")
+		assert.Equal(t, b.Bytes(), binary.AppendUvarint(nil, i), "uvarint error.")
+
+		u := NewUnpacker(binary.BigEndian, b)
+		j, err := u.ShiftUvarint()
+		assert.Equal(t, err, nil, "Has error.")
+		assert.Equal(t, j, i, "uvarint error.")
+	}
+}
+
+func TestPushVarint(t *testing.T) {
+	for _, i := range []int64{0, -1, 1, -64, 64, math.MinInt64, math.MaxInt64} {
+		b := new(bytes.Buffer)
+		p := NewPacker(binary.BigEndian, b)
+		p.PushVarint(i)
+		assert.Equal(t, p.Error(), nil, "Has error.")
+		assert.Equal(t, b.Bytes(), binary.AppendVarint(nil, i), "varint error.")
+
+		var j int64
+		u := NewUnpacker(binary.BigEndian, b)
+		u.FetchVarint(&j)
+		assert.Equal(t, u.Error(), nil, "Has error.")
+		assert.Equal(t, j, i, "varint error.")
+	}
+}
+
+func TestPushSLEB128(t *testing.T) {
+	cases := []struct {
+		i int64
+		b []byte
+	}{
+		{0, []byte{0x00}},
+		{2, []byte{0x02}},
+		{-1, []byte{0x7f}},
+		{63, []byte{0x3f}},
+		{64, []byte{0xc0, 0x00}},
+		{-64, []byte{0x40}},
+		{-65, []byte{0xbf, 0x7f}},
+		{-123456, []byte{0xc0, 0xbb, 0x78}},
+		{math.MaxInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}},
+		{math.MinInt64, []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7f}},
+	}
+	for _, c := range cases {
+		b := new(bytes.Buffer)
+		p := NewPacker(binary.BigEndian, b)
+		p.PushSLEB128(c.i)
+		assert.Equal(t, p.Error(), nil, "Has error.")
+		assert.Equal(t, b.Bytes(), c.b, "sleb128 error.")
+
+		var j int64
+		u := NewUnpacker(binary.BigEndian, b)
+		u.FetchSLEB128(&j)
+		assert.Equal(t, u.Error(), nil, "Has error.")
+		assert.Equal(t, j, c.i, "sleb128 error.")
+	}
+}
+
+func TestShiftVarintErrors(t *testing.T) {
+	cases := []struct {
+		b     []byte
+		uvErr error
+		slErr error
+	}{
+		{[]byte{}, io.EOF, io.EOF},
+		{[]byte{0x80}, io.ErrUnexpectedEOF, io.ErrUnexpectedEOF},
+		{[]byte{0x80, 0x00}, ErrVarintOverlong, ErrVarintOverlong},
+		{[]byte{0xff, 0x7f}, nil, ErrVarintOverlong},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, ErrVarintOverflow, ErrVarintOverflow},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, ErrVarintOverflow, ErrVarintOverflow},
+	}
+	for _, c := range cases {
+		_, err := NewUnpacker(binary.BigEndian, bytes.NewReader(c.b)).ShiftUvarint()
+		if c.uvErr == nil {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorIs(t, err, c.uvErr, "uvarint error.")
+		}
+		_, err = NewUnpacker(binary.BigEndian, bytes.NewReader(c.b)).ShiftSLEB128()
+		assert.ErrorIs(t, err, c.slErr, "sleb128 error.")
+	}
+}
+
+func TestReadWithUvarintPrefix(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, buf)
+	u := NewUnpacker(binary.BigEndian, buf)
+	var bs []byte
+	var s string
+
+	p.PushUvarint(2).PushBytes([]byte("Hi"))
+	u.BytesWithUvarintPrefix(&bs)
+	assert.Equal(t, bs, []byte("Hi"), "Bytes with prefixes error.")
+	p.PushUvarint(2).PushString("Hi")
+	u.StringWithUvarintPrefix(&s)
+	assert.Equal(t, s, "Hi", "String with prefixes error.")
+	assert.Equal(t, u.Error(), nil, "Has error.")
+}
+
+func TestPushWithUvarintPrefix(t *testing.T) {
+	b := new(bytes.Buffer)
+	p := NewPacker(binary.BigEndian, b)
+	p.PushStringWithUvarintPrefix("Hi").PushBytesWithUvarintPrefix(make([]byte, 128))
+	assert.Equal(t, p.Error(), nil, "Has error.")
+	assert.Equal(t, b.Bytes()[:5], []byte{2, 'H', 'i', 0x80, 0x01}, "uvarint prefix error.")
+	assert.Equal(t, b.Len(), 5+128, "uvarint prefix error.")
+}